@@ -11,6 +11,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// ContextKeyErrorCode 失败响应的错误码，供日志等中间件识别业务错误
+const ContextKeyErrorCode = "errorCode"
+
 type PageRequest struct {
 	Page     int `json:"page" form:"page"`
 	PageSize int `json:"pageSize" form:"pageSize"`
@@ -81,12 +84,24 @@ func NewResponse[T any](code int, message string, data T) *Response[T] {
 	}
 }
 
+func (r *Response[T]) GetCode() int {
+	return r.Code
+}
+
+func (r *Response[T]) GetMessage() string {
+	return r.Message
+}
+
+func (r *Response[T]) GetData() any {
+	return r.Data
+}
+
 func Success(ctx *gin.Context, messages ...string) {
 	message := "success"
 	if len(messages) > 0 {
 		message = messages[0]
 	}
-	ctx.JSON(http.StatusOK, NewResponse[any](errorx.ErrCodeSuccess, message, nil))
+	writeResponse(ctx, http.StatusOK, NewResponse[any](errorx.ErrCodeSuccess, message, nil))
 }
 
 func SuccessWithData[T any](ctx *gin.Context, data T, messages ...string) {
@@ -94,9 +109,17 @@ func SuccessWithData[T any](ctx *gin.Context, data T, messages ...string) {
 	if len(messages) > 0 {
 		message = messages[0]
 	}
-	ctx.JSON(http.StatusOK, NewResponse(errorx.ErrCodeSuccess, message, data))
+	writeResponse(ctx, http.StatusOK, NewResponse(errorx.ErrCodeSuccess, message, data))
 }
 
+// Failure 输出失败响应，默认HTTP状态码为200、错误码在响应体中；
+// 启用问题详情的路由组使用错误码对应的HTTP状态码输出 application/problem+json
 func Failure(ctx *gin.Context, err error) {
-	ctx.JSON(http.StatusOK, NewResponse[any](errorx.CodeOf(err), errorx.MessageOf(err), nil))
+	code := errorx.CodeOf(err)
+	ctx.Set(ContextKeyErrorCode, code)
+	if ProblemDetailsEnabled(ctx) {
+		writeProblem(ctx, errorx.HTTPStatus(code), code, errorx.MessageOf(err))
+		return
+	}
+	writeResponse(ctx, http.StatusOK, NewResponse[any](code, errorx.MessageOf(err), nil))
 }
@@ -0,0 +1,205 @@
+/*
+Copyright © 2025 lixw
+*/
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/goccy/go-yaml"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MediaTypeJSON     = "application/json"
+	MediaTypeMsgPack  = "application/msgpack"
+	MediaTypeProtobuf = "application/x-protobuf"
+	MediaTypeYAML     = "application/yaml"
+	MediaTypeCSV      = "text/csv"
+)
+
+// ErrCodecUnsupported 编码器不支持该响应数据时返回，响应回退为 JSON
+var ErrCodecUnsupported = errors.New("codec does not support response data")
+
+// Envelope 统一响应结构，供编码器读取响应内容
+type Envelope interface {
+	GetCode() int
+	GetMessage() string
+	GetData() any
+}
+
+// Codec 响应编码器，根据 Accept 请求头协商选择
+type Codec interface {
+	Render(ctx *gin.Context, status int, resp Envelope) error
+}
+
+// CodecFunc 函数形式的编码器
+type CodecFunc func(ctx *gin.Context, status int, resp Envelope) error
+
+func (f CodecFunc) Render(ctx *gin.Context, status int, resp Envelope) error {
+	return f(ctx, status, resp)
+}
+
+var (
+	codecMu sync.RWMutex
+	codecs  = map[string]Codec{}
+)
+
+func init() {
+	RegisterCodec(CodecFunc(renderJSON), MediaTypeJSON)
+	RegisterCodec(CodecFunc(renderMsgPack), MediaTypeMsgPack, "application/x-msgpack", "application/vnd.msgpack")
+	RegisterCodec(CodecFunc(renderProtobuf), MediaTypeProtobuf, "application/protobuf", "application/vnd.google.protobuf")
+	RegisterCodec(CodecFunc(renderYAML), MediaTypeYAML, "application/x-yaml", "text/yaml")
+	RegisterCodec(CodecFunc(renderCSV), MediaTypeCSV)
+}
+
+// RegisterCodec 注册或替换媒体类型对应的编码器
+func RegisterCodec(codec Codec, mediaTypes ...string) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	for _, mediaType := range mediaTypes {
+		codecs[strings.ToLower(mediaType)] = codec
+	}
+}
+
+// writeResponse 按 Accept 请求头选择编码器输出响应，未匹配、编码器不支持或编码失败时输出 JSON
+func writeResponse(ctx *gin.Context, status int, resp Envelope) {
+	ctx.Writer.Header().Add("Vary", "Accept")
+	if codec := negotiateCodec(ctx.GetHeader("Accept")); codec != nil {
+		err := codec.Render(ctx, status, resp)
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, ErrCodecUnsupported) {
+			_ = ctx.Error(err)
+		}
+		if ctx.Writer.Written() {
+			return
+		}
+	}
+	_ = renderJSON(ctx, status, resp)
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiateCodec 按 q 值从高到低匹配已注册的编码器，支持 type/* 通配和 +json 等结构化后缀
+func negotiateCodec(accept string) Codec {
+	if accept == "" {
+		return nil
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	for _, r := range ranges {
+		switch {
+		case r.mediaType == "*/*":
+			return nil
+		case strings.HasSuffix(r.mediaType, "/*"):
+			prefix := strings.TrimSuffix(r.mediaType, "*")
+			if strings.HasPrefix(MediaTypeJSON, prefix) {
+				return nil
+			}
+			var matched []string
+			for mediaType := range codecs {
+				if strings.HasPrefix(mediaType, prefix) {
+					matched = append(matched, mediaType)
+				}
+			}
+			if len(matched) > 0 {
+				sort.Strings(matched)
+				return codecs[matched[0]]
+			}
+		default:
+			if codec, ok := codecs[r.mediaType]; ok {
+				return codec
+			}
+			// application/vnd.x.v1+json 等结构化后缀按后缀类型处理
+			if i := strings.LastIndex(r.mediaType, "+"); i >= 0 {
+				if codec, ok := codecs["application/"+r.mediaType[i+1:]]; ok {
+					return codec
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func renderJSON(ctx *gin.Context, status int, resp Envelope) error {
+	ctx.JSON(status, resp)
+	return nil
+}
+
+func renderMsgPack(ctx *gin.Context, status int, resp Envelope) error {
+	ctx.Render(status, render.MsgPack{Data: resp})
+	return nil
+}
+
+// renderProtobuf 仅在成功响应的数据为 proto.Message（如生成的 DTO）时输出其 protobuf 编码
+func renderProtobuf(ctx *gin.Context, status int, resp Envelope) error {
+	msg, ok := resp.GetData().(proto.Message)
+	if !ok {
+		return ErrCodecUnsupported
+	}
+	ctx.Render(status, render.ProtoBuf{Data: msg})
+	return nil
+}
+
+// renderYAML 经由 JSON 转换，字段名和取值与 JSON 响应保持一致
+func renderYAML(ctx *gin.Context, status int, resp Envelope) error {
+	buf, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	out, err := yaml.JSONToYAML(buf)
+	if err != nil {
+		return err
+	}
+	ctx.Data(status, MediaTypeYAML+"; charset=utf-8", out)
+	return nil
+}
+
+// renderCSV 仅支持列表数据（PageResult），分页信息通过响应头返回
+func renderCSV(ctx *gin.Context, status int, resp Envelope) error {
+	table, ok := resp.GetData().(tabular)
+	if !ok {
+		return ErrCodecUnsupported
+	}
+	out, err := table.csv()
+	if err != nil {
+		return err
+	}
+	page, pageSize, total := table.pagination()
+	ctx.Header("X-Page", strconv.Itoa(page))
+	ctx.Header("X-Page-Size", strconv.Itoa(pageSize))
+	ctx.Header("X-Total-Count", strconv.Itoa(total))
+	ctx.Data(status, MediaTypeCSV+"; charset=utf-8", out)
+	return nil
+}
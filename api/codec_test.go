@@ -0,0 +1,156 @@
+/*
+Copyright © 2025 lixw
+*/
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// respond 以 accept 请求头输出 data 的成功响应
+func respond[T any](accept string, data T) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/files", nil)
+	if accept != "" {
+		ctx.Request.Header.Set("Accept", accept)
+	}
+	SuccessWithData(ctx, data)
+	return w
+}
+
+func mediaType(w *httptest.ResponseRecorder) string {
+	mediaType, _, _ := strings.Cut(w.Header().Get("Content-Type"), ";")
+	return mediaType
+}
+
+func TestNegotiateCodec(t *testing.T) {
+	page := NewPageResult([]string{"a"}, 1, 10, 1)
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", MediaTypeJSON},
+		{"application/json", MediaTypeJSON},
+		{"application/yaml", MediaTypeYAML},
+		{"APPLICATION/X-YAML", MediaTypeYAML},
+		// q 值从高到低，相同 q 值保持请求头中的顺序
+		{"application/json;q=0.5, application/yaml", MediaTypeYAML},
+		{"application/yaml;q=0.5, application/json", MediaTypeJSON},
+		{"application/msgpack;q=0.9, text/csv;q=0.1", "application/msgpack"},
+		{"text/csv, application/yaml", MediaTypeCSV},
+		{"application/yaml;q=0, application/msgpack;q=0.1", "application/msgpack"},
+		{"application/yaml;q=abc, text/csv;q=0.2", MediaTypeCSV},
+		// 未注册的类型跳过
+		{"text/html, application/xml;q=0.9, application/x-yaml;q=0.8", MediaTypeYAML},
+		{"application/xml", MediaTypeJSON},
+		// type/* 通配，包含 JSON 时优先 JSON
+		{"*/*", MediaTypeJSON},
+		{"application/*", MediaTypeJSON},
+		{"text/*", MediaTypeCSV},
+		{"image/*", MediaTypeJSON},
+		// 结构化后缀
+		{"application/vnd.app.v1+json", MediaTypeJSON},
+		{"application/vnd.app.v1+yaml", MediaTypeYAML},
+		{"application/vnd.app.v1+xml", MediaTypeJSON},
+	}
+	for _, c := range cases {
+		w := respond(c.accept, page)
+		if got := mediaType(w); got != c.want {
+			t.Errorf("%q: content type = %s, want %s", c.accept, got, c.want)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("%q: vary = %q, want Accept", c.accept, got)
+		}
+	}
+}
+
+func TestCodecFallback(t *testing.T) {
+	// 非 proto.Message 的数据回退为 JSON
+	w := respond(MediaTypeProtobuf, map[string]string{"name": "a"})
+	if got := mediaType(w); got != MediaTypeJSON {
+		t.Errorf("protobuf of map: content type = %s, want %s", got, MediaTypeJSON)
+	}
+	if !strings.Contains(w.Body.String(), `"name":"a"`) {
+		t.Errorf("body = %s, want the json response", w.Body.String())
+	}
+
+	w = respond(MediaTypeProtobuf, wrapperspb.String("a"))
+	if got := mediaType(w); got != MediaTypeProtobuf {
+		t.Fatalf("protobuf of message: content type = %s, want %s", got, MediaTypeProtobuf)
+	}
+	var msg wrapperspb.StringValue
+	if err := proto.Unmarshal(w.Body.Bytes(), &msg); err != nil || msg.GetValue() != "a" {
+		t.Errorf("decoded = %q, %v, want a", msg.GetValue(), err)
+	}
+
+	// 非列表数据不支持 CSV
+	w = respond(MediaTypeCSV, map[string]string{"name": "a"})
+	if got := mediaType(w); got != MediaTypeJSON {
+		t.Errorf("csv of map: content type = %s, want %s", got, MediaTypeJSON)
+	}
+}
+
+type csvBase struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type csvMeta struct {
+	Tags []string `json:"tags"`
+}
+
+type csvRow struct {
+	csvBase
+	*csvMeta
+	Name     string            `json:"name,omitempty"`
+	Size     *int64            `json:"size"`
+	Public   bool              `json:"public"`
+	Labels   map[string]string `json:"labels"`
+	Secret   string            `json:"-"`
+	Untagged float64
+	hidden   string
+}
+
+func TestCSV(t *testing.T) {
+	size := int64(42)
+	created := time.Date(2025, 9, 1, 8, 0, 0, 0, time.UTC)
+	rows := []csvRow{
+		{csvBase: csvBase{ID: 1, CreatedAt: created}, csvMeta: &csvMeta{Tags: []string{"a", "b"}}, Name: `say "hi", bob`, Size: &size, Public: true, Labels: map[string]string{"k": "v"}, Secret: "s", Untagged: 1.5, hidden: "h"},
+		{csvBase: csvBase{ID: 2, CreatedAt: created}, Name: "line\nbreak"},
+	}
+	w := respond(MediaTypeCSV, NewPageResult(rows, 2, 10, 12))
+	if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("content type = %s, want text/csv; charset=utf-8", got)
+	}
+	for key, want := range map[string]string{"X-Page": "2", "X-Page-Size": "10", "X-Total-Count": "12"} {
+		if got := w.Header().Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	want := "id,created_at,tags,name,size,public,labels,Untagged\n" +
+		`1,2025-09-01T08:00:00Z,"[""a"",""b""]","say ""hi"", bob",42,true,"{""k"":""v""}",1.5` + "\n" +
+		"2,2025-09-01T08:00:00Z,,\"line\nbreak\",,false,,0\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+
+	// 非结构体列表输出单列 value
+	n := 7
+	w = respond(MediaTypeCSV, NewPageResult([]*int{&n, nil}, 1, 10, 2))
+	if got := w.Body.String(); got != "value\n7\n\n" {
+		t.Errorf("body = %q, want a value column", got)
+	}
+}
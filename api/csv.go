@@ -0,0 +1,135 @@
+/*
+Copyright © 2025 lixw
+*/
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// tabular 可编码为 CSV 的列表数据
+type tabular interface {
+	csv() ([]byte, error)
+	pagination() (page, pageSize, total int)
+}
+
+func (p *PageResult[T]) csv() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	columns := csvColumns(reflect.TypeFor[T]())
+	header := make([]string, 0, len(columns))
+	for _, c := range columns {
+		header = append(header, c.name)
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, item := range p.List {
+		v := reflect.ValueOf(&item).Elem()
+		record := make([]string, 0, len(columns))
+		for _, c := range columns {
+			cell, err := csvCell(v, c.index)
+			if err != nil {
+				return nil, err
+			}
+			record = append(record, cell)
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (p *PageResult[T]) pagination() (int, int, int) {
+	return p.Page, p.PageSize, p.Total
+}
+
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// csvColumns 按 json 标签展开结构体字段作为列，匿名嵌入的结构体字段提升到上层，非结构体列表输出单列 value
+func csvColumns(t reflect.Type) []csvColumn {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return []csvColumn{{name: "value"}}
+	}
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, c := range csvColumns(ft) {
+				columns = append(columns, csvColumn{name: c.name, index: append([]int{i}, c.index...)})
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		columns = append(columns, csvColumn{name: name, index: []int{i}})
+	}
+	return columns
+}
+
+// csvCell 基本类型直接格式化，其他类型使用 JSON 编码，nil 输出空值
+func csvCell(v reflect.Value, index []int) (string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if len(index) > 0 {
+		field, err := v.FieldByIndexErr(index)
+		if err != nil {
+			// 嵌入的结构体指针为 nil
+			return "", nil
+		}
+		return csvCell(field, nil)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), nil
+	}
+	buf, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	if string(buf) == "null" {
+		return "", nil
+	}
+	var s string
+	if json.Unmarshal(buf, &s) == nil {
+		return s, nil
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,39 @@
+/*
+Copyright © 2025 lixw
+*/
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/gin-gonic/gin"
+)
+
+// ETag 将乐观锁版本号格式化为强 ETag，如 "3"
+func ETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// SetETag 在响应头中写入资源当前版本号
+func SetETag(ctx *gin.Context, version uint) {
+	ctx.Header("ETag", ETag(version))
+}
+
+// IfMatch 解析 If-Match 请求头中的版本号，未携带或为 * 时返回 0（不校验版本）；
+// 弱 ETag 和非本服务生成的 ETag 不可能与当前版本一致，按版本冲突处理
+func IfMatch(ctx *gin.Context) (uint, error) {
+	value := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+	if strings.Contains(value, ",") {
+		return 0, errorx.New(errorx.ErrCodeBadRequest, "If-Match must contain a single etag")
+	}
+	version, err := strconv.ParseUint(strings.Trim(value, `"`), 10, 64)
+	if err != nil || version == 0 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return 0, errorx.New(errorx.ErrCodeConflict, "etag does not match the current version")
+	}
+	return uint(version), nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2025 lixw
+*/
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	MediaTypeProblemJSON = "application/problem+json"
+	// ContextKeyProblemDetails 启用 RFC 9457 错误响应的路由组在上下文中设置，值为问题类型 URI 前缀
+	ContextKeyProblemDetails = "problemDetails"
+)
+
+// Problem RFC 9457 问题详情，code 和 trace_id 为扩展字段
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     int    `json:"code"`
+	TraceID  string `json:"trace_id,omitempty"`
+}
+
+// UseProblemDetails 当前请求的错误响应使用真实HTTP状态码和 application/problem+json，
+// typeBaseURI 为空时问题类型为 about:blank，否则为 {typeBaseURI}/{错误码}
+func UseProblemDetails(ctx *gin.Context, typeBaseURI string) {
+	ctx.Set(ContextKeyProblemDetails, typeBaseURI)
+}
+
+// ProblemDetailsEnabled 判断当前请求是否使用 RFC 9457 错误响应
+func ProblemDetailsEnabled(ctx *gin.Context) bool {
+	_, ok := ctx.Get(ContextKeyProblemDetails)
+	return ok
+}
+
+func newProblem(ctx *gin.Context, status, code int, detail string) *Problem {
+	problemType := "about:blank"
+	if base := ctx.GetString(ContextKeyProblemDetails); base != "" {
+		problemType = strings.TrimSuffix(base, "/") + "/" + strconv.Itoa(code)
+	}
+	return &Problem{
+		Type:     problemType,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: ctx.Request.URL.Path,
+		Code:     code,
+		TraceID:  ctx.GetString(logging.ContextKeyTraceID),
+	}
+}
+
+func writeProblem(ctx *gin.Context, status, code int, detail string) {
+	buf, err := json.Marshal(newProblem(ctx, status, code, detail))
+	if err != nil {
+		_ = ctx.Error(err)
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+	ctx.Data(status, MediaTypeProblemJSON, buf)
+}
@@ -0,0 +1,21 @@
+/*
+Copyright © 2025 lixw
+*/
+package v1
+
+import "github.com/ethanli-dev/go-app-layout/api"
+
+// AuditListRequest audit log query, empty conditions are ignored
+type AuditListRequest struct {
+	api.PageRequest
+	// entity table name, e.g. tenant, file
+	Entity string `json:"entity" form:"entity"`
+	// entity primary key
+	EntityID string `json:"entity_id" form:"entity_id"`
+	// tenant id, also selects the tenant's own database when it has one
+	Tenant uint `json:"tenant" form:"tenant"`
+	// actor, e.g. admin, tenant:1, anonymous
+	Actor string `json:"actor" form:"actor"`
+	// action: create, update or delete
+	Action string `json:"action" form:"action"`
+}
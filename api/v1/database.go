@@ -0,0 +1,10 @@
+/*
+Copyright © 2025 lixw
+*/
+package v1
+
+// SlowQueryListRequest slow query report query
+type SlowQueryListRequest struct {
+	// number of fingerprints to return, ordered by total time, 0 returns all
+	Limit int `json:"limit" form:"limit" binding:"min=0"`
+}
@@ -0,0 +1,30 @@
+/*
+Copyright © 2025 lixw
+*/
+package v1
+
+import "github.com/ethanli-dev/go-app-layout/internal/model"
+
+// UploadRequest create chunked upload request
+type UploadRequest struct {
+	// file name
+	Name string `json:"name"`
+	// total file size in bytes
+	Size int64 `json:"size"`
+}
+
+// UploadStatusResponse chunked upload status
+type UploadStatusResponse struct {
+	*model.UploadSession
+	// indexes of chunks already uploaded
+	Received []int `json:"received"`
+}
+
+// FileResponse file metadata with a signed download url
+type FileResponse struct {
+	*model.File
+	// signed download url
+	DownloadURL string `json:"download_url"`
+	// download url expiry, unix seconds
+	ExpiresAt int64 `json:"expires_at"`
+}
@@ -10,3 +10,22 @@ type TenantRequest struct {
 	// tenant description
 	Description string `json:"description"`
 }
+
+// TenantUpdateRequest tenant update request, omitted fields are left unchanged
+type TenantUpdateRequest struct {
+	// tenant name
+	Name *string `json:"name"`
+	// tenant description
+	Description *string `json:"description"`
+}
+
+// TopicTenantCreated outbox topic published after a tenant is created
+const TopicTenantCreated = "tenant.created"
+
+// TenantCreatedEvent payload of the tenant.created event
+type TenantCreatedEvent struct {
+	// tenant id
+	ID uint `json:"id"`
+	// tenant name
+	Name string `json:"name"`
+}
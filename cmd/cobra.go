@@ -9,6 +9,7 @@ import (
 
 	"github.com/ethanli-dev/go-app-layout/buildinfo"
 	"github.com/ethanli-dev/go-app-layout/cmd/migrate"
+	"github.com/ethanli-dev/go-app-layout/cmd/seed"
 	"github.com/ethanli-dev/go-app-layout/cmd/server"
 	"github.com/spf13/cobra"
 )
@@ -50,4 +51,5 @@ func Execute() {
 func init() {
 	rootCmd.AddCommand(server.StartCmd)
 	rootCmd.AddCommand(migrate.StartCmd)
+	rootCmd.AddCommand(seed.StartCmd)
 }
@@ -5,38 +5,210 @@ package migrate
 
 import (
 	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
 
-	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/internal/migration"
 	"github.com/ethanli-dev/go-app-layout/pkg/config"
 	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
 )
 
 // StartCmd represents the server command
 var (
-	configYml string
-	StartCmd  = &cobra.Command{
+	configYml  string
+	upSteps    int
+	downSteps  int
+	exitCode   bool
+	allTenants bool
+	StartCmd   = &cobra.Command{
 		Use:     "migrate",
 		Aliases: []string{"migrate"},
 		Short:   "Run database migration",
 		Example: "go-app-layout migrate -c config/dev.yml",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cmd.Println(fmt.Sprintf("starting migrate with config: %s", configYml))
-			cfg, err := config.New(configYml)
+			return runUp(cmd, 0)
+		},
+	}
+	upCmd = &cobra.Command{
+		Use:     "up",
+		Short:   "Apply pending migrations",
+		Example: "go-app-layout migrate up -n 1 -c config/dev.yml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUp(cmd, upSteps)
+		},
+	}
+	downCmd = &cobra.Command{
+		Use:     "down",
+		Short:   "Roll back the most recent migrations",
+		Example: "go-app-layout migrate down -n 1 -c config/dev.yml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, func(m *migrate.Migrator) ([]*migrate.Migration, error) {
+				return m.Down(cmd.Context(), downSteps)
+			})
+		},
+	}
+	redoCmd = &cobra.Command{
+		Use:     "redo",
+		Short:   "Roll back and re-apply the most recent migration",
+		Example: "go-app-layout migrate redo -c config/dev.yml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, func(m *migrate.Migrator) ([]*migrate.Migration, error) {
+				return m.Redo(cmd.Context())
+			})
+		},
+	}
+	toCmd = &cobra.Command{
+		Use:     "to <version>",
+		Short:   "Migrate up or down to the given version, 0 rolls back all",
+		Example: "go-app-layout migrate to 20250101000000 -c config/dev.yml",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.ParseInt(args[0], 10, 64)
 			if err != nil {
-				return err
+				return fmt.Errorf("invalid version %s: %w", args[0], err)
 			}
-			db, err := database.New(database.WithUrl(cfg.Database.Url))
+			return run(cmd, func(m *migrate.Migrator) ([]*migrate.Migration, error) {
+				return m.To(cmd.Context(), version)
+			})
+		},
+	}
+	statusCmd = &cobra.Command{
+		Use:     "status",
+		Short:   "Show migration status",
+		Example: "go-app-layout migrate status -c config/dev.yml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eachTarget(cmd, func(m *migrate.Migrator, db *gorm.DB) error {
+				statuses, err := m.Status(cmd.Context())
+				if err != nil {
+					return err
+				}
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+				for _, s := range statuses {
+					appliedAt := "-"
+					if s.AppliedAt != nil {
+						appliedAt = s.AppliedAt.Format(time.DateTime)
+					}
+					_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, appliedAt)
+				}
+				return w.Flush()
+			})
+		},
+	}
+	planCmd = &cobra.Command{
+		Use:     "plan",
+		Short:   "Print the DDL needed to bring the schema in line with the models without executing it",
+		Example: "go-app-layout migrate plan --exit-code -c config/prod.yml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var statements, warnings int
+			err := eachTarget(cmd, func(m *migrate.Migrator, db *gorm.DB) error {
+				plan, err := migrate.Diff(cmd.Context(), db, migration.Models()...)
+				if err != nil {
+					return err
+				}
+				if !plan.Drift() {
+					cmd.Println("schema is up to date")
+					return nil
+				}
+				statements += len(plan.Statements)
+				warnings += len(plan.Warnings)
+				out := cmd.OutOrStdout()
+				for _, stmt := range plan.Statements {
+					_, _ = fmt.Fprintf(out, "%s;\n", stmt)
+				}
+				if plan.Destructive() {
+					_, _ = fmt.Fprintln(out, "\nWARNING: destructive changes detected:")
+					for _, warning := range plan.Warnings {
+						_, _ = fmt.Fprintf(out, "  - %s\n", warning)
+					}
+				}
+				return nil
+			})
 			if err != nil {
 				return err
 			}
-			return db.AutoMigrate(
-				&model.Tenant{},
-			)
+			if exitCode && statements+warnings > 0 {
+				return fmt.Errorf("schema drift detected: %d statements, %d destructive changes", statements, warnings)
+			}
+			return nil
 		},
 	}
 )
 
 func init() {
 	StartCmd.PersistentFlags().StringVarP(&configYml, "config", "c", "config/dev.yml", "Start server with configuration file")
+	StartCmd.PersistentFlags().BoolVar(&allTenants, "all-tenants", false, "Also run against every tenant with a dedicated database or schema")
+	upCmd.Flags().IntVarP(&upSteps, "steps", "n", 0, "Number of migrations to apply, 0 applies all")
+	downCmd.Flags().IntVarP(&downSteps, "steps", "n", 1, "Number of migrations to roll back")
+	planCmd.Flags().BoolVar(&exitCode, "exit-code", false, "Exit with non-zero status when drift is detected, for CI")
+	StartCmd.AddCommand(upCmd, downCmd, redoCmd, toCmd, statusCmd, planCmd)
+}
+
+// eachTarget 在共享库上执行 fn，指定 --all-tenants 时再依次在每个独立存储的租户库上执行
+func eachTarget(cmd *cobra.Command, fn func(m *migrate.Migrator, db *gorm.DB) error) error {
+	cmd.Println(fmt.Sprintf("starting migrate with config: %s", configYml))
+	cfg, err := config.New(configYml)
+	if err != nil {
+		return err
+	}
+	opts := []database.Option{database.WithUrl(cfg.Database.Url), database.WithDriver(cfg.Database.Driver)}
+	if tr := cfg.Database.TenantRouting; allTenants && tr != nil && len(tr.Tenants) > 0 {
+		tenants := make(database.StaticTenants, len(tr.Tenants))
+		for _, t := range tr.Tenants {
+			tenants[t.ID] = database.TenantTarget{URL: t.Url, Schema: t.Schema}
+		}
+		opts = append(opts, database.WithTenantSource(tenants))
+	}
+	db, err := database.New(opts...)
+	if err != nil {
+		return err
+	}
+	exec := func(target *gorm.DB) error {
+		m, err := migration.New(target)
+		if err != nil {
+			return err
+		}
+		return fn(m, target)
+	}
+	if allTenants {
+		cmd.Println("== shared ==")
+	}
+	if err := exec(db); err != nil || !allTenants {
+		return err
+	}
+	return database.EachTenant(cmd.Context(), db, func(tenantID uint, tdb *gorm.DB) error {
+		cmd.Println(fmt.Sprintf("== tenant %d ==", tenantID))
+		return exec(tdb)
+	})
+}
+
+func runUp(cmd *cobra.Command, steps int) error {
+	return run(cmd, func(m *migrate.Migrator) ([]*migrate.Migration, error) {
+		return m.Up(cmd.Context(), steps)
+	})
+}
+
+func run(cmd *cobra.Command, fn func(m *migrate.Migrator) ([]*migrate.Migration, error)) error {
+	return eachTarget(cmd, func(m *migrate.Migrator, db *gorm.DB) error {
+		done, err := fn(m)
+		for _, mg := range done {
+			cmd.Println(fmt.Sprintf("migrated %d_%s", mg.Version, mg.Name))
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			cmd.Println("no migrations to run")
+		}
+		return nil
+	})
 }
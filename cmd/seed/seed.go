@@ -0,0 +1,96 @@
+/*
+Copyright © 2025 lixw
+*/
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethanli-dev/go-app-layout/internal/seed"
+	"github.com/ethanli-dev/go-app-layout/pkg/config"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/encrypt"
+	pkgseed "github.com/ethanli-dev/go-app-layout/pkg/seed"
+	"github.com/spf13/cobra"
+)
+
+// StartCmd represents the seed command
+var (
+	configYml string
+	env       string
+	dir       string
+	truncate  bool
+	StartCmd  = &cobra.Command{
+		Use:   "seed",
+		Short: "Load fixtures into the database",
+		Example: `go-app-layout seed -c config/dev.yml
+go-app-layout seed --env test --truncate -c config/dev.yml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.Println(fmt.Sprintf("starting seed with config: %s", configYml))
+			cfg, err := config.New(configYml)
+			if err != nil {
+				return err
+			}
+			dbOpts := []database.Option{database.WithUrl(cfg.Database.Url), database.WithDriver(cfg.Database.Driver)}
+			// 夹具中的加密字段按配置的主密钥加密写入
+			if ec := cfg.Database.Encryption; ec != nil {
+				keys := make(map[uint32]string, len(ec.Keys))
+				for _, k := range ec.Keys {
+					keys[k.Version] = k.Key
+				}
+				keyring, err := encrypt.NewKeyring(ec.Primary, keys, ec.BlindIndexKey)
+				if err != nil {
+					return err
+				}
+				dbOpts = append(dbOpts, database.WithPlugins(encrypt.New(keyring)))
+			}
+			db, err := database.New(dbOpts...)
+			if err != nil {
+				return err
+			}
+			var opts []pkgseed.Option
+			if truncate {
+				opts = append(opts, pkgseed.WithTruncate())
+			}
+			seeder, err := seed.New(db, opts...)
+			if err != nil {
+				return err
+			}
+
+			// 未指定目录时加载内置的环境夹具，环境默认取配置文件名，如 config/dev.yml 为 dev
+			var fsys fs.FS = seed.FS
+			fixtureDir := path.Join(seed.Dir, env)
+			if env == "" {
+				fixtureDir = path.Join(seed.Dir, strings.TrimSuffix(filepath.Base(configYml), filepath.Ext(configYml)))
+			}
+			if dir != "" {
+				fsys, fixtureDir = os.DirFS(dir), "."
+			}
+			results, err := seeder.Load(cmd.Context(), fsys, fixtureDir)
+			if errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("no fixtures in %s: %w", fixtureDir, err)
+			}
+			if err != nil {
+				return err
+			}
+			for _, r := range results {
+				cmd.Println(fmt.Sprintf("%s: %d created, %d updated", r.Table, r.Created, r.Updated))
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	StartCmd.PersistentFlags().StringVarP(&configYml, "config", "c", "config/dev.yml", "Start server with configuration file")
+	StartCmd.Flags().StringVar(&env, "env", "", "Fixture environment, defaults to the config file name")
+	StartCmd.Flags().StringVar(&dir, "dir", "", "Load fixtures from this directory instead of the built-in ones")
+	StartCmd.Flags().BoolVar(&truncate, "truncate", false, "Empty the fixture tables before loading, for test databases only")
+}
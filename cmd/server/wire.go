@@ -7,24 +7,37 @@ Copyright © 2025 lixw
 package server
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
 	"github.com/ethanli-dev/go-app-layout/buildinfo"
 	"github.com/ethanli-dev/go-app-layout/internal/handler"
+	"github.com/ethanli-dev/go-app-layout/internal/model"
 	"github.com/ethanli-dev/go-app-layout/internal/repository"
 	"github.com/ethanli-dev/go-app-layout/internal/server"
 	"github.com/ethanli-dev/go-app-layout/internal/service"
 	"github.com/ethanli-dev/go-app-layout/locales"
 	"github.com/ethanli-dev/go-app-layout/pkg/app"
+	"github.com/ethanli-dev/go-app-layout/pkg/cache"
 	"github.com/ethanli-dev/go-app-layout/pkg/config"
 	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/encrypt"
 	"github.com/ethanli-dev/go-app-layout/pkg/i18n"
 	"github.com/ethanli-dev/go-app-layout/pkg/logging"
+	"github.com/ethanli-dev/go-app-layout/pkg/outbox"
+	"github.com/ethanli-dev/go-app-layout/pkg/storage"
 	"github.com/ethanli-dev/go-app-layout/pkg/web"
+	"github.com/ethanli-dev/go-app-layout/pkg/web/middleware"
 	"github.com/google/wire"
 	"gorm.io/gorm"
 )
 
-func createServer(cfg *config.Config, db *gorm.DB) (*server.Server, error) {
-	panic(wire.Build(server.New, repository.ProviderSet, service.ProviderSet, handler.ProviderSet))
+func createServer(cfg *config.Config, db *gorm.DB, store storage.Storage) (*server.Server, error) {
+	panic(wire.Build(server.New, database.NewTxManager, outbox.NewWriter, repository.ProviderSet, service.ProviderSet, handler.ProviderSet))
 }
 
 func CreateApp(configPath string) (*app.App, error) {
@@ -57,36 +70,110 @@ func CreateApp(configPath string) (*app.App, error) {
 		return nil, err
 	}
 	var dbOpts []database.Option
+	var keyring *encrypt.Keyring
 	if cfg.Database != nil {
 		dbOpts = []database.Option{
 			database.WithUrl(cfg.Database.Url),
+			database.WithDriver(cfg.Database.Driver),
 			database.WithConnMaxIdleTime(cfg.Database.ConnMaxIdleTime),
 			database.WithConnMaxLifeTime(cfg.Database.ConnMaxLifeTime),
 			database.WithMaxIdleConns(cfg.Database.MaxIdleConns),
 			database.WithMaxOpenConns(cfg.Database.MaxOpenConns),
 			database.WithSlowThreshold(cfg.Database.SlowThreshold),
+			database.WithPlugins(repository.Plugins()...),
+		}
+		if rc := cfg.Database.Replica; rc != nil && len(rc.Urls) > 0 {
+			policy, err := database.ParsePolicy(rc.Policy)
+			if err != nil {
+				return nil, err
+			}
+			dbOpts = append(dbOpts,
+				database.WithReplicas(rc.Urls...),
+				database.WithReplicaPolicy(policy),
+				database.WithReplicaHealthCheck(rc.CheckInterval, rc.MaxLag),
+			)
+		}
+		if sl := cfg.Database.SlowLog; sl != nil {
+			dbOpts = append(dbOpts, database.WithSlowLog(sl.MaxFingerprints, sl.ExplainInterval))
+		}
+		if ec := cfg.Database.Encryption; ec != nil {
+			keyring, err = newKeyring(ec)
+			if err != nil {
+				return nil, err
+			}
+			dbOpts = append(dbOpts, database.WithPlugins(encrypt.New(keyring)))
+		}
+		if cc := cfg.Database.Cache; cc != nil && cc.Enabled {
+			dbOpts = append(dbOpts, database.WithPlugins(cache.New(cache.NewMemory(cc.MaxEntries), cache.WithTTL(cc.TTL))))
+		}
+		if tr := cfg.Database.TenantRouting; tr != nil && len(tr.Tenants) > 0 {
+			dbOpts = append(dbOpts,
+				database.WithTenantSource(newTenantSource(tr)),
+				database.WithTenantPool(tr.MaxOpen, tr.IdleTimeout),
+			)
 		}
 	}
 	db, err := database.New(dbOpts...)
 	if err != nil {
 		return nil, err
 	}
-	appServer, err := createServer(cfg, db)
+	var storageOpts []storage.Option
+	if cfg.Storage != nil {
+		storageOpts = []storage.Option{
+			storage.WithDriver(cfg.Storage.Driver),
+		}
+		if cfg.Storage.Local != nil {
+			storageOpts = append(storageOpts, storage.WithLocalRoot(cfg.Storage.Local.Root))
+		}
+		if s3 := cfg.Storage.S3; s3 != nil {
+			storageOpts = append(storageOpts,
+				storage.WithS3(s3.Endpoint, s3.Region, s3.Bucket, s3.AccessKey, s3.SecretKey),
+				storage.WithS3PathStyle(s3.PathStyle),
+			)
+		}
+	}
+	store, err := storage.New(storageOpts...)
+	if err != nil {
+		return nil, err
+	}
+	appServer, err := createServer(cfg, db, store)
 	if err != nil {
 		return nil, err
 	}
 	var webOpts []web.Option
 	if cfg.Server != nil {
+		maintenance, err := newMaintenanceSwitch(cfg.Server.Maintenance)
+		if err != nil {
+			return nil, err
+		}
 		webOpts = []web.Option{
 			web.WithAddress(cfg.Server.Addr),
 			web.WithBasePath(cfg.Server.BasePath),
+			web.WithDefaultVersion(cfg.Server.DefaultVersion),
 			web.WithReadTimeout(cfg.Server.ReadTimeout),
 			web.WithWriteTimeout(cfg.Server.WriteTimeout),
 			web.WithIdleTimeout(cfg.Server.IdleTimeout),
 			web.WithMaxHeaderBytes(cfg.Server.MaxHeaderBytes),
+			web.WithAdminToken(cfg.Server.AdminToken),
+			web.WithMaintenance(maintenance),
+		}
+		ipOpts, err := newIPOptions(cfg.Server)
+		if err != nil {
+			return nil, err
+		}
+		webOpts = append(webOpts, ipOpts...)
+		webOpts = append(webOpts, newSecurityOptions(cfg.Server.Security)...)
+		redactor, loggerOpts, err := newLoggerOptions(cfg.Server.RequestLog)
+		if err != nil {
+			return nil, err
+		}
+		webOpts = append(webOpts, web.WithLoggerOptions(loggerOpts...))
+		webOpts = append(webOpts, newAccessLogOptions(cfg.Server.AccessLog, redactor)...)
+		if pd := cfg.Server.ProblemDetails; pd != nil && len(pd.Groups) > 0 {
+			webOpts = append(webOpts, web.WithProblemDetails(pd.TypeBaseURI, pd.Groups...))
 		}
 	}
-	webServer := web.New(webOpts...).UseRoutes(appServer.Routes)
+	webServer := web.New(webOpts...).UseVersion(web.NewVersion("v1"), appServer.Routes).UseAdmin(appServer.AdminRoutes)
 	var appOpts []app.Option
 	if cfg.Server != nil {
 		appOpts = []app.Option{
@@ -95,5 +182,244 @@ func CreateApp(configPath string) (*app.App, error) {
 		}
 	}
 
-	return app.New(appOpts...).Use(database.NewService(db), appServer, webServer), nil
+	application := app.New(appOpts...).Use(database.NewService(db), storage.NewService(store), newOutboxRelay(cfg.Outbox, db))
+	if keyring != nil {
+		application.Use(newRotator(cfg.Database.Encryption, db, keyring))
+	}
+	return application.Use(appServer, webServer), nil
+}
+
+// newKeyring 根据配置创建字段加密的密钥环
+func newKeyring(cfg *config.EncryptionConfig) (*encrypt.Keyring, error) {
+	keys := make(map[uint32]string, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		keys[k.Version] = k.Key
+	}
+	return encrypt.NewKeyring(cfg.Primary, keys, cfg.BlindIndexKey)
+}
+
+// newRotator 在后台将租户密钥重新加密为当前主密钥版本
+func newRotator(cfg *config.EncryptionConfig, db *gorm.DB, keyring *encrypt.Keyring) *encrypt.Rotator {
+	return encrypt.NewRotator(db, keyring,
+		encrypt.WithModels(&model.Tenant{}),
+		encrypt.WithInterval(cfg.RotateInterval),
+		encrypt.WithBatchSize(cfg.RotateBatchSize),
+	)
+}
+
+// newTenantSource 将配置中的物理隔离租户转换为租户路由映射
+func newTenantSource(cfg *config.TenantRoutingConfig) database.StaticTenants {
+	tenants := make(database.StaticTenants, len(cfg.Tenants))
+	for _, t := range cfg.Tenants {
+		tenants[t.ID] = database.TenantTarget{URL: t.Url, Schema: t.Schema}
+	}
+	return tenants
+}
+
+// newOutboxRelay 创建事件投递服务，进程内总线始终启用，webhook 按配置添加
+func newOutboxRelay(cfg *config.OutboxConfig, db *gorm.DB) *outbox.Relay {
+	bus := outbox.NewBus()
+	bus.Subscribe("*", func(ctx context.Context, msg *outbox.Message) error {
+		slog.DebugContext(ctx, "outbox event published", "id", msg.ID, "topic", msg.Topic, "aggregate", msg.Aggregate, "aggregateId", msg.AggregateID)
+		return nil
+	})
+	opts := []outbox.Option{outbox.WithSinks(bus)}
+	if cfg == nil {
+		return outbox.NewRelay(db, opts...)
+	}
+	opts = append(opts,
+		outbox.WithInterval(cfg.Interval),
+		outbox.WithBatchSize(cfg.BatchSize),
+		outbox.WithMaxAttempts(cfg.MaxAttempts),
+		outbox.WithBackoff(cfg.Backoff, cfg.MaxBackoff),
+		outbox.WithLeaseTTL(cfg.LeaseTTL),
+	)
+	for _, wc := range cfg.Webhooks {
+		webhookOpts := []outbox.WebhookOption{outbox.WithWebhookTopics(wc.Topics...), outbox.WithWebhookTimeout(wc.Timeout)}
+		if wc.Secret != "" {
+			webhookOpts = append(webhookOpts, outbox.WithWebhookSigner(wc.KeyID, []byte(wc.Secret)))
+		}
+		opts = append(opts, outbox.WithSinks(outbox.NewWebhook(wc.Url, webhookOpts...)))
+	}
+	return outbox.NewRelay(db, opts...)
+}
+
+// newMaintenanceSwitch 根据配置创建维护模式开关，并在配置文件变更时热更新
+func newMaintenanceSwitch(cfg *config.MaintenanceConfig) (*middleware.MaintenanceSwitch, error) {
+	maintenance := middleware.NewMaintenanceSwitch()
+	if cfg == nil {
+		return maintenance, nil
+	}
+	mode, err := middleware.ParseMaintenanceMode(cfg.Mode)
+	if err != nil {
+		return nil, err
+	}
+	maintenance.SetMode(mode, cfg.RetryAfter)
+	if err := maintenance.SetAllowList(cfg.AllowIPs, cfg.AllowKeys); err != nil {
+		return nil, err
+	}
+	lastMode := mode
+	config.OnChange(func(c *config.Config) {
+		if c.Server == nil || c.Server.Maintenance == nil {
+			return
+		}
+		mode, err := middleware.ParseMaintenanceMode(c.Server.Maintenance.Mode)
+		if err != nil {
+			slog.Error("failed to reload maintenance config", "err", err)
+			return
+		}
+		if err := maintenance.SetAllowList(c.Server.Maintenance.AllowIPs, c.Server.Maintenance.AllowKeys); err != nil {
+			slog.Error("failed to reload maintenance config", "err", err)
+			return
+		}
+		// 仅在配置中的模式变化时切换，避免覆盖通过管理接口设置的模式
+		if mode != lastMode {
+			maintenance.SetMode(mode, c.Server.Maintenance.RetryAfter)
+			lastMode = mode
+			slog.Warn("maintenance mode changed by config", "mode", mode)
+		}
+	})
+	return maintenance, nil
+}
+
+// newIPOptions 根据配置创建可信代理解析器和各路由组的IP黑白名单，并在配置文件变更时热更新
+func newIPOptions(cfg *config.ServerConfig) ([]web.Option, error) {
+	resolver := middleware.NewClientIPResolver()
+	if err := resolver.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
+	if err := checkGroups(cfg.IPFilters); err != nil {
+		return nil, fmt.Errorf("invalid ip filters: %w", err)
+	}
+	opts := []web.Option{web.WithClientIPResolver(resolver)}
+	// 所有路由组都安装过滤器，热更新时可以为未配置的路由组添加名单
+	filters := make(map[string]*middleware.IPFilter)
+	for _, group := range web.Groups() {
+		filter := middleware.NewIPFilter()
+		if fc := cfg.IPFilters[group]; fc != nil {
+			if err := filter.Set(fc.Allow, fc.Deny); err != nil {
+				return nil, fmt.Errorf("invalid ip filter for %s: %w", group, err)
+			}
+		}
+		filters[group] = filter
+		opts = append(opts, web.WithIPFilter(group, filter))
+	}
+	config.OnChange(func(c *config.Config) {
+		if c.Server == nil {
+			return
+		}
+		if err := resolver.SetTrustedProxies(c.Server.TrustedProxies); err != nil {
+			slog.Error("failed to reload trusted proxies", "err", err)
+		}
+		if err := checkGroups(c.Server.IPFilters); err != nil {
+			slog.Error("failed to reload ip filters", "err", err)
+		}
+		for group, filter := range filters {
+			var allow, deny []string
+			if fc := c.Server.IPFilters[group]; fc != nil {
+				allow, deny = fc.Allow, fc.Deny
+			}
+			if err := filter.Set(allow, deny); err != nil {
+				slog.Error("failed to reload ip filter", "group", group, "err", err)
+			}
+		}
+	})
+	return opts, nil
+}
+
+// checkGroups 检查按路由组配置的键均为已知的路由组，避免拼写错误的配置被静默忽略
+func checkGroups[T any](groups map[string]T) error {
+	for group := range groups {
+		if !slices.Contains(web.Groups(), group) {
+			return fmt.Errorf("unknown route group %q, must be one of %s", group, strings.Join(web.Groups(), ", "))
+		}
+	}
+	return nil
+}
+
+// newSecurityOptions 将安全响应头配置转换为各路由组的选项，未配置的项使用默认值
+func newSecurityOptions(cfg *config.SecurityConfig) []web.Option {
+	if cfg == nil {
+		return nil
+	}
+	var common []middleware.SecurityOption
+	if cfg.HSTSMaxAge != nil || cfg.HSTSIncludeSubdomains != nil || cfg.HSTSPreload {
+		maxAge, includeSubdomains := middleware.DefaultHSTSMaxAge, true
+		if cfg.HSTSMaxAge != nil {
+			maxAge = *cfg.HSTSMaxAge
+		}
+		if cfg.HSTSIncludeSubdomains != nil {
+			includeSubdomains = *cfg.HSTSIncludeSubdomains
+		}
+		common = append(common, middleware.WithHSTS(maxAge, includeSubdomains, cfg.HSTSPreload))
+	}
+	if cfg.FrameOptions != "" {
+		common = append(common, middleware.WithFrameOptions(cfg.FrameOptions))
+	}
+	if cfg.ReferrerPolicy != "" {
+		common = append(common, middleware.WithReferrerPolicy(cfg.ReferrerPolicy))
+	}
+	if cfg.PermissionsPolicy != "" {
+		common = append(common, middleware.WithPermissionsPolicy(cfg.PermissionsPolicy))
+	}
+	opts := []web.Option{web.WithSecurity("", common...)}
+	for group, policy := range cfg.CSP {
+		opts = append(opts, web.WithSecurity(group, middleware.WithContentSecurityPolicy(policy)))
+	}
+	return opts
+}
+
+// newLoggerOptions 根据配置创建请求日志的脱敏规则、请求体和响应体记录选项
+func newLoggerOptions(cfg *config.RequestLogConfig) (*middleware.Redactor, []middleware.LoggerOption, error) {
+	if cfg == nil {
+		return middleware.NewRedactor(), nil, nil
+	}
+	var redactOpts []middleware.RedactOption
+	if cfg.Redact != nil {
+		patterns := make([]*regexp.Regexp, 0, len(cfg.Redact.Patterns))
+		for _, p := range cfg.Redact.Patterns {
+			pattern, err := regexp.Compile(p)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+			}
+			patterns = append(patterns, pattern)
+		}
+		redactOpts = []middleware.RedactOption{
+			middleware.WithRedactJSONPaths(cfg.Redact.JSONPaths...),
+			middleware.WithRedactHeaders(cfg.Redact.Headers...),
+			middleware.WithRedactQueryParams(cfg.Redact.QueryParams...),
+			middleware.WithRedactPatterns(patterns...),
+		}
+	}
+	redactor := middleware.NewRedactor(redactOpts...)
+	loggerOpts := []middleware.LoggerOption{
+		middleware.WithRedactor(redactor),
+		middleware.WithLogHeaders(cfg.LogHeaders),
+		middleware.WithSkipBodyRoutes(cfg.SkipBodyRoutes...),
+		middleware.WithResponseCapture(cfg.CaptureResponse),
+		middleware.WithResponseSampleRate(cfg.ResponseSampleRate),
+	}
+	for _, r := range cfg.RouteSampleRates {
+		loggerOpts = append(loggerOpts, middleware.WithRouteSampleRate(r.Route, r.Rate))
+	}
+	return redactor, loggerOpts, nil
+}
+
+// newAccessLogOptions 根据配置创建独立的访问日志
+func newAccessLogOptions(cfg *config.AccessLogConfig, redactor *middleware.Redactor) []web.Option {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	writer := logging.NewWriter(
+		logging.WithPath(cfg.Path),
+		logging.WithMaxAge(cfg.MaxAge),
+		logging.WithMaxSize(cfg.MaxSize),
+		logging.WithMaxBackups(cfg.MaxBackups),
+		logging.WithCompress(cfg.Compress),
+	)
+	return []web.Option{web.WithAccessLog(
+		middleware.WithAccessLogWriter(writer),
+		middleware.WithAccessLogFormat(cfg.Format),
+		middleware.WithAccessLogRedactor(redactor),
+	)}
 }
@@ -0,0 +1,45 @@
+/*
+Copyright © 2025 lixw
+*/
+package handler
+
+import (
+	"github.com/ethanli-dev/go-app-layout/api"
+	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
+	"github.com/ethanli-dev/go-app-layout/internal/service"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	auditSrv *service.AuditService
+}
+
+func NewAuditHandler(auditSrv *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditSrv: auditSrv,
+	}
+}
+
+// List audit log
+// @Summary Browse the change history of entities (admin)
+// @Description Newest first. Sensitive fields are recorded as *** and only show that they changed
+// @Tags audit
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param req query v1.AuditListRequest false "Audit log query"
+// @Success 200 {object} api.Response[api.PageResult[audit.Event]] "Audit log page"
+// @Router /admin/audit [get]
+func (ah *AuditHandler) List(ctx *gin.Context) {
+	var req v1.AuditListRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "failed to parse request parameters"))
+		return
+	}
+	page, err := ah.auditSrv.List(ctx, &req)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	api.SuccessWithData(ctx, page)
+}
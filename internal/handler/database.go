@@ -0,0 +1,52 @@
+/*
+Copyright © 2025 lixw
+*/
+package handler
+
+import (
+	"github.com/ethanli-dev/go-app-layout/api"
+	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
+	"github.com/ethanli-dev/go-app-layout/internal/service"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/gin-gonic/gin"
+)
+
+type SlowQueryHandler struct {
+	slowQuerySrv *service.SlowQueryService
+}
+
+func NewSlowQueryHandler(slowQuerySrv *service.SlowQueryService) *SlowQueryHandler {
+	return &SlowQueryHandler{
+		slowQuerySrv: slowQuerySrv,
+	}
+}
+
+// List slow queries
+// @Summary Slow query report (admin)
+// @Description Statements slower than database.slowThreshold grouped by fingerprint (literals replaced with ?), ordered by total time. SELECT statements carry the most recent EXPLAIN plan, captured in the background
+// @Tags database
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param req query v1.SlowQueryListRequest false "Slow query report query"
+// @Success 200 {object} api.Response[[]database.SlowQuery] "Slow queries"
+// @Router /admin/database/slow-queries [get]
+func (sh *SlowQueryHandler) List(ctx *gin.Context) {
+	var req v1.SlowQueryListRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "failed to parse request parameters"))
+		return
+	}
+	api.SuccessWithData(ctx, sh.slowQuerySrv.List(ctx, &req))
+}
+
+// Reset slow queries
+// @Summary Clear the slow query report (admin)
+// @Tags database
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Success 200 {object} api.Response[any] "Cleared"
+// @Router /admin/database/slow-queries [delete]
+func (sh *SlowQueryHandler) Reset(ctx *gin.Context) {
+	sh.slowQuerySrv.Reset(ctx)
+	api.Success(ctx)
+}
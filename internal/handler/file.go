@@ -0,0 +1,283 @@
+/*
+Copyright © 2025 lixw
+*/
+package handler
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ethanli-dev/go-app-layout/api"
+	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
+	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/internal/service"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead 预留给 multipart 边界和表单字段的请求体大小
+const multipartOverhead = 1 << 20
+
+type FileHandler struct {
+	fileSrv *service.FileService
+}
+
+func NewFileHandler(fileSrv *service.FileService) *FileHandler {
+	return &FileHandler{
+		fileSrv: fileSrv,
+	}
+}
+
+// Upload file
+// @Summary Upload a file
+// @Description Requires HMAC-SHA256 request signature headers, sign large files with X-Content-Sha256
+// @Tags file
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "File to upload"
+// @Success 200 {object} api.Response[v1.FileResponse] "File response"
+// @Router /files [post]
+func (fh *FileHandler) Upload(ctx *gin.Context) {
+	tenantID, err := signedTenantID(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, fh.fileSrv.MaxFileSize()+multipartOverhead)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		api.Failure(ctx, errorx.Wrap(err, errorx.ErrCodeBadRequest, "failed to parse uploaded file"))
+		return
+	}
+	// multipart 解析到结束边界即停止，读完剩余请求体以触发签名哈希校验
+	if _, err := io.Copy(io.Discard, ctx.Request.Body); err != nil {
+		api.Failure(ctx, errorx.Wrap(err, errorx.ErrCodeBadRequest, "failed to read request body"))
+		return
+	}
+	f, err := header.Open()
+	if err != nil {
+		api.Failure(ctx, errorx.Wrap(err, errorx.ErrCodeBadRequest, "failed to open uploaded file"))
+		return
+	}
+	defer func() { _ = f.Close() }()
+	file, err := fh.fileSrv.Upload(ctx, tenantID, header.Filename, f, header.Size)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	api.SuccessWithData(ctx, fh.fileResponse(ctx, file))
+}
+
+// Get file
+// @Summary Get file metadata and a signed download url
+// @Tags file
+// @Produce json
+// @Param id path int true "File id"
+// @Success 200 {object} api.Response[v1.FileResponse] "File response"
+// @Router /files/{id} [get]
+func (fh *FileHandler) Get(ctx *gin.Context) {
+	tenantID, err := signedTenantID(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "invalid file id"))
+		return
+	}
+	file, err := fh.fileSrv.Get(ctx, tenantID, uint(id))
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	api.SuccessWithData(ctx, fh.fileResponse(ctx, file))
+}
+
+// Delete file
+// @Summary Delete a file
+// @Tags file
+// @Produce json
+// @Param id path int true "File id"
+// @Success 200 {object} api.Response[any] "Delete file response"
+// @Router /files/{id} [delete]
+func (fh *FileHandler) Delete(ctx *gin.Context) {
+	tenantID, err := signedTenantID(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "invalid file id"))
+		return
+	}
+	if err := fh.fileSrv.Delete(ctx, tenantID, uint(id)); err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	api.Success(ctx)
+}
+
+// Download file
+// @Summary Download a file with a signed url
+// @Tags file
+// @Produce octet-stream
+// @Param id path int true "File id"
+// @Param tenant query int true "Tenant id"
+// @Param expires query string true "Expiry, unix seconds"
+// @Param signature query string true "Url signature"
+// @Success 200 {file} file "File content"
+// @Router /files/download/{id} [get]
+func (fh *FileHandler) Download(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "invalid file id"))
+		return
+	}
+	// 租户ID参与签名，无效时签名校验失败
+	tenantID, _ := strconv.ParseUint(ctx.Query("tenant"), 10, 64)
+	file, rc, err := fh.fileSrv.Open(ctx, uint(tenantID), uint(id), ctx.Query("expires"), ctx.Query("signature"))
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	defer func() { _ = rc.Close() }()
+	ctx.DataFromReader(http.StatusOK, file.Size, file.ContentType, rc, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}),
+	})
+}
+
+// CreateUpload chunked upload
+// @Summary Create a chunked upload
+// @Tags file
+// @Accept json
+// @Produce json
+// @Param req body v1.UploadRequest true "Create upload request"
+// @Success 200 {object} api.Response[model.UploadSession] "Upload session response"
+// @Router /files/uploads [post]
+func (fh *FileHandler) CreateUpload(ctx *gin.Context) {
+	tenantID, err := signedTenantID(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	var req v1.UploadRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "failed to parse request parameters"))
+		return
+	}
+	session, err := fh.fileSrv.CreateUpload(ctx, tenantID, &req)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	api.SuccessWithData(ctx, session)
+}
+
+// UploadChunk chunked upload
+// @Summary Upload a chunk, re-uploading a chunk overwrites it
+// @Tags file
+// @Accept octet-stream
+// @Produce json
+// @Param uploadId path string true "Upload id"
+// @Param index path int true "Chunk index, starting from 0"
+// @Success 200 {object} api.Response[any] "Upload chunk response"
+// @Router /files/uploads/{uploadId}/chunks/{index} [put]
+func (fh *FileHandler) UploadChunk(ctx *gin.Context) {
+	tenantID, err := signedTenantID(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	index, err := strconv.Atoi(ctx.Param("index"))
+	if err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "invalid chunk index"))
+		return
+	}
+	if err := fh.fileSrv.UploadChunk(ctx, tenantID, ctx.Param("uploadId"), index, ctx.Request.Body); err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	api.Success(ctx)
+}
+
+// UploadStatus chunked upload
+// @Summary Get uploaded chunks of a chunked upload
+// @Tags file
+// @Produce json
+// @Param uploadId path string true "Upload id"
+// @Success 200 {object} api.Response[v1.UploadStatusResponse] "Upload status response"
+// @Router /files/uploads/{uploadId} [get]
+func (fh *FileHandler) UploadStatus(ctx *gin.Context) {
+	tenantID, err := signedTenantID(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	status, err := fh.fileSrv.UploadStatus(ctx, tenantID, ctx.Param("uploadId"))
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	api.SuccessWithData(ctx, status)
+}
+
+// CompleteUpload chunked upload
+// @Summary Complete a chunked upload
+// @Tags file
+// @Produce json
+// @Param uploadId path string true "Upload id"
+// @Success 200 {object} api.Response[v1.FileResponse] "File response"
+// @Router /files/uploads/{uploadId}/complete [post]
+func (fh *FileHandler) CompleteUpload(ctx *gin.Context) {
+	tenantID, err := signedTenantID(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	file, err := fh.fileSrv.CompleteUpload(ctx, tenantID, ctx.Param("uploadId"))
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	api.SuccessWithData(ctx, fh.fileResponse(ctx, file))
+}
+
+// AbortUpload chunked upload
+// @Summary Abort a chunked upload
+// @Tags file
+// @Produce json
+// @Param uploadId path string true "Upload id"
+// @Success 200 {object} api.Response[any] "Abort upload response"
+// @Router /files/uploads/{uploadId} [delete]
+func (fh *FileHandler) AbortUpload(ctx *gin.Context) {
+	tenantID, err := signedTenantID(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	if err := fh.fileSrv.AbortUpload(ctx, tenantID, ctx.Param("uploadId")); err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	api.Success(ctx)
+}
+
+// fileResponse 生成文件的签名下载链接，链接与当前请求位于同一 API 版本下
+func (fh *FileHandler) fileResponse(ctx *gin.Context, file *model.File) *v1.FileResponse {
+	expires, sig := fh.fileSrv.DownloadURL(file)
+	expiresAt, _ := strconv.ParseInt(expires, 10, 64)
+	// 路由模板形如 /v1/files/:id，取 /files 之前的部分作为下载链接前缀，不在文件路由下时使用根路径
+	prefix, _, ok := strings.Cut(ctx.FullPath(), "/files")
+	if !ok {
+		prefix = "/"
+	}
+	downloadURL := path.Join(prefix, "files", "download", strconv.FormatUint(uint64(file.ID), 10)) +
+		"?tenant=" + strconv.FormatUint(uint64(file.TenantID), 10) + "&expires=" + expires + "&signature=" + sig
+	return &v1.FileResponse{File: file, DownloadURL: downloadURL, ExpiresAt: expiresAt}
+}
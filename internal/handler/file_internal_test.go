@@ -0,0 +1,46 @@
+/*
+Copyright © 2025 lixw
+*/
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/internal/service"
+	"github.com/ethanli-dev/go-app-layout/pkg/config"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/gin-gonic/gin"
+)
+
+// TestFileResponsePrefix 路由模板中没有 /files 时（如未匹配路由）不应 panic
+func TestFileResponsePrefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fh := NewFileHandler(service.NewFileService(&config.Config{Storage: &config.StorageConfig{URLSecret: "secret"}}, nil, nil, nil))
+	file := &model.File{Model: database.Model{ID: 7}, TenantModel: database.TenantModel{TenantID: 3}}
+	for _, tt := range []struct {
+		route string
+		want  string
+	}{
+		{"/v1/files/:id", "/v1/files/download/7?tenant=3&"},
+		{"/api/v2/files/uploads/:uploadId/complete", "/api/v2/files/download/7?tenant=3&"},
+		{"", "/files/download/7?tenant=3&"},
+		{"/v1/tenant/info", "/files/download/7?tenant=3&"},
+	} {
+		engine := gin.New()
+		var got string
+		handle := func(ctx *gin.Context) { got = fh.fileResponse(ctx, file).DownloadURL }
+		if tt.route == "" {
+			engine.NoRoute(handle)
+			engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+		} else {
+			engine.GET(tt.route, handle)
+			engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", strings.NewReplacer(":id", "7", ":uploadId", "u").Replace(tt.route), nil))
+		}
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("route %q: download url = %q, want prefix %q", tt.route, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright © 2025 lixw
+*/
+package handler_test
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
+	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/internal/webtest"
+	"github.com/ethanli-dev/go-app-layout/pkg/config"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/storage"
+	"github.com/ethanli-dev/go-app-layout/pkg/storage/s3test"
+)
+
+// fileFields 每次上传都会变化的字段
+var fileFields = []string{"created_at", "updated_at", "upload_id", "download_url", "expires_at"}
+
+// newFileClient 使用 S3 替身作为存储后端
+func newFileClient(t *testing.T, sc *config.StorageConfig) (*webtest.Client, *s3test.Server) {
+	t.Helper()
+	srv := s3test.New(t, "files", "access", "secret")
+	store, err := storage.New(
+		storage.WithDriver(storage.DriverS3),
+		storage.WithS3(srv.URL(), "us-east-1", "files", "access", "secret"),
+		storage.WithS3PathStyle(true),
+		storage.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.URLSecret = "test-url-secret"
+	return newClient(t, &config.Config{Storage: sc}, store), srv
+}
+
+func TestFileUpload(t *testing.T) {
+	c, srv := newFileClient(t, &config.StorageConfig{})
+	signer := tenantSigner(createTenant(t, c))
+	content := []byte("hello, file storage")
+
+	resp := c.POST("/v1/files").File("file", "hello.txt", content, nil).Sign(signer).Send().
+		Status(http.StatusOK).OK().Golden("file_upload", fileFields...)
+	file := webtest.Data[*v1.FileResponse](resp)
+	keys := srv.Keys()
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], "tenants/1/") || string(srv.Object(keys[0]).Data) != string(content) {
+		t.Fatalf("stored objects = %v", keys)
+	}
+	if !strings.HasPrefix(file.DownloadURL, "/v1/files/download/1?") {
+		t.Fatalf("download url = %q", file.DownloadURL)
+	}
+
+	c.GET("/v1/files/1").Sign(signer).Send().Status(http.StatusOK).OK().Golden("file_get", fileFields...)
+	c.DELETE("/v1/files/1").Sign(signer).Send().Status(http.StatusOK).OK()
+	if keys := srv.Keys(); len(keys) != 0 {
+		t.Fatalf("objects after delete = %v", keys)
+	}
+	c.GET("/v1/files/1").Sign(signer).Send().Golden("file_not_found")
+}
+
+func TestFileDownloadURL(t *testing.T) {
+	c, _ := newFileClient(t, &config.StorageConfig{})
+	signer := tenantSigner(createTenant(t, c))
+	content := []byte("%PDF-1.4 signed download")
+	resp := c.POST("/v1/files").File("file", "report.pdf", content, nil).Sign(signer).Send().OK()
+	file := webtest.Data[*v1.FileResponse](resp)
+
+	download := c.GET(file.DownloadURL).Send().Status(http.StatusOK).
+		HasHeader("Content-Type", "application/pdf").
+		HasHeader("Content-Disposition", "attachment; filename=report.pdf")
+	if download.Body.String() != string(content) {
+		t.Fatalf("downloaded content = %q", download.Body.String())
+	}
+
+	u, err := url.Parse(file.DownloadURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := u.Query()
+	tampered.Set("tenant", "2")
+	c.GET(u.Path + "?" + tampered.Encode()).Send().Code(errorx.ErrCodeForbidden).Message("invalid or expired download url")
+	expired := u.Query()
+	expired.Set("expires", "1")
+	c.GET(u.Path + "?" + expired.Encode()).Send().Code(errorx.ErrCodeForbidden).Message("invalid or expired download url")
+}
+
+func TestFileChunkedUpload(t *testing.T) {
+	c, srv := newFileClient(t, &config.StorageConfig{ChunkSize: 8})
+	signer := tenantSigner(createTenant(t, c))
+	content := []byte("chunked upload content")
+
+	resp := c.POST("/v1/files/uploads").JSON(&v1.UploadRequest{Name: "chunked.txt", Size: int64(len(content))}).Sign(signer).Send().
+		Status(http.StatusOK).OK().Golden("file_upload_create", fileFields...)
+	session := webtest.Data[*model.UploadSession](resp)
+	base := "/v1/files/uploads/" + session.UploadID
+
+	// 乱序上传，中途查询进度用于续传
+	c.PUT(base+"/chunks/2").Body("application/octet-stream", content[16:]).Sign(signer).Send().OK()
+	c.PUT(base+"/chunks/0").Body("application/octet-stream", content[:8]).Sign(signer).Send().OK()
+	if status := webtest.Data[*v1.UploadStatusResponse](c.GET(base).Sign(signer).Send().OK()); !slices.Equal(status.Received, []int{0, 2}) {
+		t.Fatalf("received chunks = %v, want [0 2]", status.Received)
+	}
+	c.POST(base + "/complete").Sign(signer).Send().Code(errorx.ErrCodeValidation).Message("2 of 3 chunks uploaded")
+	c.PUT(base+"/chunks/1").Body("application/octet-stream", content[8:12]).Sign(signer).Send().Code(errorx.ErrCodeValidation).Message("chunk 1 must be 8 bytes")
+	c.PUT(base+"/chunks/1").Body("application/octet-stream", content[8:16]).Sign(signer).Send().OK()
+
+	file := webtest.Data[*v1.FileResponse](c.POST(base + "/complete").Sign(signer).Send().OK())
+	if file.Size != int64(len(content)) || file.Name != "chunked.txt" {
+		t.Fatalf("file = %+v", file.File)
+	}
+	keys := srv.Keys()
+	if len(keys) != 1 || string(srv.Object(keys[0]).Data) != string(content) {
+		t.Fatalf("chunks must be merged and removed, objects = %v", keys)
+	}
+	if body := c.GET(file.DownloadURL).Send().Status(http.StatusOK).Body.String(); body != string(content) {
+		t.Fatalf("downloaded content = %q", body)
+	}
+	c.GET(base).Sign(signer).Send().Code(errorx.ErrCodeNotFound)
+}
+
+func TestFileQuota(t *testing.T) {
+	c, srv := newFileClient(t, &config.StorageConfig{TenantQuota: 32})
+	signer := tenantSigner(createTenant(t, c))
+
+	c.POST("/v1/files").File("file", "a.txt", []byte(strings.Repeat("a", 20)), nil).Sign(signer).Send().OK()
+	c.POST("/v1/files").File("file", "b.txt", []byte(strings.Repeat("b", 20)), nil).Sign(signer).Send().Golden("file_quota_exceeded")
+	// 分片上传会话创建后即预占配额
+	c.POST("/v1/files/uploads").JSON(&v1.UploadRequest{Name: "c.txt", Size: 10}).Sign(signer).Send().OK()
+	c.POST("/v1/files").File("file", "d.txt", []byte(strings.Repeat("d", 5)), nil).Sign(signer).Send().Golden("file_quota_reserved")
+	if keys := srv.Keys(); len(keys) != 1 {
+		t.Fatalf("objects = %v, want only the first file", keys)
+	}
+}
+
+// TestFileQuotaConcurrent 并发上传时配额检查与写入元数据之间不能有竞争
+func TestFileQuotaConcurrent(t *testing.T) {
+	c, _ := newFileClient(t, &config.StorageConfig{TenantQuota: 32})
+	signer := tenantSigner(createTenant(t, c))
+
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := c.POST("/v1/files").File("file", "a.txt", []byte(strings.Repeat("a", 10)), nil).Sign(signer).Send()
+			if webtest.Decode[any](resp).Code == errorx.ErrCodeSuccess {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := accepted.Load(); n != 3 {
+		t.Fatalf("accepted uploads = %d, want 3 within quota", n)
+	}
+}
+
+// TestFileCompleteConcurrent 同一分片上传并发合并时只能生成一个文件，会话预占的配额只转给该文件
+func TestFileCompleteConcurrent(t *testing.T) {
+	c, srv := newFileClient(t, &config.StorageConfig{ChunkSize: 8, TenantQuota: 32})
+	signer := tenantSigner(createTenant(t, c))
+	content := []byte("concurrent complete!")
+
+	session := webtest.Data[*model.UploadSession](c.POST("/v1/files/uploads").
+		JSON(&v1.UploadRequest{Name: "c.txt", Size: int64(len(content))}).Sign(signer).Send().OK())
+	base := "/v1/files/uploads/" + session.UploadID
+	for i := 0; i < session.TotalChunks; i++ {
+		chunk := content[i*8 : min((i+1)*8, len(content))]
+		c.PUT(base+"/chunks/"+strconv.Itoa(i)).Body("application/octet-stream", chunk).Sign(signer).Send().OK()
+	}
+
+	var wg sync.WaitGroup
+	var completed atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := c.POST(base + "/complete").Sign(signer).Send()
+			switch code := webtest.Decode[any](resp).Code; code {
+			case errorx.ErrCodeSuccess:
+				completed.Add(1)
+			case errorx.ErrCodeConflict, errorx.ErrCodeNotFound:
+			default:
+				t.Errorf("complete code = %d, body = %s", code, resp.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	if n := completed.Load(); n != 1 {
+		t.Fatalf("completed = %d, want 1", n)
+	}
+	if keys := srv.Keys(); len(keys) != 1 || string(srv.Object(keys[0]).Data) != string(content) {
+		t.Fatalf("objects = %v, want the merged file only", keys)
+	}
+	// 已用 20 字节，配额 32 字节
+	c.POST("/v1/files").File("file", "a.txt", []byte(strings.Repeat("a", 13)), nil).Sign(signer).Send().Code(errorx.ErrCodeForbidden)
+	c.POST("/v1/files").File("file", "a.txt", []byte(strings.Repeat("a", 12)), nil).Sign(signer).Send().OK()
+}
+
+// TestFileCrossTenant 租户不能读取或删除其他租户的文件
+func TestFileCrossTenant(t *testing.T) {
+	c, srv := newFileClient(t, &config.StorageConfig{})
+	owner := tenantSigner(createTenant(t, c))
+	other := tenantSigner(createTenant(t, c))
+	file := webtest.Data[*v1.FileResponse](c.POST("/v1/files").File("file", "a.txt", []byte("tenant a"), nil).Sign(owner).Send().OK())
+	path := "/v1/files/" + strconv.FormatUint(uint64(file.ID), 10)
+
+	c.GET(path).Sign(other).Send().Code(errorx.ErrCodeNotFound).Message("file not found")
+	c.DELETE(path).Sign(other).Send().Code(errorx.ErrCodeNotFound).Message("file not found")
+	if keys := srv.Keys(); len(keys) != 1 {
+		t.Fatalf("objects = %v, want the file to remain", keys)
+	}
+	c.GET(path).Sign(owner).Send().OK()
+}
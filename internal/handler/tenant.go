@@ -4,10 +4,13 @@ Copyright © 2025 lixw
 package handler
 
 import (
+	"strconv"
+
 	"github.com/ethanli-dev/go-app-layout/api"
 	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
 	"github.com/ethanli-dev/go-app-layout/internal/service"
 	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/web/middleware"
 	"github.com/gin-gonic/gin"
 )
 
@@ -42,3 +45,103 @@ func (th *TenantHandler) Create(ctx *gin.Context) {
 	}
 	api.SuccessWithData(ctx, create)
 }
+
+// Info current tenant
+// @Summary Get the calling tenant
+// @Description Requires HMAC-SHA256 request signature headers, X-Signature-Key is the tenant id
+// @Tags tenant
+// @Produce json
+// @Param X-Signature-Key header string true "Tenant id"
+// @Param X-Signature-Timestamp header string true "Unix timestamp in seconds"
+// @Param X-Signature-Nonce header string true "Random nonce"
+// @Param X-Signature header string true "Hex encoded HMAC-SHA256 signature"
+// @Success 200 {object} api.Response[model.Tenant] "Tenant response"
+// @Router /tenant/info [get]
+func (th *TenantHandler) Info(ctx *gin.Context) {
+	id, err := signedTenantID(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	tenant, err := th.tenantSrv.Get(ctx, id)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	// 签名密钥仅在创建时返回
+	tenant.SignSecret = ""
+	api.SetETag(ctx, tenant.Version)
+	api.SuccessWithData(ctx, tenant)
+}
+
+// Get tenant
+// @Summary Get a tenant (admin)
+// @Description The ETag response header carries the tenant version, send it back in If-Match when updating
+// @Tags tenant
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param id path int true "Tenant id"
+// @Success 200 {object} api.Response[model.Tenant] "Tenant response"
+// @Router /admin/tenants/{id} [get]
+func (th *TenantHandler) Get(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "invalid tenant id"))
+		return
+	}
+	tenant, err := th.tenantSrv.Get(ctx, uint(id))
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	tenant.SignSecret = ""
+	api.SetETag(ctx, tenant.Version)
+	api.SuccessWithData(ctx, tenant)
+}
+
+// Update tenant
+// @Summary Update a tenant (admin)
+// @Description With If-Match the update only succeeds when the tenant is still at that version, otherwise it fails with a conflict
+// @Tags tenant
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param If-Match header string false "ETag from a previous read"
+// @Param id path int true "Tenant id"
+// @Param req body v1.TenantUpdateRequest true "Update tenant request"
+// @Success 200 {object} api.Response[model.Tenant] "Tenant response"
+// @Router /admin/tenants/{id} [put]
+func (th *TenantHandler) Update(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "invalid tenant id"))
+		return
+	}
+	version, err := api.IfMatch(ctx)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	var req v1.TenantUpdateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "failed to parse request parameters"))
+		return
+	}
+	tenant, err := th.tenantSrv.Update(ctx, uint(id), version, &req)
+	if err != nil {
+		api.Failure(ctx, err)
+		return
+	}
+	tenant.SignSecret = ""
+	api.SetETag(ctx, tenant.Version)
+	api.SuccessWithData(ctx, tenant)
+}
+
+// signedTenantID 返回通过请求签名校验的租户ID
+func signedTenantID(ctx *gin.Context) (uint, error) {
+	id, err := strconv.ParseUint(middleware.SignatureKeyID(ctx), 10, 64)
+	if err != nil {
+		return 0, errorx.New(errorx.ErrCodeUnauthorized, "invalid signature key")
+	}
+	return uint(id), nil
+}
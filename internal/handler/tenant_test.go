@@ -0,0 +1,136 @@
+/*
+Copyright © 2025 lixw
+*/
+package handler_test
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"testing"
+
+	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
+	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/internal/webtest"
+	"github.com/ethanli-dev/go-app-layout/pkg/config"
+	"github.com/ethanli-dev/go-app-layout/pkg/encrypt"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/signature"
+	"github.com/ethanli-dev/go-app-layout/pkg/storage"
+	"github.com/ethanli-dev/go-app-layout/pkg/web"
+	"gorm.io/gorm"
+)
+
+const adminToken = "test-admin-token"
+
+// tenantFields 每次创建都会变化的字段
+var tenantFields = []string{"api_key", "sign_secret", "created_at", "updated_at"}
+
+// newDB 创建安装了生产插件和加密插件的 SQLite 临时库
+func newDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	keyring, err := encrypt.NewKeyring(1, map[uint32]string{1: randomKey(t)}, randomKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return webtest.NewDB(t, encrypt.New(keyring))
+}
+
+// newClient 基于 newDB 创建测试客户端，store 为 nil 时不支持文件接口
+func newClient(t *testing.T, cfg *config.Config, store storage.Storage) *webtest.Client {
+	t.Helper()
+	return newDBClient(t, cfg, newDB(t), store)
+}
+
+// newDBClient 使用给定的数据库创建测试客户端，用于需要直接读写数据的测试
+func newDBClient(t *testing.T, cfg *config.Config, db *gorm.DB, store storage.Storage) *webtest.Client {
+	t.Helper()
+	return webtest.New(t,
+		webtest.WithServer(webtest.NewServer(cfg, db, store)),
+		webtest.WithWebOptions(web.WithAdminToken(adminToken), web.WithDefaultVersion("v1")),
+		webtest.WithConfigValue("tenant.aes_key", "0123456789abcdef"),
+	)
+}
+
+func randomKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func createTenant(t *testing.T, c *webtest.Client) *model.Tenant {
+	t.Helper()
+	resp := c.POST("/v1/tenant/create").JSON(&v1.TenantRequest{Name: "acme", Description: "demo tenant"}).Send().Status(http.StatusOK).OK()
+	return webtest.Data[*model.Tenant](resp)
+}
+
+// tenantSigner 返回租户的请求签名器
+func tenantSigner(tenant *model.Tenant) *signature.Signer {
+	return signature.NewSigner(strconv.FormatUint(uint64(tenant.ID), 10), []byte(tenant.SignSecret))
+}
+
+func TestTenantCreate(t *testing.T) {
+	c := newClient(t, &config.Config{}, nil)
+	resp := c.POST("/v1/tenant/create").JSON(&v1.TenantRequest{Name: "acme", Description: "demo tenant"}).Send()
+	resp.Status(http.StatusOK).OK().Golden("tenant_create", tenantFields...)
+	tenant := webtest.Data[*model.Tenant](resp)
+	if tenant.ApiKey == "" || tenant.SignSecret == "" {
+		t.Fatalf("tenant credentials are not generated: %+v", tenant)
+	}
+}
+
+func TestTenantCreateValidation(t *testing.T) {
+	c := newClient(t, &config.Config{}, nil)
+	c.POST("/v1/tenant/create").JSON(&v1.TenantRequest{}).Send().Status(http.StatusOK).Golden("tenant_create_invalid")
+}
+
+func TestTenantInfo(t *testing.T) {
+	c := newClient(t, &config.Config{}, nil)
+	tenant := createTenant(t, c)
+	c.GET("/v1/tenant/info").Sign(tenantSigner(tenant)).Send().Status(http.StatusOK).OK().Golden("tenant_info", tenantFields...)
+}
+
+// TestTenantInfoUnversioned 签名未带版本前缀的路径，由服务端协商版本
+func TestTenantInfoUnversioned(t *testing.T) {
+	c := newClient(t, &config.Config{}, nil)
+	tenant := createTenant(t, c)
+	c.GET("/tenant/info").Sign(tenantSigner(tenant)).Send().Status(http.StatusOK).OK().HasHeader(web.HeaderKeyAPIVersion, "v1")
+}
+
+func TestTenantInfoUnsigned(t *testing.T) {
+	c := newClient(t, &config.Config{}, nil)
+	createTenant(t, c)
+	c.GET("/v1/tenant/info").Send().Golden("tenant_info_unsigned")
+}
+
+// TestTenantDisabled 已禁用的租户不能通过签名校验
+func TestTenantDisabled(t *testing.T) {
+	db := newDB(t)
+	c := newDBClient(t, &config.Config{}, db, nil)
+	tenant := createTenant(t, c)
+	signer := tenantSigner(tenant)
+	c.GET("/v1/tenant/info").Sign(signer).Send().OK()
+
+	if err := db.Model(&model.Tenant{}).Where("id = ?", tenant.ID).Update("status", 0).Error; err != nil {
+		t.Fatal(err)
+	}
+	c.GET("/v1/tenant/info").Sign(signer).Send().Code(errorx.ErrCodeUnauthorized).Message("invalid request signature")
+	c.POST("/v1/files/uploads").JSON(&v1.UploadRequest{Name: "a.txt", Size: 1}).Sign(signer).Send().Code(errorx.ErrCodeUnauthorized)
+}
+
+func TestTenantAdmin(t *testing.T) {
+	c := newClient(t, &config.Config{}, nil)
+	tenant := createTenant(t, c)
+	path := "/admin/tenants/" + strconv.FormatUint(uint64(tenant.ID), 10)
+
+	c.GET(path).Send().Golden("tenant_admin_unauthorized")
+	c.GET(path).Bearer(adminToken).Send().Status(http.StatusOK).OK().Golden("tenant_admin_get", tenantFields...)
+	name := "acme inc"
+	c.PUT(path).Bearer(adminToken).JSON(&v1.TenantUpdateRequest{Name: &name}).Send().
+		Status(http.StatusOK).OK().Golden("tenant_admin_update", tenantFields...)
+	c.GET("/admin/tenants/404").Bearer(adminToken).Send().Golden("tenant_admin_not_found")
+}
@@ -5,4 +5,4 @@ package handler
 
 import "github.com/google/wire"
 
-var ProviderSet = wire.NewSet(NewTenantHandler)
+var ProviderSet = wire.NewSet(NewTenantHandler, NewFileHandler, NewAuditHandler, NewSlowQueryHandler)
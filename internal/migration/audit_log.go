@@ -0,0 +1,41 @@
+/*
+Copyright © 2025 lixw
+*/
+package migration
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// auditLog 实体变更审计日志
+var auditLog = &migrate.Migration{
+	Version: 20250701000000,
+	Name:    "audit_log",
+	Up: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().CreateTable(&auditEvent{})
+	},
+	Down: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&auditEvent{})
+	},
+}
+
+type auditEvent struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;comment:主键ID"`
+	Entity    string    `gorm:"column:entity;size:127;not null;index:idx_audit_log_entity,priority:1;comment:实体表名"`
+	EntityID  string    `gorm:"column:entity_id;size:127;not null;index:idx_audit_log_entity,priority:2;comment:实体主键"`
+	Action    string    `gorm:"column:action;size:16;not null;comment:操作:create,update,delete"`
+	Changes   string    `gorm:"column:changes;type:text;comment:字段变更"`
+	Actor     string    `gorm:"column:actor;size:127;index;comment:操作者"`
+	TenantID  uint      `gorm:"column:tenant_id;index;comment:租户ID"`
+	RequestID string    `gorm:"column:request_id;size:64;comment:请求ID"`
+	IP        string    `gorm:"column:ip;size:64;comment:客户端IP"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime;index;comment:操作时间"`
+}
+
+func (*auditEvent) TableName() string {
+	return "audit_log"
+}
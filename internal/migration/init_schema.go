@@ -0,0 +1,74 @@
+/*
+Copyright © 2025 lixw
+*/
+package migration
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// initSchema 基线迁移，创建引入迁移引擎前由 AutoMigrate 维护的表。
+// 表结构为当时模型的快照，不随 model 包变化；已有的库执行时表结构一致，不做修改
+var initSchema = &migrate.Migration{
+	Version: 20250101000000,
+	Name:    "init_schema",
+	Up: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().AutoMigrate(&initTenant{}, &initFile{}, &initUploadSession{})
+	},
+	Down: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&initUploadSession{}, &initFile{}, &initTenant{})
+	},
+}
+
+// initModel 未导出的匿名字段会被 gorm 忽略，使用具名字段加 embedded 标签嵌入
+type initModel struct {
+	ID        uint           `gorm:"primaryKey;autoIncrement;comment:主键ID"`
+	Status    uint8          `gorm:"default:1;comment:状态:0=禁用,1=启用"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;comment:创建时间"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime;comment:更新时间"`
+	DeletedAt gorm.DeletedAt `gorm:"index;comment:删除时间"`
+}
+
+type initTenant struct {
+	Model       initModel `gorm:"embedded"`
+	Name        string    `gorm:"column:name;size:127;not null;comment:租户名称"`
+	Description string    `gorm:"column:description;size:511;comment:租户描述"`
+	ApiKey      string    `gorm:"column:api_key;size:255;comment:API密钥"`
+	SignSecret  string    `gorm:"column:sign_secret;size:64;comment:请求签名密钥"`
+}
+
+func (*initTenant) TableName() string {
+	return "tenant"
+}
+
+type initFile struct {
+	Model       initModel `gorm:"embedded"`
+	TenantID    uint      `gorm:"column:tenant_id;not null;index;comment:租户ID"`
+	Name        string    `gorm:"column:name;size:255;not null;comment:文件名"`
+	StorageKey  string    `gorm:"column:storage_key;size:512;not null;uniqueIndex;comment:存储路径"`
+	Size        int64     `gorm:"column:size;not null;comment:文件大小(字节)"`
+	ContentType string    `gorm:"column:content_type;size:127;comment:文件类型(按内容识别)"`
+	Checksum    string    `gorm:"column:checksum;size:64;comment:SHA256校验值"`
+}
+
+func (*initFile) TableName() string {
+	return "file"
+}
+
+type initUploadSession struct {
+	Model       initModel `gorm:"embedded"`
+	UploadID    string    `gorm:"column:upload_id;size:32;not null;uniqueIndex;comment:上传ID"`
+	TenantID    uint      `gorm:"column:tenant_id;not null;index;comment:租户ID"`
+	Name        string    `gorm:"column:name;size:255;not null;comment:文件名"`
+	Size        int64     `gorm:"column:size;not null;comment:文件大小(字节)"`
+	ChunkSize   int64     `gorm:"column:chunk_size;not null;comment:分片大小(字节)"`
+	TotalChunks int       `gorm:"column:total_chunks;not null;comment:分片数量"`
+}
+
+func (*initUploadSession) TableName() string {
+	return "upload_session"
+}
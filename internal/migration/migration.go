@@ -0,0 +1,56 @@
+/*
+Copyright © 2025 lixw
+*/
+package migration
+
+import (
+	"embed"
+
+	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/pkg/audit"
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+	"github.com/ethanli-dev/go-app-layout/pkg/outbox"
+	"gorm.io/gorm"
+)
+
+// FS SQL 迁移脚本，命名规则见 sql/README.md
+//
+//go:embed sql
+var FS embed.FS
+
+const Dir = "sql"
+
+// Migrations Go 编写的迁移，适用于数据迁移或需要兼容多种数据库的结构变更
+func Migrations() []*migrate.Migration {
+	return []*migrate.Migration{
+		initSchema,
+		tenantVersion,
+		auditLog,
+		outboxTables,
+		tenantEncryption,
+		storageLock,
+		uploadSessionCompleting,
+	}
+}
+
+// Models 由迁移维护表结构的模型，migrate plan 据此对比数据库表结构
+func Models() []any {
+	return []any{
+		&model.Tenant{},
+		&model.File{},
+		&model.UploadSession{},
+		&model.StorageLock{},
+		&audit.Event{},
+		&outbox.Message{},
+		&outbox.DeadLetter{},
+	}
+}
+
+// New 创建包含全部 SQL 和 Go 迁移的迁移器
+func New(db *gorm.DB, options ...migrate.Option) (*migrate.Migrator, error) {
+	opts := []migrate.Option{
+		migrate.WithFS(FS, Dir),
+		migrate.WithMigrations(Migrations()...),
+	}
+	return migrate.New(db, append(opts, options...)...)
+}
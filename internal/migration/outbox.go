@@ -0,0 +1,71 @@
+/*
+Copyright © 2025 lixw
+*/
+package migration
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// outboxTables 事务性事件投递：待投递事件、死信和投递租约
+var outboxTables = &migrate.Migration{
+	Version: 20250801000000,
+	Name:    "outbox",
+	Up: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().CreateTable(&outboxMessage{}, &outboxDeadLetter{}, &outboxLease{})
+	},
+	Down: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&outboxLease{}, &outboxDeadLetter{}, &outboxMessage{})
+	},
+}
+
+type outboxMessage struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement;index:idx_outbox_aggregate,priority:3;comment:主键ID"`
+	Aggregate     string    `gorm:"column:aggregate;size:64;not null;index:idx_outbox_aggregate,priority:1;comment:聚合类型"`
+	AggregateID   string    `gorm:"column:aggregate_id;size:127;not null;index:idx_outbox_aggregate,priority:2;comment:聚合ID"`
+	Topic         string    `gorm:"column:topic;size:127;not null;comment:事件类型"`
+	Payload       string    `gorm:"column:payload;type:text;comment:事件内容(JSON)"`
+	TraceID       string    `gorm:"column:trace_id;size:64;comment:请求ID"`
+	TenantID      uint      `gorm:"column:tenant_id;comment:租户ID"`
+	Attempts      int       `gorm:"column:attempts;not null;default:0;comment:投递次数"`
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at;not null;index;comment:下次投递时间"`
+	LastError     string    `gorm:"column:last_error;size:1024;comment:最近一次投递错误"`
+	CreatedAt     time.Time `gorm:"column:created_at;comment:创建时间"`
+}
+
+func (*outboxMessage) TableName() string {
+	return "outbox"
+}
+
+type outboxDeadLetter struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement;comment:主键ID"`
+	MessageID   uint      `gorm:"column:message_id;not null;comment:原事件ID"`
+	Aggregate   string    `gorm:"column:aggregate;size:64;not null;comment:聚合类型"`
+	AggregateID string    `gorm:"column:aggregate_id;size:127;not null;comment:聚合ID"`
+	Topic       string    `gorm:"column:topic;size:127;not null;index;comment:事件类型"`
+	Payload     string    `gorm:"column:payload;type:text;comment:事件内容(JSON)"`
+	TraceID     string    `gorm:"column:trace_id;size:64;comment:请求ID"`
+	TenantID    uint      `gorm:"column:tenant_id;comment:租户ID"`
+	Attempts    int       `gorm:"column:attempts;not null;comment:投递次数"`
+	LastError   string    `gorm:"column:last_error;size:1024;comment:最后一次投递错误"`
+	CreatedAt   time.Time `gorm:"column:created_at;comment:事件创建时间"`
+	FailedAt    time.Time `gorm:"column:failed_at;not null;comment:转入死信时间"`
+}
+
+func (*outboxDeadLetter) TableName() string {
+	return "outbox_dead_letter"
+}
+
+type outboxLease struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement:false"`
+	Owner     string    `gorm:"column:owner;size:255;not null"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+}
+
+func (*outboxLease) TableName() string {
+	return "outbox_lease"
+}
@@ -0,0 +1,31 @@
+/*
+Copyright © 2025 lixw
+*/
+package migration
+
+import (
+	"context"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// storageLock 租户存储配额锁，按需插入，无需回填
+var storageLock = &migrate.Migration{
+	Version: 20250915000000,
+	Name:    "storage_lock",
+	Up: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().CreateTable(&storageLockTable{})
+	},
+	Down: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&storageLockTable{})
+	},
+}
+
+type storageLockTable struct {
+	TenantID uint `gorm:"column:tenant_id;primaryKey;autoIncrement:false;comment:租户ID"`
+}
+
+func (*storageLockTable) TableName() string {
+	return "storage_lock"
+}
@@ -0,0 +1,54 @@
+/*
+Copyright © 2025 lixw
+*/
+package migration
+
+import (
+	"context"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// tenantEncryption 租户密钥加密存储：加长签名密钥列以容纳密文，增加 API 密钥盲索引；
+// 已有的明文数据由 encrypt.Rotator 在后台加密。回滚前需先解密数据，否则签名密钥会被截断
+var tenantEncryption = &migrate.Migration{
+	Version: 20250901000000,
+	Name:    "tenant_encryption",
+	Up: func(ctx context.Context, tx *gorm.DB) error {
+		m := tx.Migrator()
+		// SQLite 不限制长度，修改列会重建表并丢失索引
+		if tx.Dialector.Name() != database.DriverSQLite {
+			if err := m.AlterColumn(&encryptionTenant{}, "SignSecret"); err != nil {
+				return err
+			}
+		}
+		if err := m.AddColumn(&encryptionTenant{}, "ApiKeyIndex"); err != nil {
+			return err
+		}
+		return m.CreateIndex(&encryptionTenant{}, "ApiKeyIndex")
+	},
+	Down: func(ctx context.Context, tx *gorm.DB) error {
+		m := tx.Migrator()
+		if err := m.DropIndex(&encryptionTenant{}, "ApiKeyIndex"); err != nil {
+			return err
+		}
+		if err := m.DropColumn(&encryptionTenant{}, "ApiKeyIndex"); err != nil {
+			return err
+		}
+		if tx.Dialector.Name() == database.DriverSQLite {
+			return nil
+		}
+		return m.AlterColumn(&initTenant{}, "SignSecret")
+	},
+}
+
+type encryptionTenant struct {
+	SignSecret  string `gorm:"column:sign_secret;size:255;comment:请求签名密钥"`
+	ApiKeyIndex string `gorm:"column:api_key_bidx;size:32;index;comment:API密钥盲索引"`
+}
+
+func (*encryptionTenant) TableName() string {
+	return "tenant"
+}
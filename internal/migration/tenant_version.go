@@ -0,0 +1,31 @@
+/*
+Copyright © 2025 lixw
+*/
+package migration
+
+import (
+	"context"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// tenantVersion 租户增加乐观锁版本号，已有记录从 1 开始
+var tenantVersion = &migrate.Migration{
+	Version: 20250601000000,
+	Name:    "tenant_version",
+	Up: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().AddColumn(&versionTenant{}, "Version")
+	},
+	Down: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().DropColumn(&versionTenant{}, "Version")
+	},
+}
+
+type versionTenant struct {
+	Version uint `gorm:"column:version;not null;default:1;comment:乐观锁版本号"`
+}
+
+func (*versionTenant) TableName() string {
+	return "tenant"
+}
@@ -0,0 +1,31 @@
+/*
+Copyright © 2025 lixw
+*/
+package migration
+
+import (
+	"context"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// uploadSessionCompleting 分片上传会话增加合并标记，已有会话均未在合并
+var uploadSessionCompleting = &migrate.Migration{
+	Version: 20250916000000,
+	Name:    "upload_session_completing",
+	Up: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().AddColumn(&completingUploadSession{}, "Completing")
+	},
+	Down: func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Migrator().DropColumn(&completingUploadSession{}, "Completing")
+	},
+}
+
+type completingUploadSession struct {
+	Completing bool `gorm:"column:completing;not null;default:false;comment:是否正在合并"`
+}
+
+func (*completingUploadSession) TableName() string {
+	return "upload_session"
+}
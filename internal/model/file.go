@@ -0,0 +1,48 @@
+/*
+Copyright © 2025 lixw
+*/
+package model
+
+import (
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+)
+
+type File struct {
+	database.Model
+	database.TenantModel
+	Name        string `json:"name" gorm:"column:name;size:255;not null;comment:文件名"`
+	StorageKey  string `json:"-" gorm:"column:storage_key;size:512;not null;uniqueIndex;comment:存储路径"`
+	Size        int64  `json:"size" gorm:"column:size;not null;comment:文件大小(字节)"`
+	ContentType string `json:"content_type" gorm:"column:content_type;size:127;comment:文件类型(按内容识别)"`
+	Checksum    string `json:"checksum" gorm:"column:checksum;size:64;comment:SHA256校验值"`
+}
+
+func (*File) TableName() string {
+	return "file"
+}
+
+// UploadSession 分片上传会话，分片按序号存储在临时路径，全部上传后合并为文件
+type UploadSession struct {
+	database.Model
+	database.TenantModel
+	UploadID    string `json:"upload_id" gorm:"column:upload_id;size:32;not null;uniqueIndex;comment:上传ID"`
+	Name        string `json:"name" gorm:"column:name;size:255;not null;comment:文件名"`
+	Size        int64  `json:"size" gorm:"column:size;not null;comment:文件大小(字节)"`
+	ChunkSize   int64  `json:"chunk_size" gorm:"column:chunk_size;not null;comment:分片大小(字节)"`
+	TotalChunks int    `json:"total_chunks" gorm:"column:total_chunks;not null;comment:分片数量"`
+	// Completing 合并中的会话不能再上传分片、取消或重复合并
+	Completing bool `json:"-" gorm:"column:completing;not null;default:false;comment:是否正在合并"`
+}
+
+func (*UploadSession) TableName() string {
+	return "upload_session"
+}
+
+// StorageLock 每个租户一行，保存文件或分片上传会话前锁定，使同一租户的配额检查与写入串行执行
+type StorageLock struct {
+	TenantID uint `gorm:"column:tenant_id;primaryKey;autoIncrement:false;comment:租户ID"`
+}
+
+func (*StorageLock) TableName() string {
+	return "storage_lock"
+}
@@ -9,9 +9,12 @@ import (
 
 type Tenant struct {
 	database.Model
+	database.VersionModel
 	Name        string `json:"name" gorm:"column:name;size:127;not null;comment:租户名称"`
 	Description string `json:"description" gorm:"column:description;size:511;comment:租户描述"`
-	ApiKey      string `json:"api_key" gorm:"column:api_key;size:255;comment:API密钥"`
+	ApiKey      string `json:"api_key" gorm:"column:api_key;size:255;serializer:encrypted;comment:API密钥" audit:"mask"`
+	ApiKeyIndex string `json:"-" gorm:"column:api_key_bidx;size:32;index;comment:API密钥盲索引" blind:"ApiKey" audit:"-"`
+	SignSecret  string `json:"sign_secret,omitempty" gorm:"column:sign_secret;size:255;serializer:encrypted;comment:请求签名密钥" audit:"mask"`
 }
 
 func (*Tenant) TableName() string {
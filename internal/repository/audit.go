@@ -0,0 +1,25 @@
+/*
+Copyright © 2025 lixw
+*/
+package repository
+
+import (
+	"context"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/audit"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{
+		db: db,
+	}
+}
+
+func (ar *AuditRepository) Page(ctx context.Context, filter audit.Filter, offset, limit int) ([]*audit.Event, int64, error) {
+	return audit.Query(ctx, ar.db, filter, offset, limit)
+}
@@ -0,0 +1,27 @@
+/*
+Copyright © 2025 lixw
+*/
+package repository
+
+import (
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+)
+
+type SlowQueryRepository struct {
+	db *gorm.DB
+}
+
+func NewSlowQueryRepository(db *gorm.DB) *SlowQueryRepository {
+	return &SlowQueryRepository{
+		db: db,
+	}
+}
+
+func (sr *SlowQueryRepository) List() []database.SlowQuery {
+	return database.SlowQueries(sr.db)
+}
+
+func (sr *SlowQueryRepository) Reset() {
+	database.ResetSlowQueries(sr.db)
+}
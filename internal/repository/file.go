@@ -0,0 +1,109 @@
+/*
+Copyright © 2025 lixw
+*/
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/pkg/cache"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrFileNotFound          = errors.New("file not found")
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+)
+
+type FileRepository struct {
+	db    *gorm.DB
+	cache *cache.Cache
+}
+
+func NewFileRepository(db *gorm.DB) *FileRepository {
+	return &FileRepository{
+		db:    db,
+		cache: cache.Of(db),
+	}
+}
+
+func (fr *FileRepository) Create(ctx context.Context, file *model.File) error {
+	return database.Conn(ctx, fr.db).Create(file).Error
+}
+
+func (fr *FileRepository) GetById(ctx context.Context, id uint) (*model.File, error) {
+	return cache.GetByID(ctx, fr.cache, id, func(ctx context.Context) (*model.File, error) {
+		var file model.File
+		if err := database.Conn(ctx, fr.db).First(&file, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrFileNotFound
+			}
+			return nil, err
+		}
+		return &file, nil
+	})
+}
+
+func (fr *FileRepository) Delete(ctx context.Context, id uint) error {
+	return database.Conn(ctx, fr.db).Where("id = ?", id).Delete(&model.File{}).Error
+}
+
+// TenantUsage 返回租户已用存储空间，包含未完成的分片上传会话预占的空间
+func (fr *FileRepository) TenantUsage(ctx context.Context, tenantID uint) (int64, error) {
+	var files, sessions int64
+	if err := database.Conn(ctx, fr.db).Model(&model.File{}).
+		Where("tenant_id = ?", tenantID).Select("COALESCE(SUM(size), 0)").Scan(&files).Error; err != nil {
+		return 0, err
+	}
+	if err := database.Conn(ctx, fr.db).Model(&model.UploadSession{}).
+		Where("tenant_id = ?", tenantID).Select("COALESCE(SUM(size), 0)").Scan(&sessions).Error; err != nil {
+		return 0, err
+	}
+	return files + sessions, nil
+}
+
+// LockTenant 锁定租户的配额锁直至事务结束，须在事务中调用；SQLite 不支持行锁，首条写入语句即串行化事务
+func (fr *FileRepository) LockTenant(ctx context.Context, tenantID uint) error {
+	db := database.Conn(ctx, fr.db)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.StorageLock{TenantID: tenantID}).Error; err != nil {
+		return err
+	}
+	var lock model.StorageLock
+	return db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where("tenant_id = ?", tenantID).Take(&lock).Error
+}
+
+func (fr *FileRepository) CreateSession(ctx context.Context, session *model.UploadSession) error {
+	return database.Conn(ctx, fr.db).Create(session).Error
+}
+
+func (fr *FileRepository) GetSession(ctx context.Context, uploadID string) (*model.UploadSession, error) {
+	var session model.UploadSession
+	if err := database.Conn(ctx, fr.db).Where("upload_id = ?", uploadID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadSessionNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ClaimSession 将会话标记为合并中，会话不存在或已被其他请求标记时返回 false
+func (fr *FileRepository) ClaimSession(ctx context.Context, uploadID string) (bool, error) {
+	res := database.Conn(ctx, fr.db).Model(&model.UploadSession{}).
+		Where("upload_id = ? AND completing = ?", uploadID, false).Update("completing", true)
+	return res.RowsAffected == 1, res.Error
+}
+
+// ReleaseSession 取消会话的合并标记，合并失败后允许重试
+func (fr *FileRepository) ReleaseSession(ctx context.Context, uploadID string) error {
+	return database.Conn(ctx, fr.db).Model(&model.UploadSession{}).
+		Where("upload_id = ?", uploadID).Update("completing", false).Error
+}
+
+func (fr *FileRepository) DeleteSession(ctx context.Context, uploadID string) error {
+	return database.Conn(ctx, fr.db).Where("upload_id = ?", uploadID).Delete(&model.UploadSession{}).Error
+}
@@ -0,0 +1,20 @@
+/*
+Copyright © 2025 lixw
+*/
+package repository
+
+import (
+	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/pkg/audit"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+)
+
+// Plugins 仓储依赖的 gorm 插件：租户隔离、乐观锁，以及租户和文件的变更审计（租户密钥脱敏记录）
+func Plugins() []gorm.Plugin {
+	return []gorm.Plugin{
+		database.NewTenantScope(),
+		database.NewOptimisticLock(),
+		audit.New(audit.WithModels(&model.Tenant{}, &model.File{})),
+	}
+}
@@ -6,30 +6,62 @@ package repository
 import (
 	"context"
 	"errors"
+	"slices"
 
 	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/pkg/cache"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/encrypt"
 	"gorm.io/gorm"
 )
 
 var ErrTenantNotFound = errors.New("tenant not found")
 
 type TenantRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cache *cache.Cache
 }
 
 func NewTenantRepository(db *gorm.DB) *TenantRepository {
 	return &TenantRepository{
-		db: db,
+		db:    db,
+		cache: cache.Of(db),
 	}
 }
 
 func (tr *TenantRepository) Create(ctx context.Context, tenant *model.Tenant) error {
-	return tr.db.WithContext(ctx).Create(tenant).Error
+	return tr.conn(ctx).Create(tenant).Error
 }
 
+// GetById 签名校验等每个请求都会读取租户，启用缓存时结果按 ID 缓存；
+// 缓存中的 API 密钥和签名密钥保持加密，读取后再解密
 func (tr *TenantRepository) GetById(ctx context.Context, id uint) (*model.Tenant, error) {
+	tenant, err := cache.GetByID(ctx, tr.cache, id, func(ctx context.Context) (*model.Tenant, error) {
+		var tenant model.Tenant
+		if err := tr.conn(ctx).First(&tenant, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrTenantNotFound
+			}
+			return nil, err
+		}
+		if err := encrypt.EncryptFields(tr.db, &tenant); err != nil {
+			return nil, err
+		}
+		return &tenant, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := encrypt.DecryptFields(tr.db, tenant); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// GetByApiKey API密钥加密存储，按盲索引查询
+func (tr *TenantRepository) GetByApiKey(ctx context.Context, apiKey string) (*model.Tenant, error) {
 	var tenant model.Tenant
-	if err := tr.db.WithContext(ctx).First(&tenant, id).Error; err != nil {
+	if err := tr.conn(ctx).Where(encrypt.Match("ApiKey", apiKey)).First(&tenant).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrTenantNotFound
 		}
@@ -38,10 +70,21 @@ func (tr *TenantRepository) GetById(ctx context.Context, id uint) (*model.Tenant
 	return &tenant, nil
 }
 
-func (tr *TenantRepository) Update(ctx context.Context, tenant *model.Tenant) error {
-	return tr.db.WithContext(ctx).Model(&model.Tenant{}).Where("id = ?", tenant.ID).Updates(tenant).Error
+// Update 更新非零值字段，指定 fields 时只更新这些字段（可写入零值）；tenant.Version 不为 0 时校验版本号，
+// 不一致返回 database.ErrVersionConflict，成功后 tenant.Version 为新版本号
+func (tr *TenantRepository) Update(ctx context.Context, tenant *model.Tenant, fields ...string) error {
+	tx := tr.conn(ctx).Model(&model.Tenant{}).Where("id = ?", tenant.ID)
+	if len(fields) > 0 {
+		tx = tx.Select(append(slices.Clone(fields), "updated_at"))
+	}
+	return tx.Updates(tenant).Error
 }
 
 func (tr *TenantRepository) Delete(ctx context.Context, id uint) error {
-	return tr.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Tenant{}).Error
+	return tr.conn(ctx).Where("id = ?", id).Delete(&model.Tenant{}).Error
+}
+
+// conn 租户注册表始终位于共享库，不随请求中的租户路由到租户库
+func (tr *TenantRepository) conn(ctx context.Context) *gorm.DB {
+	return database.Conn(database.WithShared(ctx), tr.db)
 }
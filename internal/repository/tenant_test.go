@@ -0,0 +1,98 @@
+/*
+Copyright © 2025 lixw
+*/
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/internal/repository"
+	"github.com/ethanli-dev/go-app-layout/pkg/cache"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/encrypt"
+)
+
+// recordingBackend 记录写入缓存的全部值
+type recordingBackend struct {
+	*cache.Memory
+	mu     sync.Mutex
+	values [][]byte
+}
+
+func (b *recordingBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	b.values = append(b.values, value)
+	b.mu.Unlock()
+	return b.Memory.Set(ctx, key, value, ttl)
+}
+
+func randomKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// TestTenantCacheEncrypted 缓存中不能保存明文的 API 密钥和签名密钥
+func TestTenantCacheEncrypted(t *testing.T) {
+	keyring, err := encrypt.NewKeyring(1, map[uint32]string{1: randomKey(t)}, randomKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &recordingBackend{Memory: cache.NewMemory(0)}
+	db, err := database.New(
+		database.WithUrl("sqlite://"+filepath.Join(t.TempDir(), "app.db")),
+		database.WithPlugins(encrypt.New(keyring), cache.New(backend)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&model.Tenant{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	tr := repository.NewTenantRepository(db)
+	tenant := &model.Tenant{Name: "acme", ApiKey: "plain-api-key", SignSecret: "plain-sign-secret"}
+	if err := tr.Create(ctx, tenant); err != nil {
+		t.Fatal(err)
+	}
+	// 第一次读取写入缓存，第二次命中缓存
+	for i := 0; i < 2; i++ {
+		got, err := tr.GetById(ctx, tenant.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ApiKey != tenant.ApiKey || got.SignSecret != tenant.SignSecret {
+			t.Fatalf("tenant = %+v, want decrypted secrets", got)
+		}
+		got.SignSecret = "modified"
+	}
+	var cached int
+	for _, value := range backend.values {
+		if bytes.Contains(value, []byte("plain-api-key")) || bytes.Contains(value, []byte("plain-sign-secret")) {
+			t.Fatalf("cache holds plaintext secrets: %q", value)
+		}
+		if bytes.Contains(value, []byte("acme")) {
+			cached++
+		}
+	}
+	if cached != 1 {
+		t.Fatalf("tenant cached %d times, want 1", cached)
+	}
+}
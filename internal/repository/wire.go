@@ -5,4 +5,4 @@ package repository
 
 import "github.com/google/wire"
 
-var ProviderSet = wire.NewSet(NewTenantRepository)
+var ProviderSet = wire.NewSet(NewTenantRepository, NewFileRepository, NewAuditRepository, NewSlowQueryRepository)
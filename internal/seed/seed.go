@@ -0,0 +1,27 @@
+/*
+Copyright © 2025 lixw
+*/
+package seed
+
+import (
+	"embed"
+
+	"github.com/ethanli-dev/go-app-layout/internal/migration"
+	"github.com/ethanli-dev/go-app-layout/pkg/seed"
+	"gorm.io/gorm"
+)
+
+// FS 各环境的夹具，目录名为环境名，格式见 fixtures/README.md
+//
+//go:embed fixtures
+var FS embed.FS
+
+const Dir = "fixtures"
+
+// New 创建可写入全部业务模型的夹具加载器
+func New(db *gorm.DB, options ...seed.Option) (*seed.Seeder, error) {
+	opts := []seed.Option{
+		seed.WithModels(migration.Models()...),
+	}
+	return seed.New(db, append(opts, options...)...)
+}
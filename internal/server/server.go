@@ -6,26 +6,103 @@ package server
 import (
 	"context"
 	"log/slog"
+	"strconv"
 
+	"github.com/ethanli-dev/go-app-layout/api"
 	"github.com/ethanli-dev/go-app-layout/internal/handler"
+	"github.com/ethanli-dev/go-app-layout/internal/service"
+	"github.com/ethanli-dev/go-app-layout/pkg/audit"
+	"github.com/ethanli-dev/go-app-layout/pkg/config"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/signature"
+	"github.com/ethanli-dev/go-app-layout/pkg/web/middleware"
 	"github.com/gin-gonic/gin"
 )
 
 type Server struct {
-	tenantHandler *handler.TenantHandler
+	tenantHandler    *handler.TenantHandler
+	fileHandler      *handler.FileHandler
+	auditHandler     *handler.AuditHandler
+	slowQueryHandler *handler.SlowQueryHandler
+	verifier         *signature.Verifier
 }
 
-func New(tenantHandler *handler.TenantHandler) *Server {
+func New(cfg *config.Config, tenantHandler *handler.TenantHandler, fileHandler *handler.FileHandler, auditHandler *handler.AuditHandler, slowQueryHandler *handler.SlowQueryHandler, tenantSrv *service.TenantService) *Server {
+	var opts []signature.Option
+	if cfg.Server != nil && cfg.Server.Signature != nil {
+		opts = append(opts, signature.WithClockSkew(cfg.Server.Signature.ClockSkew))
+	}
 	return &Server{
-		tenantHandler: tenantHandler,
+		tenantHandler:    tenantHandler,
+		fileHandler:      fileHandler,
+		auditHandler:     auditHandler,
+		slowQueryHandler: slowQueryHandler,
+		verifier:         signature.NewVerifier(tenantSrv.SignSecret, opts...),
 	}
 }
 
 func (s *Server) Routes(group *gin.RouterGroup) {
-	authGroup := group.Group("/tenant")
+	authGroup := group.Group("/tenant", auditActor("anonymous"))
 	{
 		authGroup.POST("/create", s.tenantHandler.Create)
 	}
+	// 机器间调用，需携带 HMAC 请求签名
+	signedGroup := group.Group("/tenant", middleware.Signature(s.verifier), tenantScope)
+	{
+		signedGroup.GET("/info", s.tenantHandler.Info)
+	}
+	fileGroup := group.Group("/files", middleware.Signature(s.verifier), tenantScope)
+	{
+		fileGroup.POST("", s.fileHandler.Upload)
+		fileGroup.GET("/:id", s.fileHandler.Get)
+		fileGroup.DELETE("/:id", s.fileHandler.Delete)
+		fileGroup.POST("/uploads", s.fileHandler.CreateUpload)
+		fileGroup.GET("/uploads/:uploadId", s.fileHandler.UploadStatus)
+		fileGroup.PUT("/uploads/:uploadId/chunks/:index", s.fileHandler.UploadChunk)
+		fileGroup.POST("/uploads/:uploadId/complete", s.fileHandler.CompleteUpload)
+		fileGroup.DELETE("/uploads/:uploadId", s.fileHandler.AbortUpload)
+	}
+	// 下载链接自带签名和过期时间，无需请求签名
+	group.GET("/files/download/:id", s.fileHandler.Download)
+}
+
+// AdminRoutes 注册在 /admin 下，由管理令牌鉴权
+func (s *Server) AdminRoutes(group *gin.RouterGroup) {
+	tenantGroup := group.Group("/tenants", auditActor("admin"))
+	{
+		tenantGroup.GET("/:id", s.tenantHandler.Get)
+		tenantGroup.PUT("/:id", s.tenantHandler.Update)
+	}
+	group.GET("/audit", s.auditHandler.List)
+	dbGroup := group.Group("/database")
+	{
+		dbGroup.GET("/slow-queries", s.slowQueryHandler.List)
+		dbGroup.DELETE("/slow-queries", s.slowQueryHandler.Reset)
+	}
+}
+
+// auditActor 将操作者和客户端IP写入请求上下文，随数据变更记录到审计日志
+func auditActor(actor string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(audit.ContextKeyActor, actor)
+		ctx.Set(audit.ContextKeyClientIP, ctx.ClientIP())
+		ctx.Next()
+	}
+}
+
+// tenantScope 将签名调用方（租户）写入请求上下文，数据库读写按该租户隔离
+func tenantScope(ctx *gin.Context) {
+	id, err := strconv.ParseUint(middleware.SignatureKeyID(ctx), 10, 64)
+	if err != nil || id == 0 {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeUnauthorized, "invalid signature key"))
+		ctx.Abort()
+		return
+	}
+	ctx.Set(database.ContextKeyTenantID, uint(id))
+	ctx.Set(audit.ContextKeyActor, "tenant:"+strconv.FormatUint(id, 10))
+	ctx.Set(audit.ContextKeyClientIP, ctx.ClientIP())
+	ctx.Next()
 }
 
 func (s *Server) Start(ctx context.Context) error {
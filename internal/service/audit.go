@@ -0,0 +1,49 @@
+/*
+Copyright © 2025 lixw
+*/
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ethanli-dev/go-app-layout/api"
+	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
+	"github.com/ethanli-dev/go-app-layout/internal/repository"
+	"github.com/ethanli-dev/go-app-layout/pkg/audit"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+)
+
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditService(auditRepo *repository.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+	}
+}
+
+func (as *AuditService) List(ctx context.Context, req *v1.AuditListRequest) (*api.PageResult[*audit.Event], error) {
+	if req.EntityID != "" && req.Entity == "" {
+		return nil, errorx.New(errorx.ErrCodeValidation, "entity is required with entity_id")
+	}
+	filter := audit.Filter{
+		Entity:   req.Entity,
+		EntityID: req.EntityID,
+		TenantID: req.Tenant,
+		Actor:    req.Actor,
+		Action:   req.Action,
+	}
+	// 租户独立存储时其审计记录在租户库中
+	if req.Tenant > 0 {
+		ctx = database.WithTenant(ctx, req.Tenant)
+	}
+	events, total, err := as.auditRepo.Page(ctx, filter, req.Offset(), req.Limit())
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to query audit log", "err", err)
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to query audit log")
+	}
+	return api.NewPageResult(events, req.GetPage(), req.GetPageSize(), int(total)), nil
+}
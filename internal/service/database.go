@@ -0,0 +1,36 @@
+/*
+Copyright © 2025 lixw
+*/
+package service
+
+import (
+	"context"
+
+	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
+	"github.com/ethanli-dev/go-app-layout/internal/repository"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+)
+
+type SlowQueryService struct {
+	slowQueryRepo *repository.SlowQueryRepository
+}
+
+func NewSlowQueryService(slowQueryRepo *repository.SlowQueryRepository) *SlowQueryService {
+	return &SlowQueryService{
+		slowQueryRepo: slowQueryRepo,
+	}
+}
+
+// List 慢查询报告，按总耗时降序，包含共享库和租户库
+func (ss *SlowQueryService) List(_ context.Context, req *v1.SlowQueryListRequest) []database.SlowQuery {
+	queries := ss.slowQueryRepo.List()
+	if req.Limit > 0 && len(queries) > req.Limit {
+		queries = queries[:req.Limit]
+	}
+	return queries
+}
+
+// Reset 清空统计，通常在添加索引后调用以观察效果
+func (ss *SlowQueryService) Reset(_ context.Context) {
+	ss.slowQueryRepo.Reset()
+}
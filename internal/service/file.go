@@ -0,0 +1,499 @@
+/*
+Copyright © 2025 lixw
+*/
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
+	"github.com/ethanli-dev/go-app-layout/internal/model"
+	"github.com/ethanli-dev/go-app-layout/internal/repository"
+	"github.com/ethanli-dev/go-app-layout/pkg/config"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/signature"
+	"github.com/ethanli-dev/go-app-layout/pkg/storage"
+)
+
+// sniffLen http.DetectContentType 使用的最大字节数
+const sniffLen = 512
+
+type FileService struct {
+	tx           *database.TxManager
+	fileRepo     *repository.FileRepository
+	storage      storage.Storage
+	urlSigner    *storage.URLSigner
+	maxFileSize  int64
+	tenantQuota  int64
+	chunkSize    int64
+	allowedTypes []string
+	urlExpiry    time.Duration
+}
+
+func NewFileService(cfg *config.Config, tx *database.TxManager, fileRepo *repository.FileRepository, store storage.Storage) *FileService {
+	s := &FileService{
+		tx:          tx,
+		fileRepo:    fileRepo,
+		storage:     store,
+		maxFileSize: 100 << 20,
+		chunkSize:   5 << 20,
+		urlExpiry:   15 * time.Minute,
+	}
+	var urlSecret string
+	if sc := cfg.Storage; sc != nil {
+		if sc.MaxFileSize > 0 {
+			s.maxFileSize = sc.MaxFileSize
+		}
+		if sc.ChunkSize > 0 {
+			s.chunkSize = sc.ChunkSize
+		}
+		if sc.URLExpiry > 0 {
+			s.urlExpiry = sc.URLExpiry
+		}
+		s.tenantQuota = sc.TenantQuota
+		s.allowedTypes = sc.AllowedTypes
+		urlSecret = sc.URLSecret
+	}
+	if urlSecret == "" {
+		slog.Warn("storage url secret is not set, download urls will be invalid after restart")
+	}
+	s.urlSigner = storage.NewURLSigner([]byte(urlSecret))
+	return s
+}
+
+// MaxFileSize 返回单个文件大小上限
+func (fs *FileService) MaxFileSize() int64 {
+	return fs.maxFileSize
+}
+
+// Upload 上传单个文件，按内容识别文件类型并校验大小、类型和租户配额；
+// 写入存储前先检查一次配额，超出时无需上传，保存元数据时再在事务中检查
+func (fs *FileService) Upload(ctx context.Context, tenantID uint, name string, r io.Reader, size int64) (*model.File, error) {
+	if err := fs.validateSize(size); err != nil {
+		return nil, err
+	}
+	if err := fs.checkQuota(ctx, tenantID, size); err != nil {
+		return nil, err
+	}
+	return fs.store(ctx, tenantID, name, r, size, func(ctx context.Context, file *model.File) error {
+		return fs.withQuota(ctx, tenantID, size, func(ctx context.Context) error {
+			return fs.fileRepo.Create(ctx, file)
+		})
+	})
+}
+
+func (fs *FileService) Get(ctx context.Context, tenantID, id uint) (*model.File, error) {
+	file, err := fs.fileRepo.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrFileNotFound) {
+			return nil, errorx.Wrap(err, errorx.ErrCodeNotFound, "file not found")
+		}
+		slog.ErrorContext(ctx, "failed to get file", "id", id, "err", err)
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to get file")
+	}
+	if file.TenantID != tenantID {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "file not found")
+	}
+	return file, nil
+}
+
+func (fs *FileService) Delete(ctx context.Context, tenantID, id uint) error {
+	file, err := fs.Get(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if err := fs.fileRepo.Delete(ctx, file.ID); err != nil {
+		slog.ErrorContext(ctx, "failed to delete file", "id", id, "err", err)
+		return errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to delete file")
+	}
+	if err := fs.storage.Delete(ctx, file.StorageKey); err != nil {
+		slog.ErrorContext(ctx, "failed to delete file object", "id", id, "key", file.StorageKey, "err", err)
+	}
+	return nil
+}
+
+// DownloadURL 为文件生成带过期时间的下载签名
+func (fs *FileService) DownloadURL(file *model.File) (expires string, sig string) {
+	return fs.urlSigner.Sign(fileResource(file.TenantID, file.ID), fs.urlExpiry)
+}
+
+// Open 校验下载签名并打开文件内容，调用方负责关闭
+func (fs *FileService) Open(ctx context.Context, tenantID, id uint, expires, sig string) (*model.File, io.ReadCloser, error) {
+	if err := fs.urlSigner.Verify(fileResource(tenantID, id), expires, sig); err != nil {
+		return nil, nil, errorx.Wrap(err, errorx.ErrCodeForbidden, "invalid or expired download url")
+	}
+	// 签名绑定了租户和文件ID，按链接中的租户读取，独立存储的租户路由到其租户库
+	file, err := fs.fileRepo.GetById(database.WithTenant(ctx, tenantID), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrFileNotFound) {
+			return nil, nil, errorx.Wrap(err, errorx.ErrCodeNotFound, "file not found")
+		}
+		return nil, nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to get file")
+	}
+	rc, _, err := fs.storage.Get(ctx, file.StorageKey)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to open file object", "id", id, "key", file.StorageKey, "err", err)
+		return nil, nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to open file")
+	}
+	return file, rc, nil
+}
+
+// CreateUpload 创建分片上传会话，会话大小计入租户配额直至完成或取消
+func (fs *FileService) CreateUpload(ctx context.Context, tenantID uint, req *v1.UploadRequest) (*model.UploadSession, error) {
+	if req.Name == "" {
+		return nil, errorx.New(errorx.ErrCodeValidation, "name is required")
+	}
+	if err := fs.validateSize(req.Size); err != nil {
+		return nil, err
+	}
+	uploadID, err := randomHex(16)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to create upload")
+	}
+	session := &model.UploadSession{
+		TenantModel: database.TenantModel{TenantID: tenantID},
+		UploadID:    uploadID,
+		Name:        req.Name,
+		Size:        req.Size,
+		ChunkSize:   fs.chunkSize,
+		TotalChunks: int((req.Size + fs.chunkSize - 1) / fs.chunkSize),
+	}
+	err = fs.withQuota(ctx, tenantID, req.Size, func(ctx context.Context) error {
+		return fs.fileRepo.CreateSession(ctx, session)
+	})
+	if err != nil {
+		var e *errorx.WrappedError
+		if errors.As(err, &e) {
+			return nil, err
+		}
+		slog.ErrorContext(ctx, "failed to create upload session", "err", err)
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to create upload")
+	}
+	return session, nil
+}
+
+// UploadChunk 上传指定序号的分片，重复上传会覆盖，用于断点续传
+func (fs *FileService) UploadChunk(ctx context.Context, tenantID uint, uploadID string, index int, r io.Reader) error {
+	session, err := fs.getSession(ctx, tenantID, uploadID)
+	if err != nil {
+		return err
+	}
+	if session.Completing {
+		return errorx.New(errorx.ErrCodeConflict, "upload is being completed")
+	}
+	if index < 0 || index >= session.TotalChunks {
+		return errorx.New(errorx.ErrCodeValidation, "chunk index must be between 0 and %d", session.TotalChunks-1)
+	}
+	expected := session.ChunkSize
+	if index == session.TotalChunks-1 {
+		expected = session.Size - int64(index)*session.ChunkSize
+	}
+	buf, err := io.ReadAll(io.LimitReader(r, expected+1))
+	if err != nil {
+		return fs.readError(err)
+	}
+	if int64(len(buf)) != expected {
+		return errorx.New(errorx.ErrCodeValidation, "chunk %d must be %d bytes", index, expected)
+	}
+	if err := fs.storage.Put(ctx, chunkKey(uploadID, index), bytes.NewReader(buf), expected, ""); err != nil {
+		slog.ErrorContext(ctx, "failed to store chunk", "upload_id", uploadID, "index", index, "err", err)
+		return errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to store chunk")
+	}
+	return nil
+}
+
+// UploadStatus 返回已上传的分片序号，客户端据此续传缺失的分片
+func (fs *FileService) UploadStatus(ctx context.Context, tenantID uint, uploadID string) (*v1.UploadStatusResponse, error) {
+	session, err := fs.getSession(ctx, tenantID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	received := make([]int, 0, session.TotalChunks)
+	for i := 0; i < session.TotalChunks; i++ {
+		if _, err := fs.storage.Stat(ctx, chunkKey(uploadID, i)); err == nil {
+			received = append(received, i)
+		} else if !errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to get upload status")
+		}
+	}
+	return &v1.UploadStatusResponse{UploadSession: session, Received: received}, nil
+}
+
+// CompleteUpload 校验分片完整后按序合并为文件；合并前先标记会话，同一会话只有一个请求能合并
+func (fs *FileService) CompleteUpload(ctx context.Context, tenantID uint, uploadID string) (*model.File, error) {
+	status, err := fs.UploadStatus(ctx, tenantID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	session := status.UploadSession
+	if len(status.Received) != session.TotalChunks {
+		return nil, errorx.New(errorx.ErrCodeValidation, "%d of %d chunks uploaded", len(status.Received), session.TotalChunks)
+	}
+	if err := fs.claimSession(ctx, uploadID); err != nil {
+		return nil, err
+	}
+	r := &chunkReader{ctx: ctx, storage: fs.storage, uploadID: uploadID, total: session.TotalChunks}
+	defer r.Close()
+	// 会话已预占配额，删除会话与保存文件在同一事务中，配额由会话转给文件
+	file, err := fs.store(ctx, tenantID, session.Name, r, session.Size, func(ctx context.Context, file *model.File) error {
+		return fs.tx.Do(ctx, func(ctx context.Context) error {
+			if err := fs.fileRepo.DeleteSession(ctx, uploadID); err != nil {
+				return err
+			}
+			return fs.fileRepo.Create(ctx, file)
+		})
+	})
+	if err != nil {
+		if err := fs.fileRepo.ReleaseSession(ctx, uploadID); err != nil {
+			slog.ErrorContext(ctx, "failed to release upload session", "upload_id", uploadID, "err", err)
+		}
+		return nil, err
+	}
+	fs.deleteChunks(ctx, session)
+	return file, nil
+}
+
+// AbortUpload 取消分片上传并释放预占的配额，合并中的会话不能取消
+func (fs *FileService) AbortUpload(ctx context.Context, tenantID uint, uploadID string) error {
+	session, err := fs.getSession(ctx, tenantID, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := fs.claimSession(ctx, uploadID); err != nil {
+		return err
+	}
+	if err := fs.fileRepo.DeleteSession(ctx, uploadID); err != nil {
+		slog.ErrorContext(ctx, "failed to delete upload session", "upload_id", uploadID, "err", err)
+	}
+	fs.deleteChunks(ctx, session)
+	return nil
+}
+
+// claimSession 标记会话为合并中，已被其他请求标记时返回冲突
+func (fs *FileService) claimSession(ctx context.Context, uploadID string) error {
+	claimed, err := fs.fileRepo.ClaimSession(ctx, uploadID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to claim upload session", "upload_id", uploadID, "err", err)
+		return errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to complete upload")
+	}
+	if !claimed {
+		return errorx.New(errorx.ErrCodeConflict, "upload is being completed")
+	}
+	return nil
+}
+
+func (fs *FileService) getSession(ctx context.Context, tenantID uint, uploadID string) (*model.UploadSession, error) {
+	session, err := fs.fileRepo.GetSession(ctx, uploadID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUploadSessionNotFound) {
+			return nil, errorx.Wrap(err, errorx.ErrCodeNotFound, "upload not found")
+		}
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to get upload")
+	}
+	if session.TenantID != tenantID {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "upload not found")
+	}
+	return session, nil
+}
+
+func (fs *FileService) deleteChunks(ctx context.Context, session *model.UploadSession) {
+	for i := 0; i < session.TotalChunks; i++ {
+		if err := fs.storage.Delete(ctx, chunkKey(session.UploadID, i)); err != nil {
+			slog.ErrorContext(ctx, "failed to delete chunk", "upload_id", session.UploadID, "index", i, "err", err)
+		}
+	}
+}
+
+// store 识别文件类型，写入存储的同时计算 SHA256，最后调用 save 保存元数据，保存失败时删除已写入的对象
+func (fs *FileService) store(ctx context.Context, tenantID uint, name string, r io.Reader, size int64, save func(ctx context.Context, file *model.File) error) (*model.File, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fs.readError(err)
+	}
+	contentType := http.DetectContentType(head[:n])
+	if !fs.allowedType(contentType) {
+		return nil, errorx.New(errorx.ErrCodeValidation, "file type %s is not allowed", contentType)
+	}
+	key, err := objectKey(tenantID)
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to store file")
+	}
+	hasher := sha256.New()
+	body := io.TeeReader(io.MultiReader(bytes.NewReader(head[:n]), r), hasher)
+	if err := fs.storage.Put(ctx, key, body, size, contentType); err != nil {
+		if errors.Is(err, signature.ErrBodyHashMismatch) {
+			return nil, fs.readError(err)
+		}
+		slog.ErrorContext(ctx, "failed to store file", "key", key, "err", err)
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to store file")
+	}
+	file := &model.File{
+		TenantModel: database.TenantModel{TenantID: tenantID},
+		Name:        name,
+		StorageKey:  key,
+		Size:        size,
+		ContentType: contentType,
+		Checksum:    hex.EncodeToString(hasher.Sum(nil)),
+	}
+	if err := save(ctx, file); err != nil {
+		if err := fs.storage.Delete(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "failed to delete orphaned file object", "key", key, "err", err)
+		}
+		var e *errorx.WrappedError
+		if errors.As(err, &e) {
+			return nil, err
+		}
+		slog.ErrorContext(ctx, "failed to create file", "err", err)
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to create file")
+	}
+	return file, nil
+}
+
+func (fs *FileService) readError(err error) error {
+	if errors.Is(err, signature.ErrBodyHashMismatch) {
+		return errorx.Wrap(err, errorx.ErrCodeBadRequest, "file does not match signed hash")
+	}
+	return errorx.Wrap(err, errorx.ErrCodeBadRequest, "failed to read file")
+}
+
+func (fs *FileService) validateSize(size int64) error {
+	if size <= 0 {
+		return errorx.New(errorx.ErrCodeValidation, "file is empty")
+	}
+	if size > fs.maxFileSize {
+		return errorx.New(errorx.ErrCodeValidation, "file exceeds max size of %d bytes", fs.maxFileSize)
+	}
+	return nil
+}
+
+func (fs *FileService) checkQuota(ctx context.Context, tenantID uint, size int64) error {
+	if fs.tenantQuota <= 0 {
+		return nil
+	}
+	used, err := fs.fileRepo.TenantUsage(ctx, tenantID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get tenant storage usage", "tenant_id", tenantID, "err", err)
+		return errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to check storage quota")
+	}
+	if used+size > fs.tenantQuota {
+		return errorx.New(errorx.ErrCodeForbidden, "storage quota exceeded: %d of %d bytes used", used, fs.tenantQuota)
+	}
+	return nil
+}
+
+// withQuota 锁定租户后检查配额并执行 fn，检查与写入在同一事务中，并发上传不会超出配额
+func (fs *FileService) withQuota(ctx context.Context, tenantID uint, size int64, fn func(ctx context.Context) error) error {
+	if fs.tenantQuota <= 0 {
+		return fn(ctx)
+	}
+	return fs.tx.Do(ctx, func(ctx context.Context) error {
+		if err := fs.fileRepo.LockTenant(ctx, tenantID); err != nil {
+			slog.ErrorContext(ctx, "failed to lock tenant storage quota", "tenant_id", tenantID, "err", err)
+			return errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to check storage quota")
+		}
+		if err := fs.checkQuota(ctx, tenantID, size); err != nil {
+			return err
+		}
+		return fn(ctx)
+	})
+}
+
+// allowedType 按媒体类型匹配，规则以 / 结尾时按前缀匹配
+func (fs *FileService) allowedType(contentType string) bool {
+	if len(fs.allowedTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range fs.allowedTypes {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+func fileResource(tenantID, id uint) string {
+	return "files/" + strconv.FormatUint(uint64(tenantID), 10) + "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// objectKey 生成 tenants/{tenantID}/{yyyy}/{mm}/{random} 格式的存储路径
+func objectKey(tenantID uint) (string, error) {
+	name, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tenants/%d/%s/%s", tenantID, time.Now().Format("2006/01"), name), nil
+}
+
+func chunkKey(uploadID string, index int) string {
+	return fmt.Sprintf("uploads/%s/%d", uploadID, index)
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// chunkReader 按序依次读取各分片，避免同时打开所有分片
+type chunkReader struct {
+	ctx      context.Context
+	storage  storage.Storage
+	uploadID string
+	total    int
+	index    int
+	current  io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.index >= r.total {
+				return 0, io.EOF
+			}
+			rc, _, err := r.storage.Get(r.ctx, chunkKey(r.uploadID, r.index))
+			if err != nil {
+				return 0, err
+			}
+			r.current = rc
+			r.index++
+		}
+		n, err := r.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			_ = r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
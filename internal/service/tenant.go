@@ -10,14 +10,19 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"io"
 	"log/slog"
+	"strconv"
 
 	v1 "github.com/ethanli-dev/go-app-layout/api/v1"
 	"github.com/ethanli-dev/go-app-layout/internal/model"
 	"github.com/ethanli-dev/go-app-layout/internal/repository"
 	"github.com/ethanli-dev/go-app-layout/pkg/config"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
 	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/outbox"
 )
 
 var apiKeySecret = func() []byte {
@@ -25,12 +30,16 @@ var apiKeySecret = func() []byte {
 }
 
 type TenantService struct {
+	tx         *database.TxManager
 	tenantRepo *repository.TenantRepository
+	outbox     *outbox.Writer
 }
 
-func NewTenantService(tenantRepo *repository.TenantRepository) *TenantService {
+func NewTenantService(tx *database.TxManager, tenantRepo *repository.TenantRepository, outbox *outbox.Writer) *TenantService {
 	return &TenantService{
+		tx:         tx,
 		tenantRepo: tenantRepo,
+		outbox:     outbox,
 	}
 }
 
@@ -38,26 +47,123 @@ func (tr *TenantService) Create(ctx context.Context, req *v1.TenantRequest) (*mo
 	if req.Name == "" {
 		return nil, errorx.New(errorx.ErrCodeValidation, "name is required")
 	}
+	signSecret, err := generateSignSecret()
+	if err != nil {
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to generate sign secret")
+	}
 	tenant := &model.Tenant{
 		Name:        req.Name,
 		Description: req.Description,
+		SignSecret:  signSecret,
+	}
+	// API密钥由租户ID生成，插入、写入密钥和创建事件在同一事务中，避免留下没有密钥的租户或丢失事件
+	err = tr.tx.Do(ctx, func(ctx context.Context) error {
+		if err := tr.tenantRepo.Create(ctx, tenant); err != nil {
+			slog.ErrorContext(ctx, "failed to create tenant", "err", err)
+			return errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to create tenant")
+		}
+		key, err := tr.generateApiKey(tenant.ID)
+		if err != nil {
+			return errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to generate api key")
+		}
+		tenant.ApiKey = key
+		if err := tr.tenantRepo.Update(ctx, tenant); err != nil {
+			slog.ErrorContext(ctx, "failed to update tenant", "err", err)
+			return errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to update tenant")
+		}
+		event := &v1.TenantCreatedEvent{ID: tenant.ID, Name: tenant.Name}
+		if err := tr.outbox.Add(ctx, "tenant", strconv.FormatUint(uint64(tenant.ID), 10), v1.TopicTenantCreated, event); err != nil {
+			slog.ErrorContext(ctx, "failed to add tenant event", "err", err)
+			return errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to create tenant")
+		}
+		return nil
+	})
+	if err != nil {
+		var e *errorx.WrappedError
+		if !errors.As(err, &e) {
+			slog.ErrorContext(ctx, "failed to commit tenant", "err", err)
+			err = errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to create tenant")
+		}
+		return nil, err
 	}
-	if err := tr.tenantRepo.Create(ctx, tenant); err != nil {
-		slog.ErrorContext(ctx, "failed to create tenant", "err", err)
-		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to create tenant")
+	return tenant, nil
+}
+
+func (tr *TenantService) Get(ctx context.Context, id uint) (*model.Tenant, error) {
+	tenant, err := tr.tenantRepo.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrTenantNotFound) {
+			return nil, errorx.Wrap(err, errorx.ErrCodeNotFound, "tenant not found")
+		}
+		slog.ErrorContext(ctx, "failed to get tenant", "id", id, "err", err)
+		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to get tenant")
 	}
-	key, err := tr.generateApiKey(tenant.ID)
+	return tenant, nil
+}
+
+// Update 按请求更新租户，version 为客户端读取时的版本号，为 0 时使用本次读取的版本号；
+// 版本不一致时返回 ErrCodeConflict 错误
+func (tr *TenantService) Update(ctx context.Context, id, version uint, req *v1.TenantUpdateRequest) (*model.Tenant, error) {
+	tenant, err := tr.Get(ctx, id)
 	if err != nil {
-		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to generate api key")
+		return nil, err
+	}
+	if version != 0 && version != tenant.Version {
+		return nil, database.ErrVersionConflict
+	}
+	var fields []string
+	if req.Name != nil {
+		if *req.Name == "" {
+			return nil, errorx.New(errorx.ErrCodeValidation, "name is required")
+		}
+		tenant.Name = *req.Name
+		fields = append(fields, "name")
+	}
+	if req.Description != nil {
+		tenant.Description = *req.Description
+		fields = append(fields, "description")
 	}
-	tenant.ApiKey = key
-	if err := tr.tenantRepo.Update(ctx, tenant); err != nil {
-		slog.ErrorContext(ctx, "failed to update tenant", "err", err)
+	if len(fields) == 0 {
+		return tenant, nil
+	}
+	if err := tr.tenantRepo.Update(ctx, tenant, fields...); err != nil {
+		if errors.Is(err, database.ErrVersionConflict) {
+			return nil, database.ErrVersionConflict
+		}
+		slog.ErrorContext(ctx, "failed to update tenant", "id", id, "err", err)
 		return nil, errorx.Wrap(err, errorx.ErrCodeInternalServer, "failed to update tenant")
 	}
 	return tenant, nil
 }
 
+// SignSecret 根据 keyID（租户ID）返回请求签名密钥，供签名校验使用；已禁用的租户不能通过签名校验
+func (tr *TenantService) SignSecret(ctx context.Context, keyID string) ([]byte, error) {
+	id, err := strconv.ParseUint(keyID, 10, 64)
+	if err != nil {
+		return nil, repository.ErrTenantNotFound
+	}
+	tenant, err := tr.tenantRepo.GetById(ctx, uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if tenant.Status == 0 {
+		return nil, errors.New("tenant is disabled")
+	}
+	if tenant.SignSecret == "" {
+		return nil, errors.New("tenant has no sign secret")
+	}
+	return []byte(tenant.SignSecret), nil
+}
+
+// generateSignSecret 生成32字节随机签名密钥，十六进制编码
+func generateSignSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
 // generateApiKey generates a secure API key for tenant authentication
 func (tr *TenantService) generateApiKey(tenantID uint) (string, error) {
 	// 1. Convert tenant_id to bytes
@@ -5,4 +5,4 @@ package service
 
 import "github.com/google/wire"
 
-var ProviderSet = wire.NewSet(NewTenantService)
+var ProviderSet = wire.NewSet(NewTenantService, NewFileService, NewAuditService, NewSlowQueryService)
@@ -0,0 +1,41 @@
+/*
+Copyright © 2025 lixw
+*/
+package webtest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/internal/migration"
+	"github.com/ethanli-dev/go-app-layout/internal/repository"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+)
+
+// NewDB 创建 SQLite 临时库并执行全部迁移，安装与线上一致的 repository.Plugins()，
+// plugins 追加在其后，如加密字段所需的 encrypt.New(keyring)
+func NewDB(t testing.TB, plugins ...gorm.Plugin) *gorm.DB {
+	t.Helper()
+	db, err := database.New(
+		database.WithUrl("sqlite://"+filepath.Join(t.TempDir(), "app.db")),
+		database.WithPlugins(append(repository.Plugins(), plugins...)...),
+	)
+	if err != nil {
+		t.Fatalf("webtest: failed to open db: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	m, err := migration.New(db)
+	if err != nil {
+		t.Fatalf("webtest: failed to create migrator: %v", err)
+	}
+	if _, err := m.Up(context.Background(), 0); err != nil {
+		t.Fatalf("webtest: failed to migrate: %v", err)
+	}
+	return db
+}
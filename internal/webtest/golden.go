@@ -0,0 +1,87 @@
+/*
+Copyright © 2025 lixw
+*/
+package webtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// EnvUpdateGolden 设置为 1 时 Golden 重写快照文件而不做比较
+const EnvUpdateGolden = "UPDATE_GOLDEN"
+
+// volatileFields 每次请求都会变化的字段，快照中替换为占位符
+var volatileFields = []string{"timestamp", "trace_id", "traceId"}
+
+// Golden 将响应状态码和响应体与快照文件 {goldenDir}/{name}.golden 比较，
+// JSON 响应体格式化后比较，timestamp、trace_id 及 ignoreFields 中的字段不参与比较
+func (r *Response) Golden(name string, ignoreFields ...string) *Response {
+	r.t.Helper()
+	got := r.snapshot(append(ignoreFields, volatileFields...))
+	file := filepath.Join(r.goldenDir, name+".golden")
+	if os.Getenv(EnvUpdateGolden) == "1" {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			r.t.Fatalf("webtest: failed to create golden dir: %v", err)
+		}
+		if err := os.WriteFile(file, got, 0o644); err != nil {
+			r.t.Fatalf("webtest: failed to write golden file: %v", err)
+		}
+		return r
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		r.t.Fatalf("webtest: failed to read golden file, run with %s=1 to create it: %v", EnvUpdateGolden, err)
+	}
+	if !bytes.Equal(got, want) {
+		r.t.Errorf("%s: response does not match %s\n--- got\n%s\n--- want\n%s", r.request, file, got, want)
+	}
+	return r
+}
+
+// snapshot 快照内容：请求行、状态码、Content-Type 和响应体
+func (r *Response) snapshot(ignoreFields []string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\nstatus: %d\ncontent-type: %s\n\n", r.request, r.ResponseRecorder.Code, r.Header().Get("Content-Type"))
+	body := r.Body.Bytes()
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err == nil && !dec.More() {
+		var out bytes.Buffer
+		enc := json.NewEncoder(&out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(maskFields(v, ignoreFields)); err == nil {
+			body = out.Bytes()
+		}
+	}
+	buf.Write(body)
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// maskFields 递归替换对象中指定字段的值，字段不存在时不做处理
+func maskFields(v any, fields []string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if slices.Contains(fields, k) {
+				val[k] = "<" + k + ">"
+				continue
+			}
+			val[k] = maskFields(item, fields)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = maskFields(item, fields)
+		}
+	}
+	return v
+}
@@ -0,0 +1,162 @@
+/*
+Copyright © 2025 lixw
+*/
+package webtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/signature"
+)
+
+// Request 请求构建器，通过 Send 发送并返回响应
+type Request struct {
+	c           *Client
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        []byte
+	contentType string
+	signer      *signature.Signer
+	err         error
+}
+
+func (c *Client) Request(method, path string) *Request {
+	return &Request{
+		c:      c,
+		method: method,
+		path:   path,
+		query:  make(url.Values),
+		header: c.headers.Clone(),
+	}
+}
+
+func (c *Client) GET(path string) *Request {
+	return c.Request(http.MethodGet, path)
+}
+
+func (c *Client) POST(path string) *Request {
+	return c.Request(http.MethodPost, path)
+}
+
+func (c *Client) PUT(path string) *Request {
+	return c.Request(http.MethodPut, path)
+}
+
+func (c *Client) PATCH(path string) *Request {
+	return c.Request(http.MethodPatch, path)
+}
+
+func (c *Client) DELETE(path string) *Request {
+	return c.Request(http.MethodDelete, path)
+}
+
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// Bearer 设置 Authorization: Bearer {token}，用于管理接口等
+func (r *Request) Bearer(token string) *Request {
+	return r.Header("Authorization", "Bearer "+token)
+}
+
+// Accept 设置期望的响应媒体类型
+func (r *Request) Accept(mediaType string) *Request {
+	return r.Header("Accept", mediaType)
+}
+
+// Body 设置原始请求体
+func (r *Request) Body(contentType string, body []byte) *Request {
+	r.contentType = contentType
+	r.body = body
+	return r
+}
+
+// JSON 将 v 编码为 JSON 请求体
+func (r *Request) JSON(v any) *Request {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+	}
+	return r.Body("application/json", buf)
+}
+
+// Form 设置 application/x-www-form-urlencoded 请求体
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// File 设置 multipart/form-data 请求体，包含一个文件字段和若干普通字段
+func (r *Request) File(field, filename string, content []byte, fields map[string]string) *Request {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			r.err = err
+		}
+	}
+	part, err := w.CreateFormFile(field, filename)
+	if err != nil {
+		r.err = err
+	} else if _, err := part.Write(content); err != nil {
+		r.err = err
+	}
+	if err := w.Close(); err != nil {
+		r.err = err
+	}
+	return r.Body(w.FormDataContentType(), buf.Bytes())
+}
+
+// Sign 发送前使用 HMAC 请求签名，用于机器间调用的路由
+func (r *Request) Sign(signer *signature.Signer) *Request {
+	r.signer = signer
+	return r
+}
+
+// Send 经完整中间件链处理请求，构建或签名失败时终止测试
+func (r *Request) Send() *Response {
+	t := r.c.t
+	t.Helper()
+	if r.err != nil {
+		t.Fatalf("webtest: failed to build request %s %s: %v", r.method, r.path, r.err)
+	}
+	target := r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req := httptest.NewRequest(r.method, target, body)
+	req.Header = r.header.Clone()
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if r.signer != nil {
+		if err := r.signer.Sign(req); err != nil {
+			t.Fatalf("webtest: failed to sign request %s %s: %v", r.method, r.path, err)
+		}
+	}
+	rec := httptest.NewRecorder()
+	r.c.server.ServeHTTP(rec, req)
+	return &Response{
+		ResponseRecorder: rec,
+		t:                t,
+		request:          r.method + " " + target,
+		goldenDir:        r.c.goldenDir,
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2025 lixw
+*/
+package webtest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/api"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+)
+
+// Response 响应断言，断言失败时标记测试失败并继续执行
+type Response struct {
+	*httptest.ResponseRecorder
+	t         testing.TB
+	request   string
+	goldenDir string
+}
+
+// envelope 兼容统一响应和 problem+json 的错误码和消息
+type envelope struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail"`
+}
+
+func (r *Response) envelope() (*envelope, bool) {
+	r.t.Helper()
+	var e envelope
+	if err := json.Unmarshal(r.Body.Bytes(), &e); err != nil {
+		r.t.Errorf("%s: failed to decode response body: %v\n%s", r.request, err, r.Body.String())
+		return nil, false
+	}
+	if e.Message == "" {
+		e.Message = e.Detail
+	}
+	return &e, true
+}
+
+// Status 断言HTTP状态码
+func (r *Response) Status(status int) *Response {
+	r.t.Helper()
+	if r.ResponseRecorder.Code != status {
+		r.t.Errorf("%s: status = %d, want %d\n%s", r.request, r.ResponseRecorder.Code, status, r.Body.String())
+	}
+	return r
+}
+
+// HasHeader 断言响应头的值
+func (r *Response) HasHeader(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Header().Get(key); got != value {
+		r.t.Errorf("%s: header %s = %q, want %q", r.request, key, got, value)
+	}
+	return r
+}
+
+// Code 断言响应体中的错误码，同时支持 problem+json 响应
+func (r *Response) Code(code int) *Response {
+	r.t.Helper()
+	if e, ok := r.envelope(); ok && e.Code != code {
+		r.t.Errorf("%s: code = %d, want %d (message: %s)", r.request, e.Code, code, e.Message)
+	}
+	return r
+}
+
+// OK 断言业务处理成功
+func (r *Response) OK() *Response {
+	r.t.Helper()
+	return r.Code(errorx.ErrCodeSuccess)
+}
+
+// Message 断言响应消息，problem+json 响应断言 detail
+func (r *Response) Message(message string) *Response {
+	r.t.Helper()
+	if e, ok := r.envelope(); ok && e.Message != message {
+		r.t.Errorf("%s: message = %q, want %q", r.request, e.Message, message)
+	}
+	return r
+}
+
+// Decode 将响应体解码为 api.Response[T]，解码失败时终止测试
+func Decode[T any](r *Response) *api.Response[T] {
+	r.t.Helper()
+	var resp api.Response[T]
+	if err := json.Unmarshal(r.Body.Bytes(), &resp); err != nil {
+		r.t.Fatalf("%s: failed to decode response body: %v\n%s", r.request, err, r.Body.String())
+	}
+	return &resp
+}
+
+// Data 解码并返回响应数据
+func Data[T any](r *Response) T {
+	r.t.Helper()
+	return Decode[T](r).Data
+}
@@ -0,0 +1,157 @@
+/*
+Copyright © 2025 lixw
+*/
+
+// Package webtest 基于完整中间件链和应用路由的处理器测试工具
+//
+//	c := webtest.New(t, webtest.WithServer(webtest.NewServer(cfg, webtest.NewDB(t), store)))
+//	resp := c.POST("/v1/tenant/create").JSON(req).Send().Status(http.StatusOK).OK()
+//	tenant := webtest.Data[*model.Tenant](resp)
+//	c.GET("/v1/tenant/info").Sign(signer).Send().Golden("tenant_info", "api_key", "created_at", "updated_at")
+package webtest
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/internal/server"
+	"github.com/ethanli-dev/go-app-layout/locales"
+	"github.com/ethanli-dev/go-app-layout/pkg/config"
+	"github.com/ethanli-dev/go-app-layout/pkg/i18n"
+	"github.com/ethanli-dev/go-app-layout/pkg/logging"
+	"github.com/ethanli-dev/go-app-layout/pkg/web"
+	"github.com/gin-gonic/gin"
+)
+
+var i18nOnce sync.Once
+
+type Options struct {
+	webOptions  []web.Option
+	version     string
+	routes      []func(*gin.RouterGroup)
+	adminRoutes []func(*gin.RouterGroup)
+	headers     http.Header
+	logOutput   io.Writer
+	goldenDir   string
+	config      map[string]any
+}
+
+type Option func(*Options)
+
+// WithWebOptions 设置 web.Server 选项，如维护模式、问题详情、IP黑白名单等
+func WithWebOptions(options ...web.Option) Option {
+	return func(o *Options) {
+		o.webOptions = append(o.webOptions, options...)
+	}
+}
+
+// WithVersion 设置业务路由注册的API版本，默认 v1
+func WithVersion(version string) Option {
+	return func(o *Options) {
+		o.version = version
+	}
+}
+
+// WithRoutes 注册业务路由
+func WithRoutes(routeFuncs ...func(*gin.RouterGroup)) Option {
+	return func(o *Options) {
+		o.routes = append(o.routes, routeFuncs...)
+	}
+}
+
+// WithServer 注册应用的全部业务路由和管理路由
+func WithServer(srv *server.Server) Option {
+	return func(o *Options) {
+		o.routes = append(o.routes, srv.Routes)
+		o.adminRoutes = append(o.adminRoutes, srv.AdminRoutes)
+	}
+}
+
+// WithAdminRoutes 注册管理路由
+func WithAdminRoutes(routeFuncs ...func(*gin.RouterGroup)) Option {
+	return func(o *Options) {
+		o.adminRoutes = append(o.adminRoutes, routeFuncs...)
+	}
+}
+
+// WithHeader 设置所有请求默认携带的请求头
+func WithHeader(key, value string) Option {
+	return func(o *Options) {
+		o.headers.Set(key, value)
+	}
+}
+
+// WithConfigValue 覆盖按键读取的配置项，如 tenant.aes_key，测试结束后恢复
+func WithConfigValue(key string, value any) Option {
+	return func(o *Options) {
+		o.config[key] = value
+	}
+}
+
+// WithLogOutput 设置测试期间的日志输出，默认丢弃
+func WithLogOutput(w io.Writer) Option {
+	return func(o *Options) {
+		o.logOutput = w
+	}
+}
+
+// WithGoldenDir 设置快照文件目录，默认 testdata/golden
+func WithGoldenDir(dir string) Option {
+	return func(o *Options) {
+		o.goldenDir = dir
+	}
+}
+
+// Client 基于完整中间件链的 web.Server 发起请求，无需监听端口
+type Client struct {
+	t         testing.TB
+	server    *web.Server
+	headers   http.Header
+	goldenDir string
+}
+
+// New 创建测试客户端，i18n 加载内置语言包，日志在测试结束后恢复
+func New(t testing.TB, options ...Option) *Client {
+	t.Helper()
+	opts := &Options{
+		version:   "v1",
+		headers:   make(http.Header),
+		logOutput: io.Discard,
+		goldenDir: "testdata/golden",
+		config:    make(map[string]any),
+	}
+	for _, option := range options {
+		option(opts)
+	}
+
+	prev := slog.Default()
+	slog.SetDefault(slog.New(&logging.TraceContextHandler{Handler: slog.NewTextHandler(opts.logOutput, nil)}))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	i18nOnce.Do(func() {
+		if err := i18n.Init(i18n.WithEmbedFS(locales.I18nFS, ".")); err != nil {
+			t.Fatalf("webtest: failed to init i18n: %v", err)
+		}
+	})
+	for key, value := range opts.config {
+		prev := config.Get(key)
+		config.Set(key, value)
+		t.Cleanup(func() { config.Set(key, prev) })
+	}
+
+	srv := web.New(opts.webOptions...).UseVersion(web.NewVersion(opts.version), opts.routes...)
+	srv.UseAdmin(opts.adminRoutes...)
+	return &Client{
+		t:         t,
+		server:    srv,
+		headers:   opts.headers,
+		goldenDir: opts.goldenDir,
+	}
+}
+
+// Server 返回被测的 web.Server
+func (c *Client) Server() *web.Server {
+	return c.server
+}
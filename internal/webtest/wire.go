@@ -0,0 +1,25 @@
+//go:build wireinject
+// +build wireinject
+
+/*
+Copyright © 2025 lixw
+*/
+package webtest
+
+import (
+	"github.com/ethanli-dev/go-app-layout/internal/handler"
+	"github.com/ethanli-dev/go-app-layout/internal/repository"
+	"github.com/ethanli-dev/go-app-layout/internal/server"
+	"github.com/ethanli-dev/go-app-layout/internal/service"
+	"github.com/ethanli-dev/go-app-layout/pkg/config"
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/outbox"
+	"github.com/ethanli-dev/go-app-layout/pkg/storage"
+	"github.com/google/wire"
+	"gorm.io/gorm"
+)
+
+// NewServer 使用与线上相同的依赖注入组装应用路由，db 通常由 NewDB 创建，store 可使用本地临时目录
+func NewServer(cfg *config.Config, db *gorm.DB, store storage.Storage) *server.Server {
+	panic(wire.Build(server.New, database.NewTxManager, outbox.NewWriter, repository.ProviderSet, service.ProviderSet, handler.ProviderSet))
+}
@@ -0,0 +1,354 @@
+/*
+Copyright © 2025 lixw
+*/
+package audit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// ContextKeyActor 操作者，如 admin、tenant:1，gin 处理器可直接 ctx.Set(ContextKeyActor, actor)
+	ContextKeyActor = "auditActor"
+	// ContextKeyClientIP 客户端IP
+	ContextKeyClientIP = "auditClientIP"
+
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+
+	pluginName = "audit"
+	beforeKey  = "audit:before"
+	// masked 标记为 audit:"mask" 的字段记录为该值，只体现是否变化
+	masked = "***"
+)
+
+// Change 字段变更前后的值，创建时只有 After，删除时只有 Before
+type Change struct {
+	Before any `json:"before,omitempty"`
+	After  any `json:"after,omitempty"`
+}
+
+// Event 实体变更的审计记录
+type Event struct {
+	ID        uint              `json:"id" gorm:"primaryKey;autoIncrement;comment:主键ID"`
+	Entity    string            `json:"entity" gorm:"column:entity;size:127;not null;index:idx_audit_log_entity,priority:1;comment:实体表名"`
+	EntityID  string            `json:"entity_id" gorm:"column:entity_id;size:127;not null;index:idx_audit_log_entity,priority:2;comment:实体主键"`
+	Action    string            `json:"action" gorm:"column:action;size:16;not null;comment:操作:create,update,delete"`
+	Changes   map[string]Change `json:"changes" gorm:"column:changes;type:text;serializer:json;comment:字段变更"`
+	Actor     string            `json:"actor" gorm:"column:actor;size:127;index;comment:操作者"`
+	TenantID  uint              `json:"tenant_id" gorm:"column:tenant_id;index;comment:租户ID"`
+	RequestID string            `json:"request_id" gorm:"column:request_id;size:64;comment:请求ID"`
+	IP        string            `json:"ip" gorm:"column:ip;size:64;comment:客户端IP"`
+	CreatedAt time.Time         `json:"created_at" gorm:"column:created_at;autoCreateTime;index;comment:操作时间"`
+}
+
+func (*Event) TableName() string {
+	return "audit_log"
+}
+
+// WithActor 返回带有操作者的上下文
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ContextKeyActor, actor)
+}
+
+type Options struct {
+	models []any
+}
+
+type Option func(*Options)
+
+// WithModels 需要审计的模型，字段标签 audit:"mask" 脱敏，audit:"-" 不记录
+func WithModels(models ...any) Option {
+	return func(o *Options) {
+		o.models = append(o.models, models...)
+	}
+}
+
+// Auditor 审计插件：在同一事务中记录审计模型的创建、更新、删除，写入失败时变更一并回滚；
+// 更新和删除前按相同条件读取受影响的记录以计算差异。Raw/Exec 语句和复合主键的模型不记录
+type Auditor struct {
+	models map[reflect.Type]bool
+}
+
+func New(options ...Option) *Auditor {
+	opts := &Options{}
+	for _, option := range options {
+		option(opts)
+	}
+	models := make(map[reflect.Type]bool, len(opts.models))
+	for _, model := range opts.models {
+		models[reflect.Indirect(reflect.ValueOf(model)).Type()] = true
+	}
+	return &Auditor{models: models}
+}
+
+func (a *Auditor) Name() string {
+	return pluginName
+}
+
+func (a *Auditor) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register(pluginName+":create", a.afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register(pluginName+":before_update", a.capture); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register(pluginName+":update", a.afterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register(pluginName+":before_delete", a.capture); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register(pluginName+":delete", a.afterDelete)
+}
+
+func (a *Auditor) audited(db *gorm.DB) bool {
+	stmt := db.Statement
+	return db.Error == nil && stmt.Schema != nil &&
+		stmt.Schema.PrioritizedPrimaryField != nil && len(stmt.Schema.PrimaryFields) == 1 &&
+		a.models[stmt.Schema.ModelType]
+}
+
+func (a *Auditor) afterCreate(db *gorm.DB) {
+	if !a.audited(db) || db.RowsAffected == 0 {
+		return
+	}
+	stmt := db.Statement
+	var events []*Event
+	eachRow(stmt.ReflectValue, func(rv reflect.Value) {
+		changes := make(map[string]Change)
+		for name, value := range values(stmt.Context, stmt.Schema, rv) {
+			changes[name] = Change{After: value}
+		}
+		events = append(events, a.event(stmt, rv, ActionCreate, changes))
+	})
+	a.write(db, events)
+}
+
+// capture 在更新、删除前读取将受影响的记录
+func (a *Auditor) capture(db *gorm.DB) {
+	if !a.audited(db) {
+		return
+	}
+	stmt := db.Statement
+	exprs := conditions(stmt)
+	// 没有条件时 gorm 会拒绝执行，无需读取
+	if len(exprs) == 0 && !stmt.AllowGlobalUpdate {
+		return
+	}
+	rows := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+	tx := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table)
+	if stmt.Unscoped {
+		tx = tx.Unscoped()
+	}
+	if len(exprs) > 0 {
+		tx = tx.Clauses(clause.Where{Exprs: exprs})
+	}
+	if err := tx.Find(rows.Interface()).Error; err != nil {
+		_ = db.AddError(fmt.Errorf("audit: failed to read %s: %w", stmt.Table, err))
+		return
+	}
+	stmt.Settings.Store(beforeKey, rows.Elem())
+}
+
+func (a *Auditor) afterUpdate(db *gorm.DB) {
+	v, ok := db.Statement.Settings.LoadAndDelete(beforeKey)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+	stmt := db.Statement
+	before := v.(reflect.Value)
+	if before.Len() == 0 {
+		return
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	ids := make([]any, 0, before.Len())
+	for i := 0; i < before.Len(); i++ {
+		id, _ := pk.ValueOf(stmt.Context, before.Index(i))
+		ids = append(ids, id)
+	}
+	after := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+	err := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table).Unscoped().
+		Where(clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Values: ids}).
+		Find(after.Interface()).Error
+	if err != nil {
+		_ = db.AddError(fmt.Errorf("audit: failed to read %s: %w", stmt.Table, err))
+		return
+	}
+	current := make(map[string]reflect.Value, after.Elem().Len())
+	eachRow(after.Elem(), func(rv reflect.Value) {
+		id, _ := pk.ValueOf(stmt.Context, rv)
+		current[fmt.Sprint(id)] = rv
+	})
+	var events []*Event
+	eachRow(before, func(rv reflect.Value) {
+		id, _ := pk.ValueOf(stmt.Context, rv)
+		next, ok := current[fmt.Sprint(id)]
+		if !ok {
+			return
+		}
+		if changes := diff(stmt.Context, stmt.Schema, rv, next); len(changes) > 0 {
+			events = append(events, a.event(stmt, next, ActionUpdate, changes))
+		}
+	})
+	a.write(db, events)
+}
+
+func (a *Auditor) afterDelete(db *gorm.DB) {
+	v, ok := db.Statement.Settings.LoadAndDelete(beforeKey)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+	stmt := db.Statement
+	var events []*Event
+	eachRow(v.(reflect.Value), func(rv reflect.Value) {
+		changes := make(map[string]Change)
+		for name, value := range values(stmt.Context, stmt.Schema, rv) {
+			changes[name] = Change{Before: value}
+		}
+		events = append(events, a.event(stmt, rv, ActionDelete, changes))
+	})
+	a.write(db, events)
+}
+
+func (a *Auditor) event(stmt *gorm.Statement, rv reflect.Value, action string, changes map[string]Change) *Event {
+	ctx := stmt.Context
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(ctx, rv)
+	event := &Event{
+		Entity:   stmt.Table,
+		EntityID: fmt.Sprint(id),
+		Action:   action,
+		Changes:  changes,
+	}
+	event.Actor, _ = ctx.Value(ContextKeyActor).(string)
+	event.IP, _ = ctx.Value(ContextKeyClientIP).(string)
+	event.RequestID, _ = ctx.Value(logging.ContextKeyTraceID).(string)
+	// 记录所属租户优先，其次为上下文中的租户
+	if field := stmt.Schema.LookUpField("tenant_id"); field != nil {
+		if tenantID, ok := field.ReflectValueOf(ctx, rv).Interface().(uint); ok {
+			event.TenantID = tenantID
+		}
+	}
+	if event.TenantID == 0 {
+		event.TenantID, _ = database.TenantFrom(ctx)
+	}
+	return event
+}
+
+// write 在当前连接（通常为变更所在的事务）上写入审计记录
+func (a *Auditor) write(db *gorm.DB, events []*Event) {
+	if len(events) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Create(&events).Error; err != nil {
+		_ = db.AddError(fmt.Errorf("audit: failed to write events: %w", err))
+	}
+}
+
+// conditions 返回语句的 WHERE 条件，以及 gorm 执行时才会追加的模型主键条件
+func conditions(stmt *gorm.Statement) []clause.Expression {
+	var exprs []clause.Expression
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			exprs = append(exprs, where.Exprs...)
+		}
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	var ids []any
+	eachRow(stmt.ReflectValue, func(rv reflect.Value) {
+		if id, zero := pk.ValueOf(stmt.Context, rv); !zero {
+			ids = append(ids, id)
+		}
+	})
+	if len(ids) > 0 {
+		exprs = append(exprs, clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Values: ids})
+	}
+	return exprs
+}
+
+func eachRow(rv reflect.Value, fn func(rv reflect.Value)) {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if row := reflect.Indirect(rv.Index(i)); row.Kind() == reflect.Struct {
+				fn(row)
+			}
+		}
+	case reflect.Struct:
+		fn(rv)
+	}
+}
+
+// values 返回非零值字段，脱敏字段记录为 ***
+func values(ctx context.Context, sch *schema.Schema, rv reflect.Value) map[string]any {
+	result := make(map[string]any)
+	for _, field := range sch.Fields {
+		tag := field.Tag.Get("audit")
+		if field.DBName == "" || tag == "-" {
+			continue
+		}
+		value, zero := valueOf(ctx, field, rv)
+		if zero {
+			continue
+		}
+		if masks(field) {
+			value = masked
+		}
+		result[field.DBName] = value
+	}
+	return result
+}
+
+// diff 返回前后不同的字段，不记录自动更新时间
+func diff(ctx context.Context, sch *schema.Schema, before, after reflect.Value) map[string]Change {
+	changes := make(map[string]Change)
+	for _, field := range sch.Fields {
+		tag := field.Tag.Get("audit")
+		if field.DBName == "" || tag == "-" || field.AutoUpdateTime > 0 {
+			continue
+		}
+		old, _ := valueOf(ctx, field, before)
+		cur, _ := valueOf(ctx, field, after)
+		if equal(old, cur) {
+			continue
+		}
+		if masks(field) {
+			old, cur = masked, masked
+		}
+		changes[field.DBName] = Change{Before: old, After: cur}
+	}
+	return changes
+}
+
+// valueOf 返回字段的原始值，使用序列化器的字段 ValueOf 返回的是序列化包装
+func valueOf(ctx context.Context, field *schema.Field, rv reflect.Value) (any, bool) {
+	if field.Serializer != nil {
+		v := field.ReflectValueOf(ctx, rv)
+		return v.Interface(), v.IsZero()
+	}
+	return field.ValueOf(ctx, rv)
+}
+
+// masks 脱敏字段和加密字段只记录是否变更
+func masks(field *schema.Field) bool {
+	return field.Tag.Get("audit") == "mask" || field.TagSettings["SERIALIZER"] == "encrypted"
+}
+
+func equal(a, b any) bool {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
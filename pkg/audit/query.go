@@ -0,0 +1,68 @@
+/*
+Copyright © 2025 lixw
+*/
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+)
+
+// Filter 审计记录查询条件，零值条件不生效
+type Filter struct {
+	Entity   string
+	EntityID string
+	TenantID uint
+	Actor    string
+	Action   string
+	From     time.Time
+	To       time.Time
+}
+
+// Query 按条件分页查询审计记录，按时间倒序；审计记录与变更写在同一个库，
+// 租户独立存储时需在上下文中指定租户（database.WithTenant）才会查询该租户库
+func Query(ctx context.Context, db *gorm.DB, filter Filter, offset, limit int) ([]*Event, int64, error) {
+	tx := database.Conn(ctx, db).Model(&Event{})
+	if filter.Entity != "" {
+		tx = tx.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != "" {
+		tx = tx.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.TenantID > 0 {
+		tx = tx.Where("tenant_id = ?", filter.TenantID)
+	}
+	if filter.Actor != "" {
+		tx = tx.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		tx = tx.Where("action = ?", filter.Action)
+	}
+	if !filter.From.IsZero() {
+		tx = tx.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		tx = tx.Where("created_at < ?", filter.To)
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var events []*Event
+	if total == 0 {
+		return events, 0, nil
+	}
+	if err := tx.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// History 返回实体的全部变更记录，按时间倒序
+func History(ctx context.Context, db *gorm.DB, entity, entityID string) ([]*Event, error) {
+	events, _, err := Query(ctx, db, Filter{Entity: entity, EntityID: entityID}, 0, -1)
+	return events, err
+}
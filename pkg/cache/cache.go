@@ -0,0 +1,237 @@
+/*
+Copyright © 2025 lixw
+*/
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const pluginName = "cache"
+
+// Backend 缓存存储，值为编码后的字节；远程实现（如 Redis）需保证 ttl 为 0 时不过期。
+// 多实例部署应使用远程实现，写入后所有实例同时失效
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type Options struct {
+	ttl    time.Duration
+	prefix string
+}
+
+type Option func(*Options)
+
+// WithTTL 缓存有效期，默认 5 分钟
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		if ttl > 0 {
+			o.ttl = ttl
+		}
+	}
+}
+
+// WithPrefix 键前缀，多个应用共用远程缓存时用于隔离，默认 cache:
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.prefix = prefix
+	}
+}
+
+// Cache 仓储查询结果缓存，同时是 gorm 插件：表有创建、更新、删除时使该表的全部缓存失效
+// （递增表的缓存代数，旧条目不再命中，随 TTL 过期），在 TxManager 事务中时提交后再失效一次，
+// 避免提交前被并发读取回填旧数据。Raw/Exec 语句不会触发失效
+type Cache struct {
+	backend Backend
+	opts    *Options
+	group   singleflight.Group
+	namer   schema.Namer
+	schemas sync.Map
+	seq     atomic.Uint64
+}
+
+func New(backend Backend, options ...Option) *Cache {
+	opts := &Options{
+		ttl:    5 * time.Minute,
+		prefix: "cache:",
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	return &Cache{backend: backend, opts: opts, namer: schema.NamingStrategy{}}
+}
+
+// Of 返回 db 上安装的缓存插件，未安装时返回 nil，GetByID、Find 在 nil 时直接查询
+func Of(db *gorm.DB) *Cache {
+	c, _ := db.Config.Plugins[pluginName].(*Cache)
+	return c
+}
+
+func (c *Cache) Name() string {
+	return pluginName
+}
+
+func (c *Cache) Initialize(db *gorm.DB) error {
+	c.namer = db.NamingStrategy
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register(pluginName+":create", c.invalidate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register(pluginName+":update", c.invalidate); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register(pluginName+":delete", c.invalidate)
+}
+
+func (c *Cache) invalidate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Table == "" || db.RowsAffected == 0 {
+		return
+	}
+	table := stmt.Table
+	c.Invalidate(stmt.Context, table)
+	if database.InTx(stmt.Context) {
+		database.AfterCommit(stmt.Context, func(ctx context.Context) {
+			c.Invalidate(ctx, table)
+		})
+	}
+}
+
+// Invalidate 使表的全部缓存失效，用于 Raw/Exec 等插件无法感知的写入
+func (c *Cache) Invalidate(ctx context.Context, table string) {
+	if err := c.backend.Set(ctx, c.generationKey(table), []byte(c.nextGeneration()), 0); err != nil {
+		slog.WarnContext(ctx, "failed to invalidate cache", "table", table, "err", err)
+	}
+}
+
+// GetByID 按主键读取，未命中时调用 load 并缓存结果；load 返回错误（包括未找到）时不缓存
+func GetByID[T any](ctx context.Context, c *Cache, id any, load func(ctx context.Context) (*T, error)) (*T, error) {
+	if c == nil {
+		return load(ctx)
+	}
+	return get(ctx, c, new(T), "id:"+fmt.Sprint(id), load)
+}
+
+// Find 按查询读取列表，query 为调用方组装的查询标识（如 status=1&page=2），需包含全部查询条件
+func Find[T any](ctx context.Context, c *Cache, query string, load func(ctx context.Context) ([]T, error)) ([]T, error) {
+	if c == nil {
+		return load(ctx)
+	}
+	var model T
+	result, err := get(ctx, c, &model, "find:"+query, func(ctx context.Context) (*[]T, error) {
+		list, err := load(ctx)
+		return &list, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return *result, nil
+}
+
+// get 读取缓存，未命中时同一个键只有一个请求执行 load，其余请求等待其结果，在事务中时直接执行 load；
+// 结果编码后存储，每个调用方得到独立的副本，修改返回值不影响缓存
+func get[T any](ctx context.Context, c *Cache, model any, suffix string, load func(ctx context.Context) (*T, error)) (*T, error) {
+	// 事务中可能读到未提交的数据，不读写缓存
+	if database.InTx(ctx) {
+		return load(ctx)
+	}
+	key, err := c.key(ctx, model, suffix)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to build cache key", "err", err)
+		return load(ctx)
+	}
+	if data, ok, err := c.backend.Get(ctx, key); err != nil {
+		slog.WarnContext(ctx, "failed to read cache", "key", key, "err", err)
+	} else if ok {
+		var value T
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err == nil {
+			return &value, nil
+		}
+		slog.WarnContext(ctx, "failed to decode cache", "key", key, "err", err)
+	}
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		// 共享的加载不受首个调用方取消的影响
+		loadCtx := context.WithoutCancel(ctx)
+		value, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+			return nil, fmt.Errorf("cache: failed to encode %s: %w", key, err)
+		}
+		if err := c.backend.Set(loadCtx, key, buf.Bytes(), c.opts.ttl); err != nil {
+			slog.WarnContext(loadCtx, "failed to write cache", "key", key, "err", err)
+		}
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var value T
+	if err := gob.NewDecoder(bytes.NewReader(v.([]byte))).Decode(&value); err != nil {
+		return nil, fmt.Errorf("cache: failed to decode %s: %w", key, err)
+	}
+	return &value, nil
+}
+
+// key 形如 cache:<表>:<代数>:<租户>:<查询>，按租户隔离的模型以上下文中的租户区分，其他模型为 0
+func (c *Cache) key(ctx context.Context, model any, suffix string) (string, error) {
+	sch, err := schema.Parse(model, &c.schemas, c.namer)
+	if err != nil {
+		return "", err
+	}
+	tenant := "0"
+	if field := sch.LookUpField("tenant_id"); field != nil && len(field.BindNames) > 1 && field.BindNames[0] == "TenantModel" {
+		if skip, _ := ctx.Value(database.ContextKeySkipTenant).(bool); skip {
+			tenant = "all"
+		} else if tenantID, ok := database.TenantFrom(ctx); ok {
+			tenant = strconv.FormatUint(uint64(tenantID), 10)
+		}
+	}
+	generation, err := c.generation(ctx, sch.Table)
+	if err != nil {
+		return "", err
+	}
+	return c.opts.prefix + sch.Table + ":" + generation + ":" + tenant + ":" + suffix, nil
+}
+
+// generation 返回表当前的缓存代数，不存在（首次使用或已被淘汰）时生成新的代数，旧条目随之失效
+func (c *Cache) generation(ctx context.Context, table string) (string, error) {
+	key := c.generationKey(table)
+	data, ok, err := c.backend.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return string(data), nil
+	}
+	generation := c.nextGeneration()
+	if err := c.backend.Set(ctx, key, []byte(generation), 0); err != nil {
+		return "", err
+	}
+	return generation, nil
+}
+
+func (c *Cache) generationKey(table string) string {
+	return c.opts.prefix + table + ":gen"
+}
+
+func (c *Cache) nextGeneration() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatUint(c.seq.Add(1), 36)
+}
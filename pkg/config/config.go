@@ -9,15 +9,25 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
+var (
+	watchMu   sync.Mutex
+	watchOnce sync.Once
+	watchers  []func(*Config)
+)
+
 type Config struct {
 	Server   *ServerConfig
 	Database *DatabaseConfig
 	Logging  *LoggingConfig
+	Storage  *StorageConfig
+	Outbox   *OutboxConfig
 }
 
 type ServerConfig struct {
@@ -29,16 +39,201 @@ type ServerConfig struct {
 	IdleTimeout     time.Duration
 	MaxHeaderBytes  int
 	BasePath        string
+	DefaultVersion  string
 	Locale          string
+	AdminToken      string
+	Maintenance     *MaintenanceConfig
+	TrustedProxies  []string
+	IPFilters       map[string]*IPFilterConfig
+	Security        *SecurityConfig
+	RequestLog      *RequestLogConfig
+	AccessLog       *AccessLogConfig
+	Signature       *SignatureConfig
+	ProblemDetails  *ProblemDetailsConfig
+}
+
+// ProblemDetailsConfig 按路由组启用真实HTTP状态码和 RFC 9457 错误响应，未启用的路由组保持HTTP 200加错误码响应体
+type ProblemDetailsConfig struct {
+	// 启用的路由组（api、admin、health、swagger、static）
+	Groups []string
+	// 问题类型 URI 前缀，类型为 {TypeBaseURI}/{错误码}，为空时使用 about:blank
+	TypeBaseURI string
+}
+
+// SignatureConfig 机器间调用的HMAC请求签名校验配置
+type SignatureConfig struct {
+	// 允许的客户端时钟偏差，nonce 保留时长为其两倍
+	ClockSkew time.Duration
+}
+
+type AccessLogConfig struct {
+	Enabled bool
+	// json 或 combined
+	Format     string
+	Path       string
+	MaxAge     int
+	MaxSize    int
+	MaxBackups int
+	Compress   bool
+}
+
+type RequestLogConfig struct {
+	LogHeaders bool
+	// 不记录请求体的路由模板
+	SkipBodyRoutes []string
+	Redact         *RedactConfig
+	// 记录响应体，错误响应始终记录，成功响应按采样率记录
+	CaptureResponse    bool
+	ResponseSampleRate float64
+	RouteSampleRates   []RouteSampleRate
+}
+
+type RouteSampleRate struct {
+	Route string
+	Rate  float64
+}
+
+// RedactConfig 追加的脱敏规则，内置规则始终生效
+type RedactConfig struct {
+	JSONPaths   []string
+	Headers     []string
+	QueryParams []string
+	Patterns    []string
+}
+
+type SecurityConfig struct {
+	// 为 0 时不输出 Strict-Transport-Security，未配置时为 365 天
+	HSTSMaxAge *time.Duration
+	// 未配置时为 true
+	HSTSIncludeSubdomains *bool
+	HSTSPreload           bool
+	FrameOptions          string
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+	// 各路由组的CSP策略（api、admin、health、swagger、static），支持 {nonce} 占位符
+	CSP map[string]string
+}
+
+type IPFilterConfig struct {
+	Allow []string
+	Deny  []string
+}
+
+type MaintenanceConfig struct {
+	Mode       string
+	RetryAfter time.Duration
+	AllowIPs   []string
+	AllowKeys  []string
 }
 
 type DatabaseConfig struct {
-	Url             string
+	// mysql://、postgres://、sqlite:// 开头时按 scheme 选择驱动，否则按 Driver 解析
+	Url string
+	// mysql（默认）、postgres、sqlite
+	Driver string
+	// 连接池参数为 0 时使用驱动默认值
 	ConnMaxIdleTime time.Duration
 	ConnMaxLifeTime time.Duration
 	MaxIdleConns    int
 	MaxOpenConns    int
 	SlowThreshold   time.Duration
+	Replica         *ReplicaConfig
+	TenantRouting   *TenantRoutingConfig
+	Cache           *CacheConfig
+	SlowLog         *SlowLogConfig
+	Encryption      *EncryptionConfig
+}
+
+// EncryptionConfig 字段加密的主密钥，轮换时添加新版本并将 Primary 改为新版本，
+// 旧版本在后台重新加密完成前不能删除
+type EncryptionConfig struct {
+	// 加密新数据使用的密钥版本
+	Primary uint32
+	Keys    []EncryptionKeyConfig
+	// 盲索引密钥（base64，至少 16 字节），不随主密钥轮换，修改后已有的盲索引失效
+	BlindIndexKey string
+	// 重新加密的扫描间隔和每批记录数
+	RotateInterval  time.Duration
+	RotateBatchSize int
+}
+
+// EncryptionKeyConfig Key 为 base64 编码的 AES 密钥（16、24 或 32 字节）
+type EncryptionKeyConfig struct {
+	Version uint32
+	Key     string
+}
+
+// SlowLogConfig 慢查询报告，超过 SlowThreshold 的语句按指纹聚合，SELECT 语句在后台执行 EXPLAIN
+type SlowLogConfig struct {
+	// 保留的指纹数上限，超出时淘汰最久未出现的
+	MaxFingerprints int
+	// 同一指纹两次 EXPLAIN 的最小间隔
+	ExplainInterval time.Duration
+}
+
+// CacheConfig 仓储查询结果缓存（进程内 LRU），写入时按表自动失效
+type CacheConfig struct {
+	Enabled    bool
+	MaxEntries int
+	TTL        time.Duration
+}
+
+// ReplicaConfig 只读副本配置，查询路由到健康的副本，写操作和事务走主库
+type ReplicaConfig struct {
+	Urls []string
+	// random、round_robin、least_conn
+	Policy        string
+	CheckInterval time.Duration
+	// 复制延迟超过该值的副本暂时摘除，0 表示不检查延迟
+	MaxLag time.Duration
+}
+
+// TenantRoutingConfig 物理隔离的租户，其余租户使用共享库并按 tenant_id 行隔离
+type TenantRoutingConfig struct {
+	// 同时打开的租户连接池上限，超出时关闭最久未使用的
+	MaxOpen int
+	// 租户连接池空闲超过该值后关闭
+	IdleTimeout time.Duration
+	Tenants     []TenantDatabaseConfig
+}
+
+// TenantDatabaseConfig Url 为独立数据库地址，Schema 为共享库实例上的独立 schema（PostgreSQL）或数据库（MySQL），二选一
+type TenantDatabaseConfig struct {
+	ID     uint
+	Url    string
+	Schema string
+}
+
+type StorageConfig struct {
+	// local 或 s3
+	Driver string
+	Local  *LocalStorageConfig
+	S3     *S3StorageConfig
+	// 单个文件大小上限（字节）
+	MaxFileSize int64
+	// 每个租户的存储配额（字节），0 表示不限制
+	TenantQuota int64
+	// 分片上传的分片大小（字节）
+	ChunkSize int64
+	// 允许上传的文件类型（按内容识别），支持 image/ 形式的前缀，为空时不限制
+	AllowedTypes []string
+	// 下载链接签名密钥，为空时每次启动随机生成
+	URLSecret string
+	URLExpiry time.Duration
+}
+
+type LocalStorageConfig struct {
+	Root string
+}
+
+type S3StorageConfig struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// MinIO 等本地替代服务通常需要开启
+	PathStyle bool
 }
 
 type LoggingConfig struct {
@@ -51,6 +246,30 @@ type LoggingConfig struct {
 	Format     string
 }
 
+type OutboxConfig struct {
+	// 轮询间隔
+	Interval  time.Duration
+	BatchSize int
+	// 超过最大投递次数转入死信表
+	MaxAttempts int
+	// 重试间隔从 Backoff 开始翻倍，不超过 MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// 多实例部署时只有持有租约的实例投递
+	LeaseTTL time.Duration
+	Webhooks []WebhookConfig
+}
+
+type WebhookConfig struct {
+	Url string
+	// 为空时投递全部事件
+	Topics  []string
+	Timeout time.Duration
+	// 配置后使用 HMAC-SHA256 请求签名，KeyID 为 X-Signature-Key
+	KeyID  string
+	Secret string
+}
+
 func New(path string) (*Config, error) {
 	viper.SetConfigFile(path)
 	viper.AutomaticEnv()
@@ -62,6 +281,16 @@ func New(path string) (*Config, error) {
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("using config file", "path", viper.ConfigFileUsed())
+	return cfg, nil
+}
+
+// load 读取配置文件内容，替换环境变量引用后解析为配置结构
+func load() (*Config, error) {
 	configFileContent, err := os.ReadFile(viper.ConfigFileUsed())
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file content: %w", err)
@@ -85,10 +314,33 @@ func New(path string) (*Config, error) {
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("unable to decode config into struct: %w", err)
 	}
-	slog.Info("using config file", "path", viper.ConfigFileUsed())
 	return &cfg, nil
 }
 
+// OnChange 注册配置变更回调，配置文件修改后重新解析并通知所有回调
+func OnChange(fn func(*Config)) {
+	watchMu.Lock()
+	watchers = append(watchers, fn)
+	watchMu.Unlock()
+	watchOnce.Do(func() {
+		viper.OnConfigChange(func(e fsnotify.Event) {
+			cfg, err := load()
+			if err != nil {
+				slog.Error("failed to reload config", "path", e.Name, "err", err)
+				return
+			}
+			slog.Info("config reloaded", "path", e.Name)
+			watchMu.Lock()
+			fns := append([]func(*Config){}, watchers...)
+			watchMu.Unlock()
+			for _, fn := range fns {
+				fn(cfg)
+			}
+		})
+		viper.WatchConfig()
+	})
+}
+
 func setDefaultConfig() {
 	// server
 	viper.SetDefault("server.addr", ":8080")
@@ -99,14 +351,23 @@ func setDefaultConfig() {
 	viper.SetDefault("server.idleTimeout", 30*time.Second)
 	viper.SetDefault("server.maxHeaderBytes", 1<<20) // 1MB
 	viper.SetDefault("server.basePath", "/")
+	viper.SetDefault("server.defaultVersion", "v1")
 	viper.SetDefault("server.locale", "zh-CN")
+	viper.SetDefault("server.adminToken", "")
+	viper.SetDefault("server.maintenance.mode", "off")
+	viper.SetDefault("server.maintenance.retryAfter", 5*time.Minute)
 
 	// database
-	viper.SetDefault("database.connMaxIdleTime", 5*time.Minute)
-	viper.SetDefault("database.connMaxLifeTime", 30*time.Minute)
-	viper.SetDefault("database.maxIdleConns", 5)
-	viper.SetDefault("database.maxOpenConns", 10)
+	viper.SetDefault("database.driver", "")
+	viper.SetDefault("database.connMaxIdleTime", 0)
+	viper.SetDefault("database.connMaxLifeTime", 0)
+	viper.SetDefault("database.maxIdleConns", 0)
+	viper.SetDefault("database.maxOpenConns", 0)
 	viper.SetDefault("database.slowThreshold", 500*time.Millisecond)
+	viper.SetDefault("database.replica.urls", []string{})
+	viper.SetDefault("database.replica.policy", "random")
+	viper.SetDefault("database.replica.checkInterval", 10*time.Second)
+	viper.SetDefault("database.replica.maxLag", 30*time.Second)
 
 	// logging
 	viper.SetDefault("logging.level", "info")
@@ -116,8 +377,41 @@ func setDefaultConfig() {
 	viper.SetDefault("logging.maxBackups", 10)
 	viper.SetDefault("logging.compress", true)
 	viper.SetDefault("logging.format", "text")
+
+	// access log
+	viper.SetDefault("server.accessLog.format", "json")
+	viper.SetDefault("server.accessLog.path", "logs/access.log")
+	viper.SetDefault("server.accessLog.maxAge", 7)
+	viper.SetDefault("server.accessLog.maxSize", 100)
+	viper.SetDefault("server.accessLog.maxBackups", 10)
+	viper.SetDefault("server.accessLog.compress", true)
+
+	// signature
+	viper.SetDefault("server.signature.clockSkew", 5*time.Minute)
+
+	// problem details
+	viper.SetDefault("server.problemDetails.groups", []string{})
+	viper.SetDefault("server.problemDetails.typeBaseURI", "")
+
+	// storage
+	viper.SetDefault("storage.driver", "local")
+	viper.SetDefault("storage.local.root", "data/storage")
+	viper.SetDefault("storage.maxFileSize", 100<<20) // 100MB
+	viper.SetDefault("storage.tenantQuota", 1<<30)   // 1GB
+	viper.SetDefault("storage.chunkSize", 5<<20)     // 5MB
+	viper.SetDefault("storage.urlSecret", "")
+	viper.SetDefault("storage.urlExpiry", 15*time.Minute)
 }
 
 func GetString(key string) string {
 	return viper.GetString(key)
 }
+
+func Get(key string) any {
+	return viper.Get(key)
+}
+
+// Set 覆盖配置项，优先级高于配置文件和环境变量，主要用于测试
+func Set(key string, value any) {
+	viper.Set(key, value)
+}
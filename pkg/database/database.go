@@ -5,25 +5,36 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
 	"runtime"
 	"time"
 
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 type Options struct {
 	url             string
+	driver          string
 	connMaxIdleTime time.Duration
 	connMaxLifeTime time.Duration
 	maxIdleConns    int
 	maxOpenConns    int
 	slowThreshold   time.Duration
 	plugins         []gorm.Plugin
+	replicas        []string
+	policy          Policy
+	checkInterval   time.Duration
+	maxLag          time.Duration
+	tenantSource    TenantSource
+	tenantMaxOpen   int
+	tenantIdle      time.Duration
+	maxFingerprints int
+	explainInterval time.Duration
+	slowLog         *slowLog
 }
 
 type Option func(*Options)
@@ -34,6 +45,13 @@ func WithUrl(url string) Option {
 	}
 }
 
+// WithDriver 设置数据库驱动（mysql、postgres、sqlite），url 带 scheme 时以 scheme 为准
+func WithDriver(driver string) Option {
+	return func(o *Options) {
+		o.driver = driver
+	}
+}
+
 func WithConnMaxIdleTime(connMaxIdleTime time.Duration) Option {
 	return func(o *Options) {
 		o.connMaxIdleTime = connMaxIdleTime
@@ -64,12 +82,65 @@ func WithSlowThreshold(slowThreshold time.Duration) Option {
 	}
 }
 
+// WithSlowLog 设置慢查询报告保留的指纹数和同一指纹执行 EXPLAIN 的最小间隔，为 0 时使用默认值（500 个、10 分钟）
+func WithSlowLog(maxFingerprints int, explainInterval time.Duration) Option {
+	return func(o *Options) {
+		if maxFingerprints > 0 {
+			o.maxFingerprints = maxFingerprints
+		}
+		if explainInterval > 0 {
+			o.explainInterval = explainInterval
+		}
+	}
+}
+
 func WithPlugins(plugins ...gorm.Plugin) Option {
 	return func(o *Options) {
 		o.plugins = append(o.plugins, plugins...)
 	}
 }
 
+// WithReplicas 设置只读副本地址，查询路由到副本，写操作和事务走主库，副本沿用主库的连接池参数
+func WithReplicas(urls ...string) Option {
+	return func(o *Options) {
+		o.replicas = append(o.replicas, urls...)
+	}
+}
+
+// WithReplicaPolicy 设置副本负载均衡策略，默认随机
+func WithReplicaPolicy(policy Policy) Option {
+	return func(o *Options) {
+		o.policy = policy
+	}
+}
+
+// WithReplicaHealthCheck 设置副本健康检查间隔和允许的最大复制延迟，maxLag 为 0 时不检查延迟
+func WithReplicaHealthCheck(interval, maxLag time.Duration) Option {
+	return func(o *Options) {
+		o.checkInterval = interval
+		o.maxLag = maxLag
+	}
+}
+
+// WithTenantSource 启用租户路由，source 中的租户使用独立数据库或 schema，仓储通过 Conn 透明访问
+func WithTenantSource(source TenantSource) Option {
+	return func(o *Options) {
+		o.tenantSource = source
+	}
+}
+
+// WithTenantPool 设置同时打开的租户连接池上限和空闲关闭时间，为 0 时使用默认值（64 个、10 分钟）
+func WithTenantPool(maxOpen int, idleTimeout time.Duration) Option {
+	return func(o *Options) {
+		if maxOpen > 0 {
+			o.tenantMaxOpen = maxOpen
+		}
+		if idleTimeout > 0 {
+			o.tenantIdle = idleTimeout
+		}
+	}
+}
+
 type gormLogger struct {
 	slowThreshold time.Duration
 	level         slog.Level
@@ -141,19 +212,50 @@ func (l *gormLogger) writeLog(ctx context.Context, level slog.Level, content str
 
 func New(options ...Option) (*gorm.DB, error) {
 	opts := &Options{
-		maxIdleConns:    5,
-		maxOpenConns:    10,
-		connMaxIdleTime: 5 * time.Minute,
-		connMaxLifeTime: 10 * time.Minute,
 		slowThreshold:   time.Millisecond * 500,
+		checkInterval:   10 * time.Second,
+		maxLag:          30 * time.Second,
+		tenantMaxOpen:   64,
+		tenantIdle:      10 * time.Minute,
+		maxFingerprints: 500,
+		explainInterval: 10 * time.Minute,
 	}
 	for _, option := range options {
 		option(opts)
 	}
+	// 租户库复制选项，共用同一份慢查询统计
+	opts.slowLog = newSlowLog(opts)
+	return open(opts)
+}
+
+func open(opts *Options) (*gorm.DB, error) {
 	if opts.url == "" {
 		return nil, fmt.Errorf("database url is not set (use WithUrl to configure)")
 	}
-	db, err := gorm.Open(mysql.Open(opts.url),
+	driver, dsn, err := ParseURL(opts.url, opts.driver)
+	if err != nil {
+		return nil, err
+	}
+	var tenantRouter *router
+	if opts.tenantSource != nil {
+		// 在填充驱动默认值之前复制选项，租户库可使用不同的驱动
+		tenantRouter = newRouter(driver, dsn, opts)
+	}
+	// 未设置的连接池参数使用驱动默认值
+	defaults := poolDefaults[driver]
+	if opts.maxIdleConns == 0 {
+		opts.maxIdleConns = defaults.maxIdleConns
+	}
+	if opts.maxOpenConns == 0 {
+		opts.maxOpenConns = defaults.maxOpenConns
+	}
+	if opts.connMaxIdleTime == 0 {
+		opts.connMaxIdleTime = defaults.connMaxIdleTime
+	}
+	if opts.connMaxLifeTime == 0 {
+		opts.connMaxLifeTime = defaults.connMaxLifeTime
+	}
+	db, err := gorm.Open(dialector(driver, dsn),
 		&gorm.Config{
 			Logger: &gormLogger{
 				slowThreshold: opts.slowThreshold,
@@ -165,6 +267,26 @@ func New(options ...Option) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(opts.replicas) > 0 {
+		r, err := newResolver(driver, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Use(r); err != nil {
+			_ = r.close()
+			return nil, err
+		}
+	}
+	if tenantRouter != nil {
+		if err := db.Use(tenantRouter); err != nil {
+			return nil, err
+		}
+	}
+	if opts.slowLog != nil {
+		if err := db.Use(opts.slowLog); err != nil {
+			return nil, err
+		}
+	}
 	for _, plugin := range opts.plugins {
 		if err := db.Use(plugin); err != nil {
 			return nil, err
@@ -174,12 +296,49 @@ func New(options ...Option) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	setPool(sqlDB, opts)
+	slog.Info("database create successfully", "driver", driver, "replicas", len(opts.replicas))
+	return db, nil
+}
+
+func setPool(sqlDB *sql.DB, opts *Options) {
 	sqlDB.SetConnMaxIdleTime(opts.connMaxIdleTime)
 	sqlDB.SetConnMaxLifetime(opts.connMaxLifeTime)
 	sqlDB.SetMaxIdleConns(opts.maxIdleConns)
 	sqlDB.SetMaxOpenConns(opts.maxOpenConns)
-	slog.Info("database create successfully")
-	return db, nil
+}
+
+// newResolver 打开副本连接池，副本须与主库使用相同的驱动，连接在首次使用或健康检查时建立
+func newResolver(driver string, opts *Options) (*resolver, error) {
+	policy := opts.policy
+	if policy == nil {
+		policy = RandomPolicy()
+	}
+	r := &resolver{
+		driver:        driver,
+		policy:        policy,
+		checkInterval: opts.checkInterval,
+		maxLag:        opts.maxLag,
+	}
+	for i, url := range opts.replicas {
+		replicaDriver, dsn, err := ParseURL(url, driver)
+		if err == nil && replicaDriver != driver {
+			err = fmt.Errorf("driver %s does not match primary driver %s", replicaDriver, driver)
+		}
+		var pool *sql.DB
+		if err == nil {
+			pool, err = sql.Open(sqlDriverNames[driver], dsn)
+		}
+		if err != nil {
+			_ = r.close()
+			return nil, fmt.Errorf("invalid database replica %d: %w", i+1, err)
+		}
+		setPool(pool, opts)
+		replica := &Replica{name: fmt.Sprintf("replica-%d", i+1), pool: pool}
+		replica.healthy.Store(true)
+		r.replicas = append(r.replicas, replica)
+	}
+	return r, nil
 }
 
 type DatabaseService struct {
@@ -199,6 +358,16 @@ func (s *DatabaseService) Start(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	// 副本不可用不影响启动，查询回退到主库
+	if r := resolverOf(s.db); r != nil {
+		r.start(ctx)
+	}
+	if r := routerOf(s.db); r != nil {
+		r.start()
+	}
+	if l := slowLogOf(s.db); l != nil {
+		l.start()
+	}
 	slog.InfoContext(ctx, "database connect successfully")
 	return nil
 }
@@ -208,6 +377,19 @@ func (s *DatabaseService) Stop(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if l := slowLogOf(s.db); l != nil {
+		l.close()
+	}
+	if r := resolverOf(s.db); r != nil {
+		if err := r.close(); err != nil {
+			slog.WarnContext(ctx, "failed to close database replicas", "err", err)
+		}
+	}
+	if r := routerOf(s.db); r != nil {
+		if err := r.close(); err != nil {
+			slog.WarnContext(ctx, "failed to close tenant databases", "err", err)
+		}
+	}
 	err = sqlDB.Close()
 	if err != nil {
 		return err
@@ -0,0 +1,352 @@
+/*
+Copyright © 2025 lixw
+*/
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/safego"
+	"gorm.io/gorm"
+)
+
+const (
+	// ContextKeyPrimary 为 true 时读请求也走主库，用于写后立即读的一致性场景
+	ContextKeyPrimary = "dbPrimary"
+
+	resolverName = "database:resolver"
+)
+
+// WithPrimary 返回读请求走主库的上下文，gin 处理器也可直接 ctx.Set(ContextKeyPrimary, true)
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ContextKeyPrimary, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	primary, _ := ctx.Value(ContextKeyPrimary).(bool)
+	return primary
+}
+
+// Replica 只读副本连接池
+type Replica struct {
+	name    string
+	pool    *sql.DB
+	healthy atomic.Bool
+	lag     atomic.Int64
+}
+
+func (r *Replica) Name() string {
+	return r.name
+}
+
+// Healthy 最近一次健康检查是否通过，未通过的副本不参与路由
+func (r *Replica) Healthy() bool {
+	return r.healthy.Load()
+}
+
+// Lag 最近一次健康检查得到的复制延迟
+func (r *Replica) Lag() time.Duration {
+	return time.Duration(r.lag.Load())
+}
+
+// Policy 副本负载均衡策略，replicas 为当前健康的副本，至少包含一个
+type Policy interface {
+	Resolve(replicas []*Replica) *Replica
+}
+
+type PolicyFunc func(replicas []*Replica) *Replica
+
+func (f PolicyFunc) Resolve(replicas []*Replica) *Replica {
+	return f(replicas)
+}
+
+func RandomPolicy() Policy {
+	return PolicyFunc(func(replicas []*Replica) *Replica {
+		return replicas[rand.IntN(len(replicas))]
+	})
+}
+
+func RoundRobinPolicy() Policy {
+	var i atomic.Uint64
+	return PolicyFunc(func(replicas []*Replica) *Replica {
+		return replicas[(i.Add(1)-1)%uint64(len(replicas))]
+	})
+}
+
+// LeastConnPolicy 选择使用中连接最少的副本，适合耗时差异大的报表查询
+func LeastConnPolicy() Policy {
+	return PolicyFunc(func(replicas []*Replica) *Replica {
+		best := replicas[0]
+		for _, r := range replicas[1:] {
+			if r.pool.Stats().InUse < best.pool.Stats().InUse {
+				best = r
+			}
+		}
+		return best
+	})
+}
+
+// ParsePolicy 按名称返回负载均衡策略：random（默认）、round_robin、least_conn
+func ParsePolicy(name string) (Policy, error) {
+	switch strings.ToLower(name) {
+	case "", "random":
+		return RandomPolicy(), nil
+	case "round_robin":
+		return RoundRobinPolicy(), nil
+	case "least_conn":
+		return LeastConnPolicy(), nil
+	default:
+		return nil, fmt.Errorf("unknown replica policy: %s", name)
+	}
+}
+
+// resolver 读写分离插件：事务、写操作、加锁查询和标记了主库的请求走主库，其余查询按策略路由到健康的副本
+type resolver struct {
+	driver        string
+	replicas      []*Replica
+	policy        Policy
+	checkInterval time.Duration
+	maxLag        time.Duration
+	mu            sync.Mutex
+	cancel        context.CancelFunc
+}
+
+func (r *resolver) Name() string {
+	return resolverName
+}
+
+func (r *resolver) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Query().Before("*").Register(resolverName, r.route); err != nil {
+		return err
+	}
+	return db.Callback().Row().Before("*").Register(resolverName, r.route)
+}
+
+func (r *resolver) route(db *gorm.DB) {
+	stmt := db.Statement
+	if _, ok := stmt.ConnPool.(gorm.TxCommitter); ok {
+		return
+	}
+	if usePrimary(stmt.Context) {
+		return
+	}
+	if _, locking := stmt.Clauses["FOR"]; locking {
+		return
+	}
+	// Raw 查询仅路由 SELECT 语句
+	if sqlStr := strings.TrimSpace(stmt.SQL.String()); sqlStr != "" {
+		lower := strings.ToLower(sqlStr)
+		if !strings.HasPrefix(lower, "select") || strings.HasSuffix(lower, "for update") || strings.HasSuffix(lower, "for share") {
+			return
+		}
+	}
+	if replica := r.pick(); replica != nil {
+		stmt.ConnPool = replica.pool
+	}
+}
+
+// pick 返回按策略选择的健康副本，全部不可用时返回 nil，查询回退到主库
+func (r *resolver) pick() *Replica {
+	healthy := make([]*Replica, 0, len(r.replicas))
+	for _, replica := range r.replicas {
+		if replica.Healthy() {
+			healthy = append(healthy, replica)
+		}
+	}
+	switch len(healthy) {
+	case 0:
+		return nil
+	case 1:
+		return healthy[0]
+	default:
+		return r.policy.Resolve(healthy)
+	}
+}
+
+// start 立即检查一次副本健康状态，之后按间隔定期检查
+func (r *resolver) start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil || r.checkInterval <= 0 {
+		return
+	}
+	r.checkAll(ctx)
+	loopCtx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	safego.Go(loopCtx, func(ctx context.Context) {
+		ticker := time.NewTicker(r.checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.checkAll(ctx)
+			}
+		}
+	})
+}
+
+func (r *resolver) close() error {
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	r.mu.Unlock()
+	var errs []error
+	for _, replica := range r.replicas {
+		errs = append(errs, replica.pool.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (r *resolver) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, replica := range r.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.check(ctx, replica)
+		}()
+	}
+	wg.Wait()
+}
+
+// check 副本不可达或复制延迟超过 maxLag 时摘除，恢复后重新加入
+func (r *resolver) check(ctx context.Context, replica *Replica) {
+	ctx, cancel := context.WithTimeout(ctx, r.checkInterval)
+	defer cancel()
+	err := replica.pool.PingContext(ctx)
+	var lag time.Duration
+	if err == nil {
+		lag, err = replicationLag(ctx, r.driver, replica.pool)
+	}
+	if err == nil && r.maxLag > 0 && lag > r.maxLag {
+		err = fmt.Errorf("replication lag %v exceeds %v", lag, r.maxLag)
+	}
+	replica.lag.Store(int64(lag))
+	healthy := err == nil
+	if replica.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		slog.InfoContext(ctx, "database replica recovered", "replica", replica.name, "lag", lag)
+	} else {
+		slog.WarnContext(ctx, "database replica evicted", "replica", replica.name, "err", err)
+	}
+}
+
+// replicationLag 查询副本的复制延迟，非副本实例返回 0
+func replicationLag(ctx context.Context, driver string, pool *sql.DB) (time.Duration, error) {
+	switch driver {
+	case DriverMySQL:
+		return mysqlReplicationLag(ctx, pool)
+	case DriverPostgres:
+		var seconds float64
+		err := pool.QueryRowContext(ctx, `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`).Scan(&seconds)
+		return time.Duration(seconds * float64(time.Second)), err
+	default:
+		return 0, nil
+	}
+}
+
+// mysqlReplicationLag 优先使用 8.0.22 起的 SHOW REPLICA STATUS，复制线程停止时返回错误
+func mysqlReplicationLag(ctx context.Context, pool *sql.DB) (time.Duration, error) {
+	rows, err := pool.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		if rows, err = pool.QueryContext(ctx, "SHOW SLAVE STATUS"); err != nil {
+			return 0, err
+		}
+	}
+	defer func() { _ = rows.Close() }()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if !values[i].Valid {
+			return 0, errors.New("replication is not running")
+		}
+		seconds, err := strconv.Atoi(values[i].String)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, nil
+}
+
+// PoolStats 连接池状态，主库的 Healthy 恒为 true
+type PoolStats struct {
+	Name    string        `json:"name"`
+	Healthy bool          `json:"healthy"`
+	Lag     time.Duration `json:"lag"`
+	sql.DBStats
+}
+
+// Stats 返回主库、各副本和已打开的租户库连接池的状态
+func Stats(db *gorm.DB) []PoolStats {
+	var stats []PoolStats
+	if sqlDB, err := db.DB(); err == nil {
+		stats = append(stats, PoolStats{Name: "primary", Healthy: true, DBStats: sqlDB.Stats()})
+	}
+	if r := resolverOf(db); r != nil {
+		for _, replica := range r.replicas {
+			stats = append(stats, PoolStats{
+				Name:    replica.name,
+				Healthy: replica.Healthy(),
+				Lag:     replica.Lag(),
+				DBStats: replica.pool.Stats(),
+			})
+		}
+	}
+	if r := routerOf(db); r != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		ids := slices.Sorted(maps.Keys(r.conns))
+		for _, id := range ids {
+			if sqlDB, err := r.conns[id].db.DB(); err == nil {
+				stats = append(stats, PoolStats{Name: fmt.Sprintf("tenant-%d", id), Healthy: true, DBStats: sqlDB.Stats()})
+			}
+		}
+	}
+	return stats
+}
+
+func resolverOf(db *gorm.DB) *resolver {
+	r, _ := db.Config.Plugins[resolverName].(*resolver)
+	return r
+}
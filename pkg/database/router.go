@@ -0,0 +1,361 @@
+/*
+Copyright © 2025 lixw
+*/
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/safego"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// ContextKeyShared 为 true 时使用共享库，用于租户注册表等不属于单个租户的数据
+	ContextKeyShared = "dbShared"
+
+	routerName = "database:router"
+	// evictDelay 淘汰的连接池延迟关闭，使仍持有该连接的请求能够完成
+	evictDelay = 30 * time.Second
+)
+
+var schemaPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// WithShared 返回使用共享库的上下文，租户独立存储时也不路由到租户库
+func WithShared(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ContextKeyShared, true)
+}
+
+func useShared(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	shared, _ := ctx.Value(ContextKeyShared).(bool)
+	return shared
+}
+
+// TenantTarget 租户独立存储的位置：URL 为独立数据库，可使用不同驱动；
+// Schema 为共享库实例上的独立 schema（PostgreSQL）或数据库（MySQL），沿用共享库的地址和账号
+type TenantTarget struct {
+	URL    string
+	Schema string
+}
+
+// TenantSource 租户到独立存储位置的映射，未配置的租户使用共享库并按 tenant_id 行隔离；
+// Lookup 在每个未缓存连接的请求上调用，实现需自行缓存
+type TenantSource interface {
+	Lookup(ctx context.Context, tenantID uint) (TenantTarget, bool, error)
+	// Tenants 返回所有独立存储的租户，用于迁移等需要遍历租户库的任务
+	Tenants(ctx context.Context) ([]uint, error)
+}
+
+// StaticTenants 固定的租户映射，通常来自配置文件
+type StaticTenants map[uint]TenantTarget
+
+func (s StaticTenants) Lookup(ctx context.Context, tenantID uint) (TenantTarget, bool, error) {
+	target, ok := s[tenantID]
+	return target, ok, nil
+}
+
+func (s StaticTenants) Tenants(ctx context.Context) ([]uint, error) {
+	ids := make([]uint, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// router 租户路由插件，按上下文中的租户返回其独立连接，由 Conn 调用；
+// 连接池在首次使用时打开，超过 maxOpen 时关闭最久未使用的，空闲超过 idleTimeout 的定期关闭
+type router struct {
+	source      TenantSource
+	driver      string
+	dsn         string
+	opts        *Options
+	maxOpen     int
+	idleTimeout time.Duration
+	mu          sync.Mutex
+	conns       map[uint]*tenantConn
+	cancel      context.CancelFunc
+}
+
+type tenantConn struct {
+	db       *gorm.DB
+	lastUsed atomic.Int64
+}
+
+// newRouter opts 为租户库使用的选项，沿用共享库的连接池参数和插件，不使用副本
+func newRouter(driver, dsn string, opts *Options) *router {
+	r := &router{
+		source:      opts.tenantSource,
+		driver:      driver,
+		dsn:         dsn,
+		maxOpen:     opts.tenantMaxOpen,
+		idleTimeout: opts.tenantIdle,
+		conns:       make(map[uint]*tenantConn),
+	}
+	tenantOpts := *opts
+	tenantOpts.replicas = nil
+	tenantOpts.tenantSource = nil
+	r.opts = &tenantOpts
+	return r
+}
+
+func (r *router) Name() string {
+	return routerName
+}
+
+func (r *router) Initialize(db *gorm.DB) error {
+	return nil
+}
+
+// resolve 返回上下文中租户的独立连接，租户使用共享库或上下文指定共享库时返回 nil
+func (r *router) resolve(ctx context.Context) (*gorm.DB, error) {
+	if useShared(ctx) {
+		return nil, nil
+	}
+	tenantID, ok := TenantFrom(ctx)
+	if !ok {
+		return nil, nil
+	}
+	return r.open(ctx, tenantID)
+}
+
+func (r *router) open(ctx context.Context, tenantID uint) (*gorm.DB, error) {
+	now := time.Now().UnixNano()
+	r.mu.Lock()
+	if c, ok := r.conns[tenantID]; ok {
+		c.lastUsed.Store(now)
+		r.mu.Unlock()
+		return c.db, nil
+	}
+	r.mu.Unlock()
+
+	target, ok, err := r.source.Lookup(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup tenant %d database: %w", tenantID, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	db, err := r.connect(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tenant %d database: %w", tenantID, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 并发打开同一租户时保留先完成的连接
+	if c, ok := r.conns[tenantID]; ok {
+		c.lastUsed.Store(now)
+		closeLater(db, 0)
+		return c.db, nil
+	}
+	c := &tenantConn{db: db}
+	c.lastUsed.Store(now)
+	r.conns[tenantID] = c
+	slog.InfoContext(ctx, "tenant database opened", "tenant", tenantID, "open", len(r.conns))
+	if r.maxOpen > 0 && len(r.conns) > r.maxOpen {
+		r.evictLocked(r.oldestLocked())
+	}
+	return db, nil
+}
+
+func (r *router) connect(target TenantTarget) (*gorm.DB, error) {
+	opts := *r.opts
+	switch {
+	case target.URL != "":
+		opts.url = target.URL
+	case target.Schema != "":
+		dsn, err := schemaDSN(r.driver, r.dsn, target.Schema)
+		if err != nil {
+			return nil, err
+		}
+		opts.url, opts.driver = dsn, r.driver
+	default:
+		return nil, errors.New("tenant database url or schema is not set")
+	}
+	return open(&opts)
+}
+
+func (r *router) oldestLocked() uint {
+	var oldest uint
+	var oldestUsed int64
+	for id, c := range r.conns {
+		if used := c.lastUsed.Load(); oldestUsed == 0 || used < oldestUsed {
+			oldest, oldestUsed = id, used
+		}
+	}
+	return oldest
+}
+
+func (r *router) evictLocked(tenantID uint) {
+	c, ok := r.conns[tenantID]
+	if !ok {
+		return
+	}
+	delete(r.conns, tenantID)
+	closeLater(c.db, evictDelay)
+	slog.Info("tenant database evicted", "tenant", tenantID, "open", len(r.conns))
+}
+
+// start 按 idleTimeout 的一半为间隔关闭空闲的租户连接池
+func (r *router) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil || r.idleTimeout <= 0 {
+		return
+	}
+	loopCtx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	safego.Go(loopCtx, func(ctx context.Context) {
+		ticker := time.NewTicker(r.idleTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.evictIdle()
+			}
+		}
+	})
+}
+
+func (r *router) evictIdle() {
+	deadline := time.Now().Add(-r.idleTimeout).UnixNano()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, c := range r.conns {
+		if c.lastUsed.Load() < deadline {
+			r.evictLocked(id)
+		}
+	}
+}
+
+func (r *router) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	var errs []error
+	for id, c := range r.conns {
+		if sqlDB, err := c.db.DB(); err == nil {
+			errs = append(errs, sqlDB.Close())
+		}
+		delete(r.conns, id)
+	}
+	return errors.Join(errs...)
+}
+
+func closeLater(db *gorm.DB, delay time.Duration) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+	time.AfterFunc(delay, func() {
+		if err := sqlDB.Close(); err != nil {
+			slog.Warn("failed to close tenant database", "err", err)
+		}
+	})
+}
+
+// schemaDSN 将共享库的 DSN 改为指向指定 schema：PostgreSQL 设置 search_path，MySQL 替换数据库名
+func schemaDSN(driver, dsn, schema string) (string, error) {
+	if !schemaPattern.MatchString(schema) {
+		return "", fmt.Errorf("invalid tenant schema: %s", schema)
+	}
+	switch driver {
+	case DriverPostgres:
+		if !strings.Contains(dsn, "://") {
+			return dsn + " search_path=" + schema, nil
+		}
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("invalid postgres url: %w", err)
+		}
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	case DriverMySQL:
+		// DSN 形如 user:pass@tcp(host:3306)/db?params，密码中可能含有 /，从参数之前向前查找
+		end := strings.Index(dsn, "?")
+		if end < 0 {
+			end = len(dsn)
+		}
+		slash := strings.LastIndex(dsn[:end], "/")
+		if slash < 0 || slash < strings.LastIndex(dsn[:end], ")") {
+			return "", errors.New("mysql dsn has no database name")
+		}
+		return dsn[:slash+1] + schema + dsn[end:], nil
+	default:
+		return "", fmt.Errorf("schema-per-tenant is not supported by %s", driver)
+	}
+}
+
+func routerOf(db *gorm.DB) *router {
+	r, _ := db.Config.Plugins[routerName].(*router)
+	return r
+}
+
+// RoutedTenants 返回独立存储的租户，可通过 Conn(WithTenant(ctx, id), db) 访问其库；未配置租户路由时返回空
+func RoutedTenants(ctx context.Context, db *gorm.DB) ([]uint, error) {
+	r := routerOf(db)
+	if r == nil {
+		return nil, nil
+	}
+	return r.source.Tenants(ctx)
+}
+
+// EachTenant 依次在每个独立存储的租户库上执行 fn，用于迁移等管理任务；
+// 使用独立 schema 的租户在 schema 不存在时先在共享库上创建。未配置租户路由时不执行
+func EachTenant(ctx context.Context, db *gorm.DB, fn func(tenantID uint, tdb *gorm.DB) error) error {
+	r := routerOf(db)
+	if r == nil {
+		return nil
+	}
+	ids, err := r.source.Tenants(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		target, ok, err := r.source.Lookup(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to lookup tenant %d database: %w", id, err)
+		}
+		if !ok {
+			continue
+		}
+		if target.URL == "" && target.Schema != "" {
+			if _, err := schemaDSN(r.driver, r.dsn, target.Schema); err != nil {
+				return fmt.Errorf("tenant %d: %w", id, err)
+			}
+			if err := db.WithContext(ctx).Exec("CREATE SCHEMA IF NOT EXISTS ?", clause.Table{Name: target.Schema}).Error; err != nil {
+				return fmt.Errorf("failed to create schema for tenant %d: %w", id, err)
+			}
+		}
+		tdb, err := r.open(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(id, tdb); err != nil {
+			return fmt.Errorf("tenant %d: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+/*
+Copyright © 2025 lixw
+*/
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/safego"
+	"gorm.io/gorm"
+)
+
+const (
+	slowLogName    = "database:slowlog"
+	slowLogStarted = "database:slowlog:start"
+	// slowSamples 每个指纹保留最近的耗时样本数，用于计算 p95
+	slowSamples    = 128
+	explainTimeout = 5 * time.Second
+)
+
+var (
+	stringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberLiteral = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	pgPlaceholder = regexp.MustCompile(`\$\d+`)
+	placeholders  = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	whitespace    = regexp.MustCompile(`\s+`)
+)
+
+// SlowQuery 按指纹聚合的慢查询统计，耗时单位为毫秒；Example 为最近一次的原始语句（参数为占位符）
+type SlowQuery struct {
+	Fingerprint string           `json:"fingerprint"`
+	SQL         string           `json:"sql"`
+	Example     string           `json:"example"`
+	Count       int64            `json:"count"`
+	TotalMs     float64          `json:"total_ms"`
+	AvgMs       float64          `json:"avg_ms"`
+	P95Ms       float64          `json:"p95_ms"`
+	MaxMs       float64          `json:"max_ms"`
+	Rows        int64            `json:"rows"`
+	LastSeen    time.Time        `json:"last_seen"`
+	Plan        []map[string]any `json:"plan,omitempty"`
+	PlanAt      *time.Time       `json:"plan_at,omitempty"`
+	PlanError   string           `json:"plan_error,omitempty"`
+}
+
+type slowEntry struct {
+	query   SlowQuery
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration
+	next    int
+	// explainAt 最近一次提交 EXPLAIN 的时间
+	explainAt time.Time
+}
+
+type explainJob struct {
+	fingerprint string
+	pool        gorm.ConnPool
+	dialect     string
+	sql         string
+	vars        []any
+}
+
+// slowLog 慢查询收集插件：记录耗时超过 threshold 的语句，按指纹聚合次数和耗时，
+// 并在后台对 SELECT 语句执行 EXPLAIN，同一指纹在 explainInterval 内只执行一次。
+// 租户库与共享库共用同一个收集器，指纹超过 maxFingerprints 时淘汰最久未出现的
+type slowLog struct {
+	threshold       time.Duration
+	maxFingerprints int
+	explainInterval time.Duration
+	mu              sync.Mutex
+	entries         map[string]*slowEntry
+	jobs            chan explainJob
+	cancel          context.CancelFunc
+}
+
+func newSlowLog(opts *Options) *slowLog {
+	return &slowLog{
+		threshold:       opts.slowThreshold,
+		maxFingerprints: opts.maxFingerprints,
+		explainInterval: opts.explainInterval,
+		entries:         make(map[string]*slowEntry),
+		jobs:            make(chan explainJob, 64),
+	}
+}
+
+func (s *slowLog) Name() string {
+	return slowLogName
+}
+
+// Initialize 在全部回调之前记录开始时间、之后统计耗时，与日志中的慢查询耗时口径一致
+func (s *slowLog) Initialize(db *gorm.DB) error {
+	if s.threshold <= 0 {
+		return nil
+	}
+	cb := db.Callback()
+	return errors.Join(
+		cb.Query().Before("*").Register(slowLogName+":start", s.begin),
+		cb.Query().After("*").Register(slowLogName+":finish", s.finish),
+		cb.Row().Before("*").Register(slowLogName+":start", s.begin),
+		cb.Row().After("*").Register(slowLogName+":finish", s.finish),
+		cb.Raw().Before("*").Register(slowLogName+":start", s.begin),
+		cb.Raw().After("*").Register(slowLogName+":finish", s.finish),
+		cb.Create().Before("*").Register(slowLogName+":start", s.begin),
+		cb.Create().After("*").Register(slowLogName+":finish", s.finish),
+		cb.Update().Before("*").Register(slowLogName+":start", s.begin),
+		cb.Update().After("*").Register(slowLogName+":finish", s.finish),
+		cb.Delete().Before("*").Register(slowLogName+":start", s.begin),
+		cb.Delete().After("*").Register(slowLogName+":finish", s.finish),
+	)
+}
+
+func (s *slowLog) begin(db *gorm.DB) {
+	db.Statement.Settings.Store(slowLogStarted, time.Now())
+}
+
+func (s *slowLog) finish(db *gorm.DB) {
+	v, ok := db.Statement.Settings.LoadAndDelete(slowLogStarted)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(v.(time.Time))
+	stmt := db.Statement
+	if elapsed <= s.threshold || stmt.SQL.Len() == 0 {
+		return
+	}
+	sql := stmt.SQL.String()
+	fingerprint, normalized := Fingerprint(sql)
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.entries[fingerprint]
+	if !ok {
+		if s.maxFingerprints > 0 && len(s.entries) >= s.maxFingerprints {
+			s.evictLocked()
+		}
+		entry = &slowEntry{query: SlowQuery{Fingerprint: fingerprint, SQL: normalized}, samples: make([]time.Duration, 0, slowSamples)}
+		s.entries[fingerprint] = entry
+	}
+	entry.query.Example = sql
+	entry.query.Count++
+	entry.query.Rows = db.RowsAffected
+	entry.query.LastSeen = now
+	entry.total += elapsed
+	entry.max = max(entry.max, elapsed)
+	if len(entry.samples) < slowSamples {
+		entry.samples = append(entry.samples, elapsed)
+	} else {
+		entry.samples[entry.next] = elapsed
+		entry.next = (entry.next + 1) % slowSamples
+	}
+	explain := s.explainInterval > 0 && explainable(sql) && now.Sub(entry.explainAt) >= s.explainInterval
+	if explain {
+		entry.explainAt = now
+	}
+	s.mu.Unlock()
+
+	if !explain {
+		return
+	}
+	// 事务在 EXPLAIN 执行时可能已结束，使用该库的连接池
+	pool := stmt.ConnPool
+	if _, ok := pool.(gorm.TxCommitter); ok {
+		pool = db.Config.ConnPool
+	}
+	job := explainJob{
+		fingerprint: fingerprint,
+		pool:        pool,
+		dialect:     db.Dialector.Name(),
+		sql:         sql,
+		vars:        slices.Clone(stmt.Vars),
+	}
+	select {
+	case s.jobs <- job:
+	default:
+		// 队列已满时放弃本次，下一次出现时重试
+		s.mu.Lock()
+		entry.explainAt = time.Time{}
+		s.mu.Unlock()
+	}
+}
+
+func (s *slowLog) evictLocked() {
+	var oldest string
+	var oldestSeen time.Time
+	for fingerprint, entry := range s.entries {
+		if oldest == "" || entry.query.LastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = fingerprint, entry.query.LastSeen
+		}
+	}
+	delete(s.entries, oldest)
+}
+
+// start 启动执行 EXPLAIN 的后台任务，由 DatabaseService 调用
+func (s *slowLog) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil || s.threshold <= 0 || s.explainInterval <= 0 {
+		return
+	}
+	loopCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	safego.Go(loopCtx, func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case job := <-s.jobs:
+				s.explain(ctx, job)
+			}
+		}
+	})
+}
+
+func (s *slowLog) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// explain 在语句原来的连接池上执行 EXPLAIN，直接使用连接池而不经过 gorm 回调
+func (s *slowLog) explain(ctx context.Context, job explainJob) {
+	ctx, cancel := context.WithTimeout(ctx, explainTimeout)
+	defer cancel()
+	prefix := "EXPLAIN "
+	if job.dialect == DriverSQLite {
+		prefix = "EXPLAIN QUERY PLAN "
+	}
+	plan, err := queryMaps(ctx, job.pool, prefix+job.sql, job.vars...)
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[job.fingerprint]
+	if !ok {
+		return
+	}
+	if err != nil {
+		slog.DebugContext(ctx, "failed to explain slow query", "fingerprint", job.fingerprint, "err", err)
+		entry.query.PlanError = err.Error()
+		return
+	}
+	entry.query.Plan, entry.query.PlanAt, entry.query.PlanError = plan, &now, ""
+}
+
+func queryMaps(ctx context.Context, pool gorm.ConnPool, sql string, vars ...any) ([]map[string]any, error) {
+	rows, err := pool.QueryContext(ctx, sql, vars...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]any
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[column] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func (s *slowLog) report() []SlowQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queries := make([]SlowQuery, 0, len(s.entries))
+	for _, entry := range s.entries {
+		q := entry.query
+		q.TotalMs = milliseconds(entry.total)
+		q.AvgMs = milliseconds(entry.total / time.Duration(q.Count))
+		q.MaxMs = milliseconds(entry.max)
+		q.P95Ms = milliseconds(percentile(entry.samples, 0.95))
+		queries = append(queries, q)
+	}
+	// 总耗时最高的排在前面，优化收益最大
+	slices.SortFunc(queries, func(a, b SlowQuery) int {
+		if a.TotalMs != b.TotalMs {
+			if a.TotalMs > b.TotalMs {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Fingerprint, b.Fingerprint)
+	})
+	return queries
+}
+
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	return sorted[int(float64(len(sorted)-1)*p+0.5)]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func explainable(sql string) bool {
+	head := strings.ToUpper(strings.TrimSpace(sql))
+	return strings.HasPrefix(head, "SELECT") || strings.HasPrefix(head, "WITH")
+}
+
+// Fingerprint 将语句中的字面量和占位符归一化，IN 列表折叠为 (?+)，返回指纹和归一化后的语句
+func Fingerprint(sql string) (string, string) {
+	normalized := stringLiteral.ReplaceAllString(sql, "?")
+	normalized = pgPlaceholder.ReplaceAllString(normalized, "?")
+	normalized = numberLiteral.ReplaceAllString(normalized, "?")
+	normalized = placeholders.ReplaceAllString(normalized, "(?+)")
+	normalized = strings.TrimSpace(whitespace.ReplaceAllString(normalized, " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:8]), normalized
+}
+
+// SlowQueries 返回慢查询报告，按总耗时降序；未设置慢查询阈值时返回空
+func SlowQueries(db *gorm.DB) []SlowQuery {
+	if s := slowLogOf(db); s != nil {
+		return s.report()
+	}
+	return nil
+}
+
+// ResetSlowQueries 清空慢查询统计
+func ResetSlowQueries(db *gorm.DB) {
+	if s := slowLogOf(db); s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		clear(s.entries)
+	}
+}
+
+func slowLogOf(db *gorm.DB) *slowLog {
+	s, _ := db.Config.Plugins[slowLogName].(*slowLog)
+	return s
+}
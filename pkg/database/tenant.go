@@ -0,0 +1,190 @@
+/*
+Copyright © 2025 lixw
+*/
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// ContextKeyTenantID 当前请求的租户ID（uint），gin 处理器可直接 ctx.Set(ContextKeyTenantID, id)
+	ContextKeyTenantID = "tenantId"
+	// ContextKeySkipTenant 为 true 时不按租户隔离，仅用于管理任务
+	ContextKeySkipTenant = "dbSkipTenant"
+
+	tenantScopeName = "database:tenant"
+	tenantColumn    = "tenant_id"
+)
+
+var (
+	ErrTenantMissing = errors.New("tenant is not set in context")
+	ErrCrossTenant   = errors.New("cross-tenant write is not allowed")
+)
+
+// TenantModel 按租户隔离的模型与 Model 一起嵌入，安装 TenantScope 后读写自动限定在上下文中的租户内
+type TenantModel struct {
+	TenantID uint `json:"tenant_id" gorm:"column:tenant_id;not null;index;comment:租户ID"`
+}
+
+// WithTenant 返回绑定租户的上下文
+func WithTenant(ctx context.Context, tenantID uint) context.Context {
+	return context.WithValue(ctx, ContextKeyTenantID, tenantID)
+}
+
+// SkipTenant 返回不按租户隔离的上下文，用于跨租户的管理任务，调用方需自行保证权限
+func SkipTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ContextKeySkipTenant, true)
+}
+
+// TenantFrom 返回上下文中的租户ID
+func TenantFrom(ctx context.Context) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	tenantID, ok := ctx.Value(ContextKeyTenantID).(uint)
+	return tenantID, ok && tenantID != 0
+}
+
+func skipTenant(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	skip, _ := ctx.Value(ContextKeySkipTenant).(bool)
+	return skip
+}
+
+// TenantScope 多租户行隔离插件：查询、更新、删除自动追加 tenant_id 条件，创建时写入租户ID，
+// 禁止写入或改为其他租户；上下文缺少租户时拒绝执行。Raw/Exec 语句不做处理
+type TenantScope struct{}
+
+func NewTenantScope() *TenantScope {
+	return &TenantScope{}
+}
+
+func (s *TenantScope) Name() string {
+	return tenantScopeName
+}
+
+func (s *TenantScope) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register(tenantScopeName, s.filter); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register(tenantScopeName, s.filter); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register(tenantScopeName, s.update); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register(tenantScopeName, s.filter); err != nil {
+		return err
+	}
+	return cb.Create().Before("gorm:create").Register(tenantScopeName, s.create)
+}
+
+// tenant 返回需要隔离时的租户ID，模型未嵌入 TenantModel 或已跳过时返回 false
+func (s *TenantScope) tenant(db *gorm.DB) (*schema.Field, uint, bool) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.SQL.Len() > 0 || skipTenant(stmt.Context) {
+		return nil, 0, false
+	}
+	field := stmt.Schema.LookUpField(tenantColumn)
+	if field == nil || len(field.BindNames) < 2 || field.BindNames[0] != "TenantModel" {
+		return nil, 0, false
+	}
+	tenantID, ok := TenantFrom(stmt.Context)
+	if !ok {
+		_ = db.AddError(ErrTenantMissing)
+		return nil, 0, false
+	}
+	return field, tenantID, true
+}
+
+func (s *TenantScope) filter(db *gorm.DB) {
+	if _, tenantID, ok := s.tenant(db); ok {
+		db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: tenantColumn}, Value: tenantID},
+		}})
+	}
+}
+
+// update 追加租户条件，更新内容中的租户ID须与当前租户一致
+func (s *TenantScope) update(db *gorm.DB) {
+	field, tenantID, ok := s.tenant(db)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+	var assigned any
+	switch dest := stmt.Dest.(type) {
+	case map[string]any:
+		if v, ok := dest[field.DBName]; ok {
+			assigned = v
+		} else {
+			assigned = dest[field.Name]
+		}
+	default:
+		if rv := reflect.Indirect(reflect.ValueOf(stmt.Dest)); rv.Kind() == reflect.Struct && rv.Type() == stmt.Schema.ModelType {
+			if v, zero := field.ValueOf(stmt.Context, rv); !zero {
+				assigned = v
+			}
+		}
+	}
+	if assigned != nil && !sameTenant(assigned, tenantID) {
+		_ = db.AddError(ErrCrossTenant)
+		return
+	}
+	s.filter(db)
+}
+
+// create 未设置租户ID时写入当前租户，已设置为其他租户时拒绝
+func (s *TenantScope) create(db *gorm.DB) {
+	field, tenantID, ok := s.tenant(db)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+	set := func(rv reflect.Value) {
+		v, zero := field.ValueOf(stmt.Context, rv)
+		if zero {
+			_ = db.AddError(field.Set(stmt.Context, rv, tenantID))
+		} else if !sameTenant(v, tenantID) {
+			_ = db.AddError(ErrCrossTenant)
+		}
+	}
+	switch rv := stmt.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		set(rv)
+	case reflect.Map:
+		if m, ok := stmt.Dest.(map[string]any); ok {
+			if v, ok := m[field.DBName]; !ok {
+				m[field.DBName] = tenantID
+			} else if !sameTenant(v, tenantID) {
+				_ = db.AddError(ErrCrossTenant)
+			}
+		}
+	}
+}
+
+func sameTenant(v any, tenantID uint) bool {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() == uint64(tenantID)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() >= 0 && uint64(rv.Int()) == uint64(tenantID)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright © 2025 lixw
+*/
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+)
+
+type note struct {
+	database.Model
+	database.TenantModel
+	Title string
+}
+
+// newDB 创建安装了 plugins 的 SQLite 临时库，并创建 models 的表
+func newDB(t *testing.T, plugins []gorm.Plugin, models ...any) *gorm.DB {
+	t.Helper()
+	db, err := database.New(
+		database.WithUrl("sqlite://"+filepath.Join(t.TempDir(), "app.db")),
+		database.WithPlugins(plugins...),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTenantDB 租户 1 和租户 2 各有两条记录：a、shared
+func newTenantDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := newDB(t, []gorm.Plugin{database.NewTenantScope()}, &note{})
+	for _, tenantID := range []uint{1, 2} {
+		notes := []*note{{Title: "a"}, {Title: "shared"}}
+		if err := db.WithContext(database.WithTenant(context.Background(), tenantID)).Create(&notes).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func titles(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+	var notes []note
+	if err := db.Order("tenant_id, id").Find(&notes).Error; err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, n := range notes {
+		got = append(got, fmt.Sprintf("%s@%d", n.Title, n.TenantID))
+	}
+	return got
+}
+
+func TestTenantScopeFilter(t *testing.T) {
+	db := newTenantDB(t)
+	tenant1 := db.WithContext(database.WithTenant(context.Background(), 1))
+	all := db.WithContext(database.SkipTenant(context.Background()))
+
+	if got := titles(t, tenant1); !slices.Equal(got, []string{"a@1", "shared@1"}) {
+		t.Fatalf("query = %v", got)
+	}
+	var n note
+	if err := tenant1.Where("title = ?", "a").Take(&n).Error; err != nil || n.TenantID != 1 {
+		t.Fatalf("take = %+v, err = %v", n, err)
+	}
+	// 按主键读取其他租户的记录
+	var other note
+	if err := all.Where("tenant_id = ?", 2).First(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tenant1.First(&note{}, other.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("first other tenant err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	var count int64
+	if err := tenant1.Model(&note{}).Count(&count).Error; err != nil || count != 2 {
+		t.Fatalf("count = %d, err = %v", count, err)
+	}
+	if err := tenant1.Model(&note{}).Select("COUNT(*)").Row().Scan(&count); err != nil || count != 2 {
+		t.Fatalf("row count = %d, err = %v", count, err)
+	}
+
+	if err := tenant1.Where("title = ?", "shared").Delete(&note{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tenant1.Delete(&note{}, other.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(t, all); !slices.Equal(got, []string{"a@1", "a@2", "shared@2"}) {
+		t.Fatalf("after delete = %v", got)
+	}
+	res := tenant1.Model(&note{}).Where("id = ?", other.ID).Update("title", "b")
+	if res.Error != nil || res.RowsAffected != 0 {
+		t.Fatalf("update other tenant affected %d, err = %v", res.RowsAffected, res.Error)
+	}
+}
+
+func TestTenantScopeCreate(t *testing.T) {
+	db := newDB(t, []gorm.Plugin{database.NewTenantScope()}, &note{})
+	ctx := database.WithTenant(context.Background(), 3)
+
+	n := &note{Title: "struct"}
+	if err := db.WithContext(ctx).Create(n).Error; err != nil || n.TenantID != 3 {
+		t.Fatalf("create = %+v, err = %v", n, err)
+	}
+	batch := []*note{{Title: "b1"}, {Title: "b2", TenantModel: database.TenantModel{TenantID: 3}}}
+	if err := db.WithContext(ctx).Create(&batch).Error; err != nil || batch[0].TenantID != 3 {
+		t.Fatalf("create batch = %+v, err = %v", batch[0], err)
+	}
+	if err := db.WithContext(ctx).Model(&note{}).Create(map[string]any{"title": "map"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(t, db.WithContext(database.SkipTenant(ctx))); !slices.Equal(got, []string{"struct@3", "b1@3", "b2@3", "map@3"}) {
+		t.Fatalf("created = %v", got)
+	}
+}
+
+func TestTenantScopeCrossTenant(t *testing.T) {
+	db := newTenantDB(t)
+	tenant1 := db.WithContext(database.WithTenant(context.Background(), 1))
+	var n note
+	if err := tenant1.Where("title = ?", "a").Take(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for name, fn := range map[string]func() error{
+		"create struct": func() error {
+			return tenant1.Create(&note{Title: "x", TenantModel: database.TenantModel{TenantID: 2}}).Error
+		},
+		"create batch": func() error {
+			return tenant1.Create([]*note{{Title: "x"}, {Title: "y", TenantModel: database.TenantModel{TenantID: 2}}}).Error
+		},
+		"create map": func() error {
+			return tenant1.Model(&note{}).Create(map[string]any{"title": "x", "tenant_id": 2}).Error
+		},
+		"updates struct": func() error {
+			return tenant1.Model(&note{}).Where("id = ?", n.ID).Updates(&note{TenantModel: database.TenantModel{TenantID: 2}}).Error
+		},
+		"updates map": func() error {
+			return tenant1.Model(&note{}).Where("id = ?", n.ID).Updates(map[string]any{"tenant_id": 2}).Error
+		},
+		"updates map field name": func() error {
+			return tenant1.Model(&note{}).Where("id = ?", n.ID).Updates(map[string]any{"TenantID": uint(2)}).Error
+		},
+		"update column": func() error {
+			return tenant1.Model(&note{}).Where("id = ?", n.ID).Update("tenant_id", 2).Error
+		},
+		"update column negative": func() error {
+			return tenant1.Model(&note{}).Where("id = ?", n.ID).Update("tenant_id", -1).Error
+		},
+		"save": func() error {
+			moved := n
+			moved.TenantID = 2
+			return tenant1.Save(&moved).Error
+		},
+	} {
+		if err := fn(); !errors.Is(err, database.ErrCrossTenant) {
+			t.Errorf("%s: err = %v, want %v", name, err, database.ErrCrossTenant)
+		}
+	}
+	if got := titles(t, db.WithContext(database.SkipTenant(context.Background()))); !slices.Equal(got, []string{"a@1", "shared@1", "a@2", "shared@2"}) {
+		t.Fatalf("rows changed: %v", got)
+	}
+	// 写入当前租户自身的ID是允许的
+	if err := tenant1.Model(&note{}).Where("id = ?", n.ID).Updates(map[string]any{"tenant_id": 1, "title": "renamed"}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTenantScopeMissing(t *testing.T) {
+	db := newTenantDB(t)
+	ctx := context.Background()
+	for name, err := range map[string]error{
+		"find":   db.WithContext(ctx).Find(&[]note{}).Error,
+		"rows":   rowsErr(db.WithContext(ctx).Model(&note{}).Select("COUNT(*)").Rows()),
+		"create": db.WithContext(ctx).Create(&note{Title: "x"}).Error,
+		"update": db.WithContext(ctx).Model(&note{}).Where("title = ?", "a").Update("title", "b").Error,
+		"delete": db.WithContext(ctx).Where("title = ?", "a").Delete(&note{}).Error,
+		// 租户ID 0 视为未设置
+		"zero": db.WithContext(database.WithTenant(ctx, 0)).Find(&[]note{}).Error,
+	} {
+		if !errors.Is(err, database.ErrTenantMissing) {
+			t.Errorf("%s: err = %v, want %v", name, err, database.ErrTenantMissing)
+		}
+	}
+}
+
+func rowsErr(rows *sql.Rows, err error) error {
+	if rows != nil {
+		_ = rows.Close()
+	}
+	return err
+}
+
+func TestTenantScopeSkip(t *testing.T) {
+	db := newTenantDB(t)
+	all := db.WithContext(database.SkipTenant(context.Background()))
+	if got := titles(t, all); len(got) != 4 {
+		t.Fatalf("skip query = %v", got)
+	}
+	res := all.Model(&note{}).Where("title = ?", "shared").Update("title", "renamed")
+	if res.Error != nil || res.RowsAffected != 2 {
+		t.Fatalf("skip update affected %d, err = %v", res.RowsAffected, res.Error)
+	}
+	// 跳过隔离时可以迁移记录到其他租户
+	if err := all.Model(&note{}).Where("tenant_id = ? AND title = ?", 2, "a").Update("tenant_id", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(t, db.WithContext(database.WithTenant(context.Background(), 1))); !slices.Equal(got, []string{"a@1", "renamed@1", "a@1"}) {
+		t.Fatalf("tenant 1 = %v", got)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2025 lixw
+*/
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ContextKeyTx 当前事务，由 TxManager.Do 写入，仓储通过 Conn 读取
+	ContextKeyTx = "dbTx"
+	// ContextKeyAfterCommit 最外层事务提交后执行的回调，由 AfterCommit 注册
+	ContextKeyAfterCommit = "dbAfterCommit"
+)
+
+type commitHooks struct {
+	mu  sync.Mutex
+	fns []func(ctx context.Context)
+}
+
+// TxManager 将事务保存在上下文中，使同一调用链上的多个仓储共享一个事务
+type TxManager struct {
+	db *gorm.DB
+}
+
+func NewTxManager(db *gorm.DB) *TxManager {
+	return &TxManager{db: db}
+}
+
+// Do 在事务中执行 fn，fn 返回错误或 panic 时回滚；ctx 中已有事务时嵌套为保存点，
+// 内层失败只回滚到保存点，由外层决定是否整体回滚。租户库与共享库不能在同一事务中读写
+func (m *TxManager) Do(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	hooks, nested := ctx.Value(ContextKeyAfterCommit).(*commitHooks)
+	if !nested {
+		hooks = &commitHooks{}
+		ctx = context.WithValue(ctx, ContextKeyAfterCommit, hooks)
+	}
+	err := Conn(ctx, m.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, ContextKeyTx, tx))
+	}, opts...)
+	if err != nil || nested {
+		return err
+	}
+	for _, hook := range hooks.fns {
+		hook(ctx)
+	}
+	return nil
+}
+
+// AfterCommit 在 TxManager 的最外层事务提交后执行 fn，回滚时不执行；不在事务中时立即执行。
+// 保存点回滚不会撤销其中注册的回调，fn 需要能够重复执行
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	hooks, ok := ctx.Value(ContextKeyAfterCommit).(*commitHooks)
+	if !ok || !InTx(ctx) {
+		fn(ctx)
+		return
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.fns = append(hooks.fns, fn)
+}
+
+// DB 返回 ctx 中的事务或非事务连接
+func (m *TxManager) DB(ctx context.Context) *gorm.DB {
+	return Conn(ctx, m.db)
+}
+
+// Conn 返回 ctx 中的事务，不在事务中时返回 db，启用租户路由时返回 ctx 中租户的独立库，均已绑定 ctx；
+// 仓储应通过 Conn 获取连接而不是直接使用 db.WithContext。打开租户库失败时返回的连接带有错误
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(ContextKeyTx).(*gorm.DB); ok && tx != nil {
+		return tx.WithContext(ctx)
+	}
+	if r := routerOf(db); r != nil {
+		tdb, err := r.resolve(ctx)
+		if err != nil {
+			conn := db.WithContext(ctx)
+			_ = conn.AddError(err)
+			return conn
+		}
+		if tdb != nil {
+			return tdb.WithContext(ctx)
+		}
+	}
+	return db.WithContext(ctx)
+}
+
+// InTx ctx 是否处于事务中
+func InTx(ctx context.Context) bool {
+	tx, ok := ctx.Value(ContextKeyTx).(*gorm.DB)
+	return ok && tx != nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright © 2025 lixw
+*/
+package database
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	versionLockName = "database:version"
+	versionColumn   = "version"
+)
+
+// ErrVersionConflict 更新时版本号不一致，记录已被其他请求修改或删除
+var ErrVersionConflict = errorx.New(errorx.ErrCodeConflict, "record has been modified by another request")
+
+// VersionModel 乐观锁版本号，与 Model 一起嵌入，安装 OptimisticLock 后更新时校验并递增版本号
+type VersionModel struct {
+	Version uint `json:"version" gorm:"column:version;not null;default:1;comment:乐观锁版本号"`
+}
+
+// OptimisticLock 乐观锁插件：更新内容带版本号时追加 version 条件，未更新到记录时返回 ErrVersionConflict，
+// 成功后将新版本号写回；不带版本号时不做校验，版本号照常递增。创建时版本号从 1 开始
+type OptimisticLock struct{}
+
+func NewOptimisticLock() *OptimisticLock {
+	return &OptimisticLock{}
+}
+
+func (l *OptimisticLock) Name() string {
+	return versionLockName
+}
+
+func (l *OptimisticLock) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Update().Before("gorm:update").Register(versionLockName, l.before); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register(versionLockName+":check", l.after); err != nil {
+		return err
+	}
+	return cb.Create().Before("gorm:create").Register(versionLockName, l.create)
+}
+
+// field 返回嵌入的版本号字段，模型未嵌入 VersionModel 时返回 nil
+func (l *OptimisticLock) field(db *gorm.DB) *schema.Field {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.SQL.Len() > 0 {
+		return nil
+	}
+	field := stmt.Schema.LookUpField(versionColumn)
+	if field == nil || len(field.BindNames) < 2 || field.BindNames[0] != "VersionModel" {
+		return nil
+	}
+	return field
+}
+
+// before 自行生成 SET 子句并追加 version = version + 1，已通过 Clauses 指定 SET 时不处理
+func (l *OptimisticLock) before(db *gorm.DB) {
+	field := l.field(db)
+	if field == nil {
+		return
+	}
+	stmt := db.Statement
+	if _, ok := stmt.Clauses["SET"]; ok {
+		return
+	}
+	expected := l.expected(stmt, field)
+	set := callbacks.ConvertToAssignments(stmt)
+	if db.Error != nil || len(set) == 0 {
+		return
+	}
+	// 没有条件时交由 gorm 拒绝全表更新，避免版本条件使其绕过检查
+	if _, ok := stmt.Clauses["WHERE"]; !ok && !stmt.AllowGlobalUpdate {
+		return
+	}
+	set = slices.DeleteFunc(set, func(a clause.Assignment) bool { return a.Column.Name == field.DBName })
+	column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	if expected > 0 {
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.Eq{Column: column, Value: expected}}})
+		set = append(set, clause.Assignment{Column: clause.Column{Name: field.DBName}, Value: expected + 1})
+	} else {
+		set = append(set, clause.Assignment{Column: clause.Column{Name: field.DBName}, Value: clause.Expr{SQL: "? + 1", Vars: []any{column}}})
+	}
+	stmt.AddClause(set)
+	stmt.Settings.Store(versionLockName, expected)
+}
+
+// after 清理 before 生成的 SET 子句，校验是否更新到记录并写回新版本号
+func (l *OptimisticLock) after(db *gorm.DB) {
+	stmt := db.Statement
+	v, ok := stmt.Settings.LoadAndDelete(versionLockName)
+	if !ok {
+		return
+	}
+	delete(stmt.Clauses, "SET")
+	expected := v.(uint)
+	if db.Error != nil || expected == 0 {
+		return
+	}
+	if db.RowsAffected == 0 {
+		_ = db.AddError(ErrVersionConflict)
+		return
+	}
+	stmt.SetColumn(versionColumn, expected+1, true)
+}
+
+// expected 返回更新内容中的版本号，未给出时返回 0
+func (l *OptimisticLock) expected(stmt *gorm.Statement, field *schema.Field) uint {
+	var value any
+	switch dest := stmt.Dest.(type) {
+	case map[string]any:
+		if v, ok := dest[field.DBName]; ok {
+			value = v
+		} else {
+			value = dest[field.Name]
+		}
+	default:
+		rv := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+		if rv.Kind() != reflect.Struct || rv.Type() != stmt.Schema.ModelType {
+			return 0
+		}
+		value, _ = field.ValueOf(stmt.Context, rv)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uint(rv.Uint())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() > 0 {
+			return uint(rv.Int())
+		}
+	}
+	return 0
+}
+
+// create 未设置版本号时写入 1，不依赖数据库默认值回填
+func (l *OptimisticLock) create(db *gorm.DB) {
+	field := l.field(db)
+	if field == nil {
+		return
+	}
+	stmt := db.Statement
+	set := func(rv reflect.Value) {
+		if _, zero := field.ValueOf(stmt.Context, rv); zero {
+			_ = db.AddError(field.Set(stmt.Context, rv, 1))
+		}
+	}
+	switch rv := stmt.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		set(rv)
+	}
+}
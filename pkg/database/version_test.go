@@ -0,0 +1,145 @@
+/*
+Copyright © 2025 lixw
+*/
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+)
+
+type doc struct {
+	database.Model
+	database.VersionModel
+	Title string
+	Body  string
+}
+
+func newVersionDB(t *testing.T) (*gorm.DB, *doc) {
+	t.Helper()
+	db := newDB(t, []gorm.Plugin{database.NewOptimisticLock()}, &doc{}).WithContext(context.Background())
+	d := &doc{Title: "t", Body: "b"}
+	if err := db.Create(d).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db, d
+}
+
+// load 读取数据库中的记录
+func load(t *testing.T, db *gorm.DB, id uint) *doc {
+	t.Helper()
+	var d doc
+	if err := db.First(&d, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &d
+}
+
+func TestOptimisticLockCreate(t *testing.T) {
+	db, d := newVersionDB(t)
+	if d.Version != 1 || load(t, db, d.ID).Version != 1 {
+		t.Fatalf("version = %d, want 1", d.Version)
+	}
+	batch := []*doc{{Title: "a"}, {Title: "b"}}
+	if err := db.Create(&batch).Error; err != nil || batch[0].Version != 1 || batch[1].Version != 1 {
+		t.Fatalf("batch = %+v %+v, err = %v", batch[0], batch[1], err)
+	}
+}
+
+func TestOptimisticLockStale(t *testing.T) {
+	db, d := newVersionDB(t)
+	stale := load(t, db, d.ID)
+
+	d.Title = "first"
+	if err := db.Model(&doc{}).Where("id = ?", d.ID).Updates(d).Error; err != nil {
+		t.Fatal(err)
+	}
+	if d.Version != 2 {
+		t.Fatalf("version written back = %d, want 2", d.Version)
+	}
+	stale.Title = "second"
+	if err := db.Model(&doc{}).Where("id = ?", stale.ID).Updates(stale).Error; !errors.Is(err, database.ErrVersionConflict) {
+		t.Fatalf("stale update err = %v, want %v", err, database.ErrVersionConflict)
+	}
+	if got := load(t, db, d.ID); got.Title != "first" || got.Version != 2 {
+		t.Fatalf("row = %+v, want first@2", got)
+	}
+	// 记录已删除时同样返回冲突
+	if err := db.Delete(&doc{}, d.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&doc{}).Where("id = ?", d.ID).Updates(d).Error; !errors.Is(err, database.ErrVersionConflict) {
+		t.Fatalf("update deleted err = %v, want %v", err, database.ErrVersionConflict)
+	}
+}
+
+func TestOptimisticLockSelect(t *testing.T) {
+	db, d := newVersionDB(t)
+	update := &doc{Title: "selected", Body: "ignored", VersionModel: database.VersionModel{Version: 1}}
+	if err := db.Model(&doc{}).Where("id = ?", d.ID).Select("title", "updated_at").Updates(update).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := load(t, db, d.ID); got.Title != "selected" || got.Body != "b" || got.Version != 2 || update.Version != 2 {
+		t.Fatalf("row = %+v, update version = %d", got, update.Version)
+	}
+	// 只选择部分字段时仍按更新内容中的版本号校验
+	update.Version = 1
+	if err := db.Model(&doc{}).Where("id = ?", d.ID).Select("title").Updates(update).Error; !errors.Is(err, database.ErrVersionConflict) {
+		t.Fatalf("stale select update err = %v, want %v", err, database.ErrVersionConflict)
+	}
+	// 选择零值字段
+	if err := db.Model(&doc{}).Where("id = ?", d.ID).Select("body").Updates(&doc{VersionModel: database.VersionModel{Version: 2}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := load(t, db, d.ID); got.Body != "" || got.Version != 3 {
+		t.Fatalf("row = %+v, want empty body@3", got)
+	}
+}
+
+func TestOptimisticLockMap(t *testing.T) {
+	db, d := newVersionDB(t)
+	if err := db.Model(&doc{}).Where("id = ?", d.ID).Updates(map[string]any{"title": "m", "version": 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&doc{}).Where("id = ?", d.ID).Updates(map[string]any{"title": "stale", "version": 1}).Error; !errors.Is(err, database.ErrVersionConflict) {
+		t.Fatalf("stale map update err = %v, want %v", err, database.ErrVersionConflict)
+	}
+	if err := db.Model(&doc{}).Where("id = ?", d.ID).Updates(map[string]any{"Title": "field", "Version": uint(2)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := load(t, db, d.ID); got.Title != "field" || got.Version != 3 {
+		t.Fatalf("row = %+v, want field@3", got)
+	}
+}
+
+// TestOptimisticLockUnversioned 不带版本号的更新不做校验，版本号照常递增
+func TestOptimisticLockUnversioned(t *testing.T) {
+	db, d := newVersionDB(t)
+	for i, update := range []func() error{
+		func() error { return db.Model(&doc{}).Where("id = ?", d.ID).Update("title", "u1").Error },
+		func() error {
+			return db.Model(&doc{}).Where("id = ?", d.ID).Updates(map[string]any{"title": "u2"}).Error
+		},
+		func() error { return db.Model(&doc{}).Where("id = ?", d.ID).Updates(&doc{Title: "u3"}).Error },
+	} {
+		if err := update(); err != nil {
+			t.Fatal(err)
+		}
+		if got := load(t, db, d.ID); got.Version != uint(i+2) {
+			t.Fatalf("update %d: version = %d, want %d", i, got.Version, i+2)
+		}
+	}
+	// 未更新到记录时不报冲突
+	res := db.Model(&doc{}).Where("id = ?", d.ID+100).Update("title", "missing")
+	if res.Error != nil || res.RowsAffected != 0 {
+		t.Fatalf("update missing affected %d, err = %v", res.RowsAffected, res.Error)
+	}
+	// 没有条件的更新仍被 gorm 拒绝
+	if err := db.Model(&doc{}).Update("title", "all").Error; !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Fatalf("global update err = %v, want %v", err, gorm.ErrMissingWhereClause)
+	}
+}
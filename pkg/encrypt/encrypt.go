@@ -0,0 +1,378 @@
+/*
+Copyright © 2025 lixw
+*/
+
+// Package encrypt 字段级加密，字段声明 gorm:"serializer:encrypted" 后透明加解密（AES-GCM 信封加密），
+// 支持主密钥轮换和盲索引：
+//
+//	type Tenant struct {
+//		ApiKey      string `gorm:"column:api_key;size:255;serializer:encrypted"`
+//		ApiKeyIndex string `gorm:"column:api_key_bidx;size:32;index" blind:"ApiKey"`
+//	}
+//
+//	db.Where(encrypt.Match("ApiKey", key)).First(&tenant)
+package encrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const pluginName = "encrypt"
+
+// active 序列化器由 gorm 全局注册，进程内只能使用一个密钥环，由插件安装时设置
+var active atomic.Pointer[Keyring]
+
+var ErrNoKeyring = errors.New("encrypt: keyring is not configured (install the encrypt plugin)")
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// Serializer 加密字段的序列化器，支持 string 和 []byte 字段；空值不加密，
+// 读取到未加密的旧数据时原样返回，由 Rotator 在后台加密
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("encrypt: unsupported column value %T for %s", dbValue, field.Name)
+	}
+	plaintext := []byte(value)
+	if Encrypted(value) {
+		keyring := active.Load()
+		if keyring == nil {
+			return ErrNoKeyring
+		}
+		var err error
+		if plaintext, err = keyring.Decrypt(aad(field), value); err != nil {
+			return fmt.Errorf("%w: %s", err, field.Name)
+		}
+	}
+	rv := field.ReflectValueOf(ctx, dst)
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(string(plaintext))
+	case reflect.Slice:
+		if value == "" {
+			rv.SetBytes(nil)
+		} else {
+			rv.SetBytes(plaintext)
+		}
+	default:
+		return fmt.Errorf("encrypt: unsupported field type %s for %s", rv.Type(), field.Name)
+	}
+	return nil
+}
+
+func (Serializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue any) (any, error) {
+	var plaintext []byte
+	switch v := fieldValue.(type) {
+	case string:
+		plaintext = []byte(v)
+	case []byte:
+		plaintext = v
+	default:
+		return nil, fmt.Errorf("encrypt: unsupported field type %T for %s", fieldValue, field.Name)
+	}
+	if len(plaintext) == 0 {
+		return "", nil
+	}
+	keyring := active.Load()
+	if keyring == nil {
+		return nil, ErrNoKeyring
+	}
+	return keyring.Encrypt(aad(field), plaintext)
+}
+
+// aad 密文绑定到 表.列
+func aad(field *schema.Field) string {
+	return field.Schema.Table + "." + field.DBName
+}
+
+// EncryptFields 原地加密 model（结构体指针或切片）中的加密字段，用于在数据库之外（如缓存）保存模型时不留明文，
+// 空值和已加密的值保持不变；DecryptFields 为其逆操作
+func EncryptFields(db *gorm.DB, model any) error {
+	return eachEncrypted(db, model, func(keyring *Keyring, aad, value string) (string, error) {
+		if value == "" || Encrypted(value) {
+			return value, nil
+		}
+		if keyring == nil {
+			return "", ErrNoKeyring
+		}
+		return keyring.Encrypt(aad, []byte(value))
+	})
+}
+
+// DecryptFields 原地解密 EncryptFields 加密的字段，未加密的值保持不变
+func DecryptFields(db *gorm.DB, model any) error {
+	return eachEncrypted(db, model, func(keyring *Keyring, aad, value string) (string, error) {
+		if !Encrypted(value) {
+			return value, nil
+		}
+		if keyring == nil {
+			return "", ErrNoKeyring
+		}
+		plaintext, err := keyring.Decrypt(aad, value)
+		return string(plaintext), err
+	})
+}
+
+// eachEncrypted 对 model 每一行的加密字段调用 fn，并写回其返回值
+func eachEncrypted(db *gorm.DB, model any, fn func(keyring *Keyring, aad, value string) (string, error)) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	var fields []*schema.Field
+	for _, field := range stmt.Schema.Fields {
+		if _, ok := field.Serializer.(Serializer); ok && field.DBName != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	keyring := active.Load()
+	ctx := context.Background()
+	var err error
+	eachRow(reflect.Indirect(reflect.ValueOf(model)), func(rv reflect.Value) {
+		for _, field := range fields {
+			if err != nil {
+				return
+			}
+			fv := field.ReflectValueOf(ctx, rv)
+			var value string
+			switch fv.Kind() {
+			case reflect.String:
+				value = fv.String()
+			case reflect.Slice:
+				value = string(fv.Bytes())
+			default:
+				err = fmt.Errorf("encrypt: unsupported field type %s for %s", fv.Type(), field.Name)
+				return
+			}
+			var result string
+			if result, err = fn(keyring, aad(field), value); err != nil {
+				err = fmt.Errorf("%w: %s", err, field.Name)
+				return
+			}
+			if fv.Kind() == reflect.String {
+				fv.SetString(result)
+			} else if result == "" {
+				fv.SetBytes(nil)
+			} else {
+				fv.SetBytes([]byte(result))
+			}
+		}
+	})
+	return err
+}
+
+// Encryptor 安装密钥环，同时是 gorm 插件：创建和更新时根据 blind 标签计算盲索引字段，
+// 标签值为被索引的加密字段名，按字段 Select 更新时自动带上对应的盲索引列；
+// gorm 不对 map 中的值调用序列化器，插件在写入前加密 map 中的加密字段
+type Encryptor struct {
+	keyring *Keyring
+}
+
+func New(keyring *Keyring) *Encryptor {
+	return &Encryptor{keyring: keyring}
+}
+
+// Of 返回 db 上安装的加密插件，未安装时返回 nil
+func Of(db *gorm.DB) *Encryptor {
+	e, _ := db.Config.Plugins[pluginName].(*Encryptor)
+	return e
+}
+
+func (e *Encryptor) Name() string {
+	return pluginName
+}
+
+func (e *Encryptor) Keyring() *Keyring {
+	return e.keyring
+}
+
+// Initialize 在模型钩子之后、生成 SET 子句（如乐观锁插件）之前处理写入的值
+func (e *Encryptor) Initialize(db *gorm.DB) error {
+	active.Store(e.keyring)
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:save_before_associations").Register(pluginName+":create", e.prepare); err != nil {
+		return err
+	}
+	return cb.Update().Before("gorm:save_before_associations").Register(pluginName+":update", e.prepare)
+}
+
+type blindField struct {
+	index  *schema.Field
+	source *schema.Field
+}
+
+var blindCache sync.Map
+
+// blindFields 返回带 blind 标签的字段及其被索引的字段
+func blindFields(sch *schema.Schema) []blindField {
+	if v, ok := blindCache.Load(sch); ok {
+		return v.([]blindField)
+	}
+	var fields []blindField
+	for _, field := range sch.Fields {
+		if name := field.Tag.Get("blind"); name != "" && field.DBName != "" {
+			if source := sch.LookUpField(name); source != nil {
+				fields = append(fields, blindField{index: field, source: source})
+			}
+		}
+	}
+	blindCache.Store(sch, fields)
+	return fields
+}
+
+func (e *Encryptor) prepare(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	if dest, ok := stmt.Dest.(map[string]any); ok {
+		if err := e.prepareMap(stmt.Schema, dest); err != nil {
+			_ = db.AddError(err)
+		}
+		return
+	}
+	fields := blindFields(stmt.Schema)
+	for _, f := range fields {
+		if len(stmt.Selects) > 0 && selected(stmt.Selects, f.source) && !selected(stmt.Selects, f.index) {
+			stmt.Selects = append(stmt.Selects, f.index.DBName)
+		}
+	}
+	eachRow(reflect.Indirect(reflect.ValueOf(stmt.Dest)), func(rv reflect.Value) {
+		if rv.Type() != stmt.Schema.ModelType {
+			return
+		}
+		for _, f := range fields {
+			index, err := e.keyring.BlindIndex(aad(f.source), plaintextOf(f.source.ReflectValueOf(stmt.Context, rv).Interface()))
+			if err == nil {
+				err = f.index.Set(stmt.Context, rv, index)
+			}
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+	})
+}
+
+// prepareMap 为 map 中的加密字段计算盲索引并加密
+func (e *Encryptor) prepareMap(sch *schema.Schema, dest map[string]any) error {
+	for _, f := range blindFields(sch) {
+		for _, key := range []string{f.source.DBName, f.source.Name} {
+			if v, ok := dest[key]; ok {
+				index, err := e.keyring.BlindIndex(aad(f.source), plaintextOf(v))
+				if err != nil {
+					return err
+				}
+				dest[f.index.DBName] = index
+			}
+		}
+	}
+	for key, v := range dest {
+		field := sch.LookUpField(key)
+		if field == nil {
+			continue
+		}
+		if _, ok := field.Serializer.(Serializer); !ok {
+			continue
+		}
+		plaintext := plaintextOf(v)
+		if plaintext == "" || Encrypted(plaintext) {
+			continue
+		}
+		ciphertext, err := e.keyring.Encrypt(aad(field), []byte(plaintext))
+		if err != nil {
+			return err
+		}
+		dest[key] = ciphertext
+	}
+	return nil
+}
+
+func selected(selects []string, field *schema.Field) bool {
+	return slices.Contains(selects, field.DBName) || slices.Contains(selects, field.Name)
+}
+
+func plaintextOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func eachRow(rv reflect.Value, fn func(rv reflect.Value)) {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			fn(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		fn(rv)
+	}
+}
+
+// Match 按盲索引等值查询加密字段，field 为加密字段的字段名或列名
+func Match(field, value string) clause.Expression {
+	return match{field: field, value: value}
+}
+
+type match struct {
+	field string
+	value string
+}
+
+func (m match) Build(builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok || stmt.Schema == nil {
+		_ = builder.AddError(fmt.Errorf("encrypt: Match(%s) requires a model", m.field))
+		return
+	}
+	keyring := active.Load()
+	if keyring == nil {
+		_ = builder.AddError(ErrNoKeyring)
+		return
+	}
+	for _, f := range blindFields(stmt.Schema) {
+		if f.source.Name != m.field && f.source.DBName != m.field {
+			continue
+		}
+		index, err := keyring.BlindIndex(aad(f.source), m.value)
+		if err != nil {
+			_ = builder.AddError(err)
+			return
+		}
+		builder.WriteQuoted(clause.Column{Table: clause.CurrentTable, Name: f.index.DBName})
+		_, _ = builder.WriteString(" = ")
+		builder.AddVar(builder, index)
+		return
+	}
+	_ = builder.AddError(fmt.Errorf("encrypt: %s.%s has no blind index", stmt.Schema.Table, m.field))
+}
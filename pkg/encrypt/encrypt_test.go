@@ -0,0 +1,185 @@
+/*
+Copyright © 2025 lixw
+*/
+package encrypt_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/encrypt"
+	"gorm.io/gorm"
+)
+
+type secret struct {
+	ID         uint
+	Token      string `gorm:"size:512;serializer:encrypted"`
+	TokenIndex string `gorm:"size:32;index" blind:"Token"`
+	Note       string `gorm:"size:512;serializer:encrypted"`
+}
+
+func key(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+var blindIndexKey = key(9)
+
+func newKeyring(t *testing.T, primary uint32, versions ...uint32) *encrypt.Keyring {
+	t.Helper()
+	keys := make(map[uint32]string)
+	for _, version := range versions {
+		keys[version] = key(byte(version))
+	}
+	keyring, err := encrypt.NewKeyring(primary, keys, blindIndexKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+// open 打开 file 并安装使用 keyring 的加密插件；序列化器使用最近安装的密钥环
+func open(t *testing.T, file string, keyring *encrypt.Keyring) *gorm.DB {
+	t.Helper()
+	db, err := database.New(database.WithUrl("sqlite://"+file), database.WithPlugins(encrypt.New(keyring)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&secret{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// raw 返回 id 对应记录的列原始值
+func raw(t *testing.T, db *gorm.DB, id uint, column string) string {
+	t.Helper()
+	var values []string
+	if err := db.Table("secrets").Where("id = ?", id).Pluck(column, &values).Error; err != nil || len(values) != 1 {
+		t.Fatalf("pluck %s of %d: %v, %v", column, id, values, err)
+	}
+	return values[0]
+}
+
+func find(t *testing.T, db *gorm.DB, token string) *secret {
+	t.Helper()
+	var s secret
+	if err := db.Where(encrypt.Match("Token", token)).First(&s).Error; err != nil {
+		t.Fatalf("match %s: %v", token, err)
+	}
+	return &s
+}
+
+func TestRoundTrip(t *testing.T) {
+	db := open(t, filepath.Join(t.TempDir(), "app.db"), newKeyring(t, 1, 1))
+	s := &secret{Token: "tok-a", Note: "note-a"}
+	if err := db.Create(s).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []string{"token", "note"} {
+		if value := raw(t, db, s.ID, column); !strings.HasPrefix(value, "ev1.1.") {
+			t.Errorf("%s = %q, want a v1 ciphertext", column, value)
+		}
+	}
+	if index := raw(t, db, s.ID, "token_index"); len(index) != 32 {
+		t.Errorf("token_index = %q, want a blind index", index)
+	}
+
+	got := find(t, db, "tok-a")
+	if got.Token != "tok-a" || got.Note != "note-a" {
+		t.Errorf("got %+v, want tok-a/note-a", got)
+	}
+	// 按字段更新时同步更新盲索引
+	if err := db.Model(got).Select("Token").Updates(&secret{Token: "tok-b"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := find(t, db, "tok-b"); got.ID != s.ID {
+		t.Errorf("id = %d, want %d", got.ID, s.ID)
+	}
+	if err := db.Where(encrypt.Match("Token", "tok-a")).First(&secret{}).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("match old token: err = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "app.db")
+	db := open(t, file, newKeyring(t, 1, 1))
+	old := &secret{Token: "tok-a", Note: "note-a"}
+	if err := db.Create(old).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 未加密的旧数据
+	if err := db.Exec("INSERT INTO secrets (token, token_index, note) VALUES (?, '', '')", "tok-plain").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// primary 切换到 v2 后 v1 的记录仍可读取，新记录使用 v2
+	keyring := newKeyring(t, 2, 1, 2)
+	db = open(t, file, keyring)
+	if got := find(t, db, "tok-a"); got.Note != "note-a" {
+		t.Errorf("note = %q, want note-a", got.Note)
+	}
+	fresh := &secret{Token: "tok-c", Note: "note-c"}
+	if err := db.Create(fresh).Error; err != nil {
+		t.Fatal(err)
+	}
+	if value := raw(t, db, fresh.ID, "token"); !strings.HasPrefix(value, "ev1.2.") {
+		t.Errorf("token = %q, want a v2 ciphertext", value)
+	}
+
+	rotator := encrypt.NewRotator(db, keyring, encrypt.WithModels(&secret{}), encrypt.WithBatchSize(1))
+	if n := rotator.Run(ctx); n != 2 {
+		t.Errorf("rotated = %d, want 2", n)
+	}
+	if n := rotator.Run(ctx); n != 0 {
+		t.Errorf("second run rotated = %d, want 0", n)
+	}
+	for _, id := range []uint{1, 2, 3} {
+		if value := raw(t, db, id, "token"); !strings.HasPrefix(value, "ev1.2.") {
+			t.Errorf("token of %d = %q, want a v2 ciphertext", id, value)
+		}
+	}
+
+	// 轮换后移除 v1 仍可读取全部记录，盲索引不随主密钥变化
+	db = open(t, file, newKeyring(t, 2, 2))
+	for token, note := range map[string]string{"tok-a": "note-a", "tok-plain": "", "tok-c": "note-c"} {
+		if got := find(t, db, token); got.Token != token || got.Note != note {
+			t.Errorf("got %+v, want %s/%s", got, token, note)
+		}
+	}
+}
+
+func TestAAD(t *testing.T) {
+	keyring := newKeyring(t, 1, 1)
+	db := open(t, filepath.Join(t.TempDir(), "app.db"), keyring)
+	s := &secret{Token: "tok-a", Note: "note-a"}
+	if err := db.Create(s).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 密文绑定到 表.列，复制到其他列后无法解密
+	token := raw(t, db, s.ID, "token")
+	if err := db.Exec("UPDATE secrets SET note = ? WHERE id = ?", token, s.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&secret{}, s.ID).Error; !errors.Is(err, encrypt.ErrInvalidCiphertext) {
+		t.Errorf("err = %v, want ErrInvalidCiphertext", err)
+	}
+	if _, err := keyring.Decrypt("secrets.note", token); !errors.Is(err, encrypt.ErrInvalidCiphertext) {
+		t.Errorf("decrypt: err = %v, want ErrInvalidCiphertext", err)
+	}
+	if plaintext, err := keyring.Decrypt("secrets.token", token); err != nil || string(plaintext) != "tok-a" {
+		t.Errorf("decrypt = %q, %v, want tok-a", plaintext, err)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2025 lixw
+*/
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// prefix 密文格式 ev1.<密钥版本>.<加密的数据密钥>.<加密的数据>，不以此开头的值视为未加密的旧数据
+const prefix = "ev1."
+
+var (
+	ErrUnknownKey        = errors.New("encrypt: unknown key version")
+	ErrInvalidCiphertext = errors.New("encrypt: invalid ciphertext")
+	ErrNoBlindIndexKey   = errors.New("encrypt: blind index key is not configured")
+)
+
+// Keyring 按版本管理的主密钥，新数据使用 primary 版本加密，旧版本只用于解密。
+// 每个值使用随机的数据密钥加密，主密钥只加密数据密钥（信封加密），轮换时只需重新加密数据密钥
+type Keyring struct {
+	primary  uint32
+	keys     map[uint32]cipher.AEAD
+	indexKey []byte
+}
+
+// NewKeyring keys 为版本号到 base64 编码的 AES 密钥（16、24 或 32 字节），primary 为加密新数据使用的版本；
+// blindIndexKey 为 base64 编码的盲索引密钥（至少 16 字节），不随主密钥轮换，为空时不支持盲索引
+func NewKeyring(primary uint32, keys map[uint32]string, blindIndexKey string) (*Keyring, error) {
+	k := &Keyring{primary: primary, keys: make(map[uint32]cipher.AEAD, len(keys))}
+	for version, key := range keys {
+		raw, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt: invalid key %d: %w", version, err)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt: invalid key %d: %w", version, err)
+		}
+		k.keys[version] = aead
+	}
+	if _, ok := k.keys[primary]; !ok {
+		return nil, fmt.Errorf("encrypt: primary key %d is not configured", primary)
+	}
+	if blindIndexKey != "" {
+		raw, err := base64.StdEncoding.DecodeString(blindIndexKey)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt: invalid blind index key: %w", err)
+		}
+		if len(raw) < 16 {
+			return nil, errors.New("encrypt: blind index key must be at least 16 bytes")
+		}
+		k.indexKey = raw
+	}
+	return k, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *Keyring) Primary() uint32 {
+	return k.primary
+}
+
+// Encrypt 使用随机数据密钥加密 plaintext，aad 为附加认证数据（如 表.列），解密时须一致，防止密文被挪用到其他列
+func (k *Keyring) Encrypt(aad string, plaintext []byte) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(data, plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(aad, dek, sealed)
+}
+
+// Decrypt 解密 Encrypt 的结果，使用密文中记录的主密钥版本
+func (k *Keyring) Decrypt(aad, value string) ([]byte, error) {
+	version, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := k.unwrap(aad, version, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	return open(data, sealed, aad)
+}
+
+// Rewrap 使用 primary 版本重新加密数据密钥，数据部分不变；已是 primary 版本时原样返回
+func (k *Keyring) Rewrap(aad, value string) (string, error) {
+	version, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	if version == k.primary {
+		return value, nil
+	}
+	dek, err := k.unwrap(aad, version, wrapped)
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(aad, dek, sealed)
+}
+
+// Current 报告 value 是否已使用 primary 版本加密
+func (k *Keyring) Current(value string) bool {
+	return strings.HasPrefix(value, prefix+strconv.FormatUint(uint64(k.primary), 10)+".")
+}
+
+// Encrypted 报告 value 是否为密文
+func Encrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// BlindIndex 返回 value 的 HMAC-SHA256 摘要（32 位十六进制），相同的 aad 和 value 结果相同，可用于等值查询；
+// 空值返回空字符串
+func (k *Keyring) BlindIndex(aad, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if len(k.indexKey) == 0 {
+		return "", ErrNoBlindIndexKey
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(aad))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16]), nil
+}
+
+func (k *Keyring) wrap(aad string, dek, sealed []byte) (string, error) {
+	wrapped, err := seal(k.keys[k.primary], dek, aad)
+	if err != nil {
+		return "", err
+	}
+	return prefix + strconv.FormatUint(uint64(k.primary), 10) + "." +
+		base64.RawURLEncoding.EncodeToString(wrapped) + "." +
+		base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (k *Keyring) unwrap(aad string, version uint32, wrapped []byte) ([]byte, error) {
+	kek, ok := k.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownKey, version)
+	}
+	return open(kek, wrapped, aad)
+}
+
+func parse(value string) (uint32, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ".")
+	if !Encrypted(value) || len(parts) != 3 {
+		return 0, nil, nil, ErrInvalidCiphertext
+	}
+	version, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, nil, nil, ErrInvalidCiphertext
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, nil, nil, ErrInvalidCiphertext
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, nil, ErrInvalidCiphertext
+	}
+	return uint32(version), wrapped, sealed, nil
+}
+
+// seal 返回 nonce 与密文的拼接
+func seal(aead cipher.AEAD, plaintext []byte, aad string) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(aad)), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, aad string) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
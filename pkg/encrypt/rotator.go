@@ -0,0 +1,251 @@
+/*
+Copyright © 2025 lixw
+*/
+package encrypt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/safego"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type Options struct {
+	models    []any
+	interval  time.Duration
+	batchSize int
+}
+
+type Option func(*Options)
+
+// WithModels 需要重新加密的模型
+func WithModels(models ...any) Option {
+	return func(o *Options) {
+		o.models = append(o.models, models...)
+	}
+}
+
+// WithInterval 扫描间隔，默认 1 小时；启动时立即执行一轮
+func WithInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		if interval > 0 {
+			o.interval = interval
+		}
+	}
+}
+
+// WithBatchSize 每次读取的记录数，默认 100
+func WithBatchSize(batchSize int) Option {
+	return func(o *Options) {
+		if batchSize > 0 {
+			o.batchSize = batchSize
+		}
+	}
+}
+
+// Rotator 重新加密服务（app.Service），将旧版本主密钥加密的字段改为 primary 版本（只重新加密数据密钥），
+// 并加密未加密的旧数据、补齐其盲索引。按主键比较旧值后更新，与并发写入和多实例同时执行互不影响；
+// 不更新 updated_at 和版本号。启用租户路由时同时处理独立存储的租户库
+type Rotator struct {
+	db      *gorm.DB
+	keyring *Keyring
+	opts    *Options
+	schemas sync.Map
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewRotator(db *gorm.DB, keyring *Keyring, options ...Option) *Rotator {
+	opts := &Options{
+		interval:  time.Hour,
+		batchSize: 100,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	return &Rotator{db: db, keyring: keyring, opts: opts}
+}
+
+func (r *Rotator) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil || len(r.opts.models) == 0 {
+		return nil
+	}
+	loopCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	safego.Go(loopCtx, func(ctx context.Context) {
+		defer close(r.done)
+		ticker := time.NewTicker(r.opts.interval)
+		defer ticker.Stop()
+		for {
+			if n := r.Run(ctx); n > 0 {
+				slog.InfoContext(ctx, "encrypted fields rotated", "rows", n, "primary", r.keyring.Primary())
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+	slog.InfoContext(ctx, "encryption rotator started", "primary", r.keyring.Primary())
+	return nil
+}
+
+func (r *Rotator) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	r.cancel = nil
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	slog.InfoContext(ctx, "encryption rotator stopped")
+	return nil
+}
+
+// Run 执行一轮重新加密，返回更新的记录数；通常由 Start 启动的循环调用
+func (r *Rotator) Run(ctx context.Context) int {
+	ctx = database.SkipTenant(ctx)
+	total := r.runAll(database.WithShared(ctx), false)
+	tenants, err := database.RoutedTenants(ctx, r.db)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list tenant databases", "err", err)
+		return total
+	}
+	for _, id := range tenants {
+		if ctx.Err() != nil {
+			break
+		}
+		total += r.runAll(database.WithTenant(ctx, id), true)
+	}
+	return total
+}
+
+// runAll 处理全部模型；租户库中跳过没有对应表的模型，如只在共享库中的租户注册表、未执行迁移的租户库
+func (r *Rotator) runAll(ctx context.Context, tenantDB bool) int {
+	total := 0
+	for _, model := range r.opts.models {
+		if tenantDB && !database.Conn(ctx, r.db).Migrator().HasTable(model) {
+			continue
+		}
+		n, err := r.rotate(ctx, model)
+		total += n
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to rotate encrypted fields", "model", fmt.Sprintf("%T", model), "err", err)
+		}
+	}
+	return total
+}
+
+// rotate 按主键分批处理模型中需要重新加密的记录
+func (r *Rotator) rotate(ctx context.Context, model any) (int, error) {
+	sch, err := schema.Parse(model, &r.schemas, r.db.NamingStrategy)
+	if err != nil {
+		return 0, err
+	}
+	pk := sch.PrioritizedPrimaryField
+	if pk == nil || len(sch.PrimaryFields) != 1 {
+		return 0, fmt.Errorf("encrypt: %s requires a single primary key", sch.Table)
+	}
+	var fields []*schema.Field
+	for _, field := range sch.Fields {
+		if _, ok := field.Serializer.(Serializer); ok && field.DBName != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	indexes := make(map[string]*schema.Field)
+	for _, f := range blindFields(sch) {
+		indexes[f.source.DBName] = f.index
+	}
+	db := database.Conn(ctx, r.db)
+	columns := []string{pk.DBName}
+	var conds []string
+	var args []any
+	current := prefix + strconv.FormatUint(uint64(r.keyring.Primary()), 10) + ".%"
+	for _, field := range fields {
+		columns = append(columns, field.DBName)
+		column := db.Statement.Quote(field.DBName)
+		conds = append(conds, fmt.Sprintf("(%s <> '' AND %s NOT LIKE ?)", column, column))
+		args = append(args, current)
+	}
+	stale := strings.Join(conds, " OR ")
+	total := 0
+	var last any
+	for ctx.Err() == nil {
+		tx := db.Table(sch.Table).Select(columns).Where(stale, args...).Order(pk.DBName).Limit(r.opts.batchSize)
+		if last != nil {
+			tx = tx.Where(db.Statement.Quote(pk.DBName)+" > ?", last)
+		}
+		var rows []map[string]any
+		if err := tx.Find(&rows).Error; err != nil {
+			return total, err
+		}
+		for _, row := range rows {
+			last = row[pk.DBName]
+			n, err := r.rotateRow(db, sch, pk, fields, indexes, row)
+			if err != nil {
+				return total, err
+			}
+			total += n
+		}
+		if len(rows) < r.opts.batchSize {
+			break
+		}
+	}
+	return total, nil
+}
+
+// rotateRow 旧值未被并发修改时更新，返回更新的记录数；无法解密的记录记录日志后跳过
+func (r *Rotator) rotateRow(db *gorm.DB, sch *schema.Schema, pk *schema.Field, fields []*schema.Field, indexes map[string]*schema.Field, row map[string]any) (int, error) {
+	tx := db.Table(sch.Table).Where(db.Statement.Quote(pk.DBName)+" = ?", row[pk.DBName])
+	updates := make(map[string]any)
+	for _, field := range fields {
+		value := plaintextOf(row[field.DBName])
+		if value == "" || r.keyring.Current(value) {
+			continue
+		}
+		var (
+			next string
+			err  error
+		)
+		if Encrypted(value) {
+			next, err = r.keyring.Rewrap(aad(field), value)
+		} else {
+			next, err = r.keyring.Encrypt(aad(field), []byte(value))
+			if index, ok := indexes[field.DBName]; ok && err == nil {
+				updates[index.DBName], err = r.keyring.BlindIndex(aad(field), value)
+			}
+		}
+		if err != nil {
+			slog.WarnContext(db.Statement.Context, "failed to rotate encrypted field", "table", sch.Table, "id", row[pk.DBName], "column", field.DBName, "err", err)
+			return 0, nil
+		}
+		updates[field.DBName] = next
+		tx = tx.Where(db.Statement.Quote(field.DBName)+" = ?", value)
+	}
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	res := tx.Updates(updates)
+	return int(res.RowsAffected), res.Error
+}
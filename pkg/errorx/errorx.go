@@ -6,6 +6,7 @@ package errorx
 import (
 	"errors"
 	"fmt"
+	"net/http"
 )
 
 const (
@@ -31,12 +32,36 @@ const (
 	ErrCodeServiceUnavailable = 10008
 	// ErrCodeTimeout 表示请求超时（对应HTTP 504）
 	ErrCodeTimeout = 10009
-	// ErrCodeValidation 表示数据校验失败
+	// ErrCodeValidation 表示数据校验失败（对应HTTP 422）
 	ErrCodeValidation = 10010
-	// ErrCodePermissionDenied 表示权限拒绝（更细化的权限错误）
+	// ErrCodePermissionDenied 表示权限拒绝（更细化的权限错误，对应HTTP 403）
 	ErrCodePermissionDenied = 10011
 )
 
+var httpStatus = map[int]int{
+	ErrCodeSuccess:            http.StatusOK,
+	ErrCodeBadRequest:         http.StatusBadRequest,
+	ErrCodeUnauthorized:       http.StatusUnauthorized,
+	ErrCodeForbidden:          http.StatusForbidden,
+	ErrCodeNotFound:           http.StatusNotFound,
+	ErrCodeMethodNotAllowed:   http.StatusMethodNotAllowed,
+	ErrCodeConflict:           http.StatusConflict,
+	ErrCodeTooManyRequests:    http.StatusTooManyRequests,
+	ErrCodeInternalServer:     http.StatusInternalServerError,
+	ErrCodeServiceUnavailable: http.StatusServiceUnavailable,
+	ErrCodeTimeout:            http.StatusGatewayTimeout,
+	ErrCodeValidation:         http.StatusUnprocessableEntity,
+	ErrCodePermissionDenied:   http.StatusForbidden,
+}
+
+// HTTPStatus 返回错误码对应的HTTP状态码，未知错误码按服务器内部错误处理
+func HTTPStatus(code int) int {
+	if status, ok := httpStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
 type WrappedError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
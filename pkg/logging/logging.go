@@ -105,17 +105,7 @@ func Init(options ...Option) {
 	for _, option := range options {
 		option(opts)
 	}
-	var writer io.Writer
-	writer = &lumberjack.Logger{
-		Filename:   opts.path,
-		MaxSize:    opts.maxSize,
-		MaxAge:     opts.maxAge,
-		MaxBackups: opts.maxBackups,
-		Compress:   opts.compress,
-	}
-	if opts.enableStdout {
-		writer = io.MultiWriter(os.Stdout, writer)
-	}
+	writer := newWriter(opts)
 	handlerOptions := &slog.HandlerOptions{
 		Level:     parseLevel(opts.level),
 		AddSource: true,
@@ -140,6 +130,36 @@ func Init(options ...Option) {
 	slog.Info("logger initialized", "path", opts.path, "format", opts.format, "level", opts.level)
 }
 
+// NewWriter creates a rotating file writer, e.g. for the access log.
+func NewWriter(options ...Option) io.Writer {
+	opts := &Options{
+		path:       "./logs/access.log",
+		maxAge:     7,
+		maxSize:    128,
+		maxBackups: 32,
+		compress:   true,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	return newWriter(opts)
+}
+
+func newWriter(opts *Options) io.Writer {
+	var writer io.Writer
+	writer = &lumberjack.Logger{
+		Filename:   opts.path,
+		MaxSize:    opts.maxSize,
+		MaxAge:     opts.maxAge,
+		MaxBackups: opts.maxBackups,
+		Compress:   opts.compress,
+	}
+	if opts.enableStdout {
+		writer = io.MultiWriter(os.Stdout, writer)
+	}
+	return writer
+}
+
 // parseLevel parses a log level string and returns the corresponding slog.Level.
 func parseLevel(level string) slog.Level {
 	switch level {
@@ -0,0 +1,124 @@
+/*
+Copyright © 2025 lixw
+*/
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	lockRetryInterval = 500 * time.Millisecond
+	// lockStaleAfter 锁表中超过该时长的锁视为进程异常退出遗留，可被抢占
+	lockStaleAfter = 30 * time.Minute
+)
+
+// migrationLock 锁表记录，用于不支持会话级咨询锁的驱动（SQLite）
+type migrationLock struct {
+	ID       int       `gorm:"column:id;primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"column:owner;size:255"`
+	LockedAt time.Time `gorm:"column:locked_at"`
+}
+
+// lock 获取迁移锁：MySQL 使用 GET_LOCK，PostgreSQL 使用 pg_advisory_lock，均绑定在独占连接上，
+// 进程退出时自动释放；其他驱动使用锁表
+func (m *Migrator) lock(ctx context.Context, db *gorm.DB) (func(), error) {
+	switch db.Dialector.Name() {
+	case "mysql":
+		return m.sessionLock(ctx, db, "SELECT GET_LOCK(?, 0)", "SELECT RELEASE_LOCK(?)", m.table)
+	case "postgres":
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(m.table))
+		key := int64(h.Sum64())
+		return m.sessionLock(ctx, db, "SELECT pg_try_advisory_lock($1)", "SELECT pg_advisory_unlock($1)", key)
+	default:
+		return m.tableLock(ctx, db)
+	}
+}
+
+func (m *Migrator) sessionLock(ctx context.Context, db *gorm.DB, lockSQL, unlockSQL string, key any) (func(), error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = m.retry(ctx, func() (bool, error) {
+		var locked sql.NullBool
+		if err := conn.QueryRowContext(ctx, lockSQL, key).Scan(&locked); err != nil {
+			return false, err
+		}
+		return locked.Bool, nil
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), unlockSQL, key); err != nil {
+			slog.WarnContext(ctx, "failed to release migration lock", "err", err)
+		}
+		_ = conn.Close()
+	}, nil
+}
+
+func (m *Migrator) tableLock(ctx context.Context, db *gorm.DB) (func(), error) {
+	table := m.table + "_lock"
+	if err := db.Table(table).AutoMigrate(&migrationLock{}); err != nil {
+		return nil, fmt.Errorf("failed to create migration lock table: %w", err)
+	}
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	err := m.retry(ctx, func() (bool, error) {
+		if err := db.Table(table).Where("locked_at < ?", time.Now().Add(-lockStaleAfter)).Delete(&migrationLock{}).Error; err != nil {
+			return false, err
+		}
+		// 主键冲突说明锁已被持有，不记录冲突错误日志
+		quiet := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)})
+		return quiet.Table(table).Create(&migrationLock{ID: 1, Owner: owner, LockedAt: time.Now()}).Error == nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := db.WithContext(context.WithoutCancel(ctx)).Table(table).Where("id = ? AND owner = ?", 1, owner).Delete(&migrationLock{}).Error; err != nil {
+			slog.WarnContext(ctx, "failed to release migration lock", "err", err)
+		}
+	}, nil
+}
+
+// retry 在超时前反复尝试获取锁
+func (m *Migrator) retry(ctx context.Context, try func() (bool, error)) error {
+	deadline := time.Now().Add(m.lockTimeout)
+	for waited := false; ; waited = true {
+		locked, err := try()
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if locked {
+			return nil
+		}
+		if !waited {
+			slog.InfoContext(ctx, "waiting for migration lock held by another process")
+		}
+		if time.Now().After(deadline) {
+			return ErrLockTimeout
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,381 @@
+/*
+Copyright © 2025 lixw
+*/
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+)
+
+const (
+	StateApplied = "applied"
+	StatePending = "pending"
+	// StateModified 已执行的迁移内容被修改，校验和不一致
+	StateModified = "modified"
+	// StateMissing 已执行的迁移在当前代码中不存在
+	StateMissing = "missing"
+)
+
+var (
+	ErrIrreversible     = errors.New("migration is irreversible")
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrLockTimeout      = errors.New("timed out waiting for migration lock")
+)
+
+// Func Go 编写的迁移，在事务中执行（MySQL 的 DDL 会隐式提交）
+type Func func(ctx context.Context, tx *gorm.DB) error
+
+// Migration 按版本号升序执行的迁移，Version 通常为 yyyyMMddHHmmss 格式的时间戳
+type Migration struct {
+	Version int64
+	Name    string
+	Up      Func
+	// Down 为 nil 时迁移不可回滚
+	Down Func
+	// Checksum 为空时不校验（Go 迁移），SQL 迁移为 up 脚本的 SHA256
+	Checksum string
+}
+
+// History 迁移历史记录
+type History struct {
+	Version   int64     `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255;not null"`
+	Checksum  string    `gorm:"column:checksum;size:64"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+	// 执行耗时（毫秒）
+	ExecutionTime int64 `gorm:"column:execution_time"`
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   int64
+	Name      string
+	State     string
+	AppliedAt *time.Time
+}
+
+type Options struct {
+	fsys        fs.FS
+	dir         string
+	migrations  []*Migration
+	table       string
+	lockTimeout time.Duration
+}
+
+type Option func(*Options)
+
+// WithFS 从文件系统（通常为 embed.FS）加载 SQL 迁移，文件名格式为 {version}_{name}.up.sql / {version}_{name}.down.sql，
+// 带驱动后缀的文件（如 .up.postgres.sql）仅在对应驱动下使用并优先于通用文件
+func WithFS(fsys fs.FS, dir string) Option {
+	return func(o *Options) {
+		o.fsys = fsys
+		o.dir = dir
+	}
+}
+
+// WithMigrations 注册 Go 编写的迁移
+func WithMigrations(migrations ...*Migration) Option {
+	return func(o *Options) {
+		o.migrations = append(o.migrations, migrations...)
+	}
+}
+
+// WithTable 设置迁移历史表名，默认 schema_migrations
+func WithTable(table string) Option {
+	return func(o *Options) {
+		o.table = table
+	}
+}
+
+// WithLockTimeout 设置等待迁移锁的超时时间，默认 1 分钟
+func WithLockTimeout(lockTimeout time.Duration) Option {
+	return func(o *Options) {
+		o.lockTimeout = lockTimeout
+	}
+}
+
+type Migrator struct {
+	db          *gorm.DB
+	migrations  []*Migration
+	table       string
+	lockTimeout time.Duration
+}
+
+func New(db *gorm.DB, options ...Option) (*Migrator, error) {
+	opts := &Options{
+		table:       "schema_migrations",
+		lockTimeout: time.Minute,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	migrations := slices.Clone(opts.migrations)
+	if opts.fsys != nil {
+		loaded, err := load(opts.fsys, opts.dir, db.Dialector.Name())
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, loaded...)
+	}
+	slices.SortFunc(migrations, func(a, b *Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		table:       opts.table,
+		lockTimeout: opts.lockTimeout,
+	}, nil
+}
+
+// Migrations 返回按版本排序的全部迁移
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+// Status 返回全部迁移及历史表中已不存在的迁移的状态
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.session(ctx)
+	if err := m.ensureTables(db); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(db)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []Status
+	for _, mg := range m.migrations {
+		status := Status{Version: mg.Version, Name: mg.Name, State: StatePending}
+		if h, ok := applied[mg.Version]; ok {
+			status.State = StateApplied
+			if mg.Checksum != "" && h.Checksum != "" && mg.Checksum != h.Checksum {
+				status.State = StateModified
+			}
+			status.AppliedAt = &h.AppliedAt
+			delete(applied, mg.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, h := range applied {
+		statuses = append(statuses, Status{Version: h.Version, Name: h.Name, State: StateMissing, AppliedAt: &h.AppliedAt})
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return statuses, nil
+}
+
+// Up 按顺序执行未执行的迁移，steps <= 0 时执行全部
+func (m *Migrator) Up(ctx context.Context, steps int) ([]*Migration, error) {
+	return m.run(ctx, func(applied map[int64]*History) ([]step, error) {
+		var pending []step
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; !ok {
+				pending = append(pending, step{migration: mg, up: true})
+			}
+		}
+		if steps > 0 && len(pending) > steps {
+			pending = pending[:steps]
+		}
+		return pending, nil
+	})
+}
+
+// Down 按倒序回滚最近执行的 steps 个迁移，steps <= 0 时回滚一个
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	return m.run(ctx, func(applied map[int64]*History) ([]step, error) {
+		done, err := m.appliedMigrations(applied)
+		if err != nil {
+			return nil, err
+		}
+		var down []step
+		for _, mg := range slices.Backward(done) {
+			if len(down) == steps {
+				break
+			}
+			down = append(down, step{migration: mg})
+		}
+		return down, nil
+	})
+}
+
+// To 迁移到指定版本：回滚高于该版本的已执行迁移，执行不超过该版本的未执行迁移，version 为 0 时回滚全部
+func (m *Migrator) To(ctx context.Context, version int64) ([]*Migration, error) {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(mg *Migration) bool { return mg.Version == version }) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.run(ctx, func(applied map[int64]*History) ([]step, error) {
+		done, err := m.appliedMigrations(applied)
+		if err != nil {
+			return nil, err
+		}
+		var steps []step
+		for _, mg := range slices.Backward(done) {
+			if mg.Version > version {
+				steps = append(steps, step{migration: mg})
+			}
+		}
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; !ok && mg.Version <= version {
+				steps = append(steps, step{migration: mg, up: true})
+			}
+		}
+		return steps, nil
+	})
+}
+
+// Redo 回滚并重新执行最近一个迁移
+func (m *Migrator) Redo(ctx context.Context) ([]*Migration, error) {
+	return m.run(ctx, func(applied map[int64]*History) ([]step, error) {
+		done, err := m.appliedMigrations(applied)
+		if err != nil || len(done) == 0 {
+			return nil, err
+		}
+		last := done[len(done)-1]
+		return []step{{migration: last}, {migration: last, up: true}}, nil
+	})
+}
+
+type step struct {
+	migration *Migration
+	up        bool
+}
+
+// run 持有迁移锁后根据最新的历史记录生成执行计划并逐个执行，每个迁移及其历史记录在同一事务中提交，
+// 返回已成功执行的迁移
+func (m *Migrator) run(ctx context.Context, plan func(applied map[int64]*History) ([]step, error)) ([]*Migration, error) {
+	db := m.session(ctx)
+	if err := m.ensureTables(db); err != nil {
+		return nil, err
+	}
+	unlock, err := m.lock(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+	steps, err := plan(applied)
+	if err != nil {
+		return nil, err
+	}
+	var done []*Migration
+	for _, st := range steps {
+		if err := m.apply(ctx, db, st.migration, st.up); err != nil {
+			return done, err
+		}
+		if !slices.Contains(done, st.migration) {
+			done = append(done, st.migration)
+		}
+	}
+	return done, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, db *gorm.DB, mg *Migration, up bool) error {
+	direction, fn := "up", mg.Up
+	if !up {
+		direction, fn = "down", mg.Down
+	}
+	if fn == nil {
+		return fmt.Errorf("%w: %d_%s", ErrIrreversible, mg.Version, mg.Name)
+	}
+	start := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+		if !up {
+			return tx.Table(m.table).Where("version = ?", mg.Version).Delete(&History{}).Error
+		}
+		return tx.Table(m.table).Create(&History{
+			Version:       mg.Version,
+			Name:          mg.Name,
+			Checksum:      mg.Checksum,
+			AppliedAt:     time.Now(),
+			ExecutionTime: time.Since(start).Milliseconds(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %w", mg.Version, mg.Name, direction, err)
+	}
+	slog.InfoContext(ctx, "migration applied", "version", mg.Version, "name", mg.Name, "direction", direction, "cost", time.Since(start))
+	return nil
+}
+
+// verify 已执行的迁移被修改时拒绝继续执行
+func (m *Migrator) verify(applied map[int64]*History) error {
+	for _, mg := range m.migrations {
+		h, ok := applied[mg.Version]
+		if ok && mg.Checksum != "" && h.Checksum != "" && mg.Checksum != h.Checksum {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, mg.Version, mg.Name)
+		}
+	}
+	return nil
+}
+
+// appliedMigrations 返回按版本升序的已执行迁移，历史中存在但代码中缺失的迁移无法回滚
+func (m *Migrator) appliedMigrations(applied map[int64]*History) ([]*Migration, error) {
+	var done []*Migration
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; ok {
+			done = append(done, mg)
+		}
+	}
+	if len(done) != len(applied) {
+		for version := range applied {
+			if !slices.ContainsFunc(done, func(mg *Migration) bool { return mg.Version == version }) {
+				return nil, fmt.Errorf("%w: %d is applied but missing from migrations", ErrUnknownVersion, version)
+			}
+		}
+	}
+	return done, nil
+}
+
+func (m *Migrator) applied(db *gorm.DB) (map[int64]*History, error) {
+	var histories []*History
+	if err := db.Table(m.table).Order("version").Find(&histories).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]*History, len(histories))
+	for _, h := range histories {
+		applied[h.Version] = h
+	}
+	return applied, nil
+}
+
+func (m *Migrator) ensureTables(db *gorm.DB) error {
+	if err := db.Table(m.table).AutoMigrate(&History{}); err != nil {
+		return fmt.Errorf("failed to create migration history table: %w", err)
+	}
+	return nil
+}
+
+// session 迁移及其历史查询始终走主库，数据迁移跨租户执行
+func (m *Migrator) session(ctx context.Context) *gorm.DB {
+	return m.db.WithContext(database.SkipTenant(database.WithPrimary(ctx)))
+}
@@ -0,0 +1,226 @@
+/*
+Copyright © 2025 lixw
+*/
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+func newDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return open(t, filepath.Join(t.TempDir(), "app.db"))
+}
+
+// open 打开 SQLite 库，SQLite 连接池只有一个连接，多次打开同一文件模拟多个进程
+func open(t *testing.T, file string) *gorm.DB {
+	t.Helper()
+	db, err := database.New(database.WithUrl("sqlite://" + file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// journal 记录 Go 迁移的执行顺序，如 up:1、down:2
+type journal struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (j *journal) migration(version int64) *migrate.Migration {
+	record := func(direction string) migrate.Func {
+		return func(ctx context.Context, tx *gorm.DB) error {
+			j.mu.Lock()
+			defer j.mu.Unlock()
+			j.steps = append(j.steps, fmt.Sprintf("%s:%d", direction, version))
+			return nil
+		}
+	}
+	return &migrate.Migration{Version: version, Name: fmt.Sprintf("m%d", version), Up: record("up"), Down: record("down")}
+}
+
+// take 返回并清空已记录的步骤
+func (j *journal) take() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	steps := j.steps
+	j.steps = nil
+	return steps
+}
+
+func versions(migrations []*migrate.Migration) []int64 {
+	var result []int64
+	for _, mg := range migrations {
+		result = append(result, mg.Version)
+	}
+	return result
+}
+
+func applied(t *testing.T, m *migrate.Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []int64
+	for _, status := range statuses {
+		if status.State == migrate.StateApplied {
+			result = append(result, status.Version)
+		}
+	}
+	return result
+}
+
+func TestMigratorOrder(t *testing.T) {
+	ctx := context.Background()
+	j := &journal{}
+	// 注册顺序与版本顺序不同，执行时按版本排序
+	m, err := migrate.New(newDB(t), migrate.WithMigrations(j.migration(3), j.migration(1), j.migration(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		run     func() ([]*migrate.Migration, error)
+		done    []int64
+		steps   []string
+		applied []int64
+	}{
+		{"up one", func() ([]*migrate.Migration, error) { return m.Up(ctx, 1) }, []int64{1}, []string{"up:1"}, []int64{1}},
+		{"up rest", func() ([]*migrate.Migration, error) { return m.Up(ctx, 0) }, []int64{2, 3}, []string{"up:2", "up:3"}, []int64{1, 2, 3}},
+		{"up nothing", func() ([]*migrate.Migration, error) { return m.Up(ctx, 0) }, nil, nil, []int64{1, 2, 3}},
+		{"redo", func() ([]*migrate.Migration, error) { return m.Redo(ctx) }, []int64{3}, []string{"down:3", "up:3"}, []int64{1, 2, 3}},
+		{"down two", func() ([]*migrate.Migration, error) { return m.Down(ctx, 2) }, []int64{3, 2}, []string{"down:3", "down:2"}, []int64{1}},
+		{"to 2", func() ([]*migrate.Migration, error) { return m.To(ctx, 2) }, []int64{2}, []string{"up:2"}, []int64{1, 2}},
+		{"to 1", func() ([]*migrate.Migration, error) { return m.To(ctx, 1) }, []int64{2}, []string{"down:2"}, []int64{1}},
+		{"to 0", func() ([]*migrate.Migration, error) { return m.To(ctx, 0) }, []int64{1}, []string{"down:1"}, nil},
+	}
+	for _, c := range cases {
+		done, err := c.run()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := versions(done); !slices.Equal(got, c.done) {
+			t.Errorf("%s: done = %v, want %v", c.name, got, c.done)
+		}
+		if got := j.take(); !slices.Equal(got, c.steps) {
+			t.Errorf("%s: steps = %v, want %v", c.name, got, c.steps)
+		}
+		if got := applied(t, m); !slices.Equal(got, c.applied) {
+			t.Errorf("%s: applied = %v, want %v", c.name, got, c.applied)
+		}
+	}
+
+	if _, err := m.To(ctx, 4); !errors.Is(err, migrate.ErrUnknownVersion) {
+		t.Errorf("to unknown version: err = %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestMigratorChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	fsys := fstest.MapFS{
+		"sql/1_create_user.up.sql":   {Data: []byte("CREATE TABLE user (id INTEGER PRIMARY KEY, name VARCHAR(64));")},
+		"sql/1_create_user.down.sql": {Data: []byte("DROP TABLE user;")},
+	}
+	m, err := migrate.New(db, migrate.WithFS(fsys, "sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Migrator().HasTable("user") {
+		t.Fatal("table user is not created")
+	}
+
+	// 已执行的脚本被修改后拒绝执行任何迁移
+	fsys["sql/1_create_user.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE user (id INTEGER PRIMARY KEY, name VARCHAR(128));")}
+	fsys["sql/2_create_role.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE role (id INTEGER PRIMARY KEY);")}
+	m, err = migrate.New(db, migrate.WithFS(fsys, "sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].State != migrate.StateModified {
+		t.Errorf("state = %s, want %s", statuses[0].State, migrate.StateModified)
+	}
+	if _, err := m.Up(ctx, 0); !errors.Is(err, migrate.ErrChecksumMismatch) {
+		t.Fatalf("up: err = %v, want ErrChecksumMismatch", err)
+	}
+	if _, err := m.Down(ctx, 1); !errors.Is(err, migrate.ErrChecksumMismatch) {
+		t.Fatalf("down: err = %v, want ErrChecksumMismatch", err)
+	}
+	if db.Migrator().HasTable("role") {
+		t.Error("migration 2 is applied despite the checksum mismatch")
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "app.db")
+	started, release := make(chan struct{}), make(chan struct{})
+	blocking := &migrate.Migration{Version: 1, Name: "blocking", Up: func(ctx context.Context, tx *gorm.DB) error {
+		close(started)
+		<-release
+		return nil
+	}}
+	j := &journal{}
+	first, err := migrate.New(open(t, file), migrate.WithMigrations(blocking))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := migrate.New(open(t, file), migrate.WithMigrations(blocking, j.migration(2)), migrate.WithLockTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := first.Up(ctx, 0)
+		errs <- err
+	}()
+	<-started
+	// 第一个迁移器持有锁期间第二个迁移器等待超时，不执行任何迁移
+	if _, err := second.Up(ctx, 0); !errors.Is(err, migrate.ErrLockTimeout) {
+		t.Fatalf("err = %v, want ErrLockTimeout", err)
+	}
+	if steps := j.take(); len(steps) > 0 {
+		t.Fatalf("steps = %v, want none", steps)
+	}
+
+	close(release)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	// 锁释放后第二个迁移器只执行剩余的迁移
+	done, err := second.Up(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !slices.Equal(got, []int64{2}) {
+		t.Errorf("done = %v, want [2]", got)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright © 2025 lixw
+*/
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Plan 模型定义与数据库表结构的差异
+type Plan struct {
+	// Statements 使表结构与模型一致需要执行的 DDL，按执行顺序排列
+	Statements []string
+	// Warnings 可能丢失数据的破坏性变更，如删除列、收窄类型
+	Warnings []string
+}
+
+// Drift 表结构与模型是否不一致
+func (p *Plan) Drift() bool {
+	return len(p.Statements) > 0 || len(p.Warnings) > 0
+}
+
+// Destructive 是否包含破坏性变更
+func (p *Plan) Destructive() bool {
+	return len(p.Warnings) > 0
+}
+
+// Diff 对比模型和数据库表结构，生成 AutoMigrate 会执行的 DDL 而不实际执行，
+// 模型中已不存在的列同样生成删除语句并标记为破坏性变更
+func Diff(ctx context.Context, db *gorm.DB, models ...any) (*Plan, error) {
+	tx := db.WithContext(database.SkipTenant(database.WithPrimary(ctx)))
+	rec := &recorder{ConnPool: tx.Statement.ConnPool, dialector: tx.Dialector}
+	tx.Statement.ConnPool = rec
+
+	plan := &Plan{}
+	for _, model := range models {
+		if err := diffModel(tx, model, plan); err != nil {
+			return nil, err
+		}
+	}
+	plan.Statements = rec.statements
+	return plan, nil
+}
+
+func diffModel(tx *gorm.DB, model any, plan *Plan) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	m := tx.Migrator()
+	exists := m.HasTable(model)
+	if err := m.AutoMigrate(model); err != nil {
+		return fmt.Errorf("failed to plan %s: %w", stmt.Schema.Table, err)
+	}
+	if !exists {
+		return nil
+	}
+	columns, err := m.ColumnTypes(model)
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		field := stmt.Schema.FieldsByDBName[column.Name()]
+		if field == nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s.%s: column is not in the model and will be dropped", stmt.Schema.Table, column.Name()))
+			if err := m.DropColumn(model, column.Name()); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.IgnoreMigration {
+			continue
+		}
+		if warning := narrowed(tx.Dialector, field, column); warning != "" {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s.%s: %s", stmt.Schema.Table, column.Name(), warning))
+		}
+	}
+	return nil
+}
+
+// narrowed 判断模型类型相对数据库列类型是否会丢失数据：类型族变化、整数位数变小、字符串长度或小数精度变小
+func narrowed(dialector gorm.Dialector, field *schema.Field, column gorm.ColumnType) string {
+	modelType := strings.ToLower(dialector.DataTypeOf(field))
+	liveType := strings.ToLower(column.DatabaseTypeName())
+	if full, ok := column.ColumnType(); ok && full != "" {
+		liveType = strings.ToLower(full)
+	}
+	modelFamily, liveFamily := typeFamily(modelType), typeFamily(liveType)
+	if modelFamily == "" || liveFamily == "" {
+		return ""
+	}
+	if modelFamily != liveFamily {
+		return fmt.Sprintf("type changes from %s to %s", liveType, modelType)
+	}
+	switch modelFamily {
+	case familyInt:
+		if intBits[baseType(modelType)] < intBits[baseType(liveType)] {
+			return fmt.Sprintf("integer narrows from %s to %s", liveType, modelType)
+		}
+	case familyString:
+		length, ok := column.Length()
+		if !ok || length <= 0 {
+			// 无长度的 text 类型视为不限长度
+			if unbounded[baseType(liveType)] && !unbounded[baseType(modelType)] {
+				return fmt.Sprintf("length narrows from %s to %s", liveType, modelType)
+			}
+			return ""
+		}
+		if field.Size > 0 && int64(field.Size) < length {
+			return fmt.Sprintf("length narrows from %d to %d", length, field.Size)
+		}
+	case familyFloat:
+		precision, scale, ok := column.DecimalSize()
+		if ok && (field.Precision > 0 && int64(field.Precision) < precision || field.Scale > 0 && int64(field.Scale) < scale) {
+			return fmt.Sprintf("precision narrows from (%d,%d) to (%d,%d)", precision, scale, field.Precision, field.Scale)
+		}
+	}
+	return ""
+}
+
+const (
+	familyInt    = "int"
+	familyFloat  = "float"
+	familyString = "string"
+	familyTime   = "time"
+	familyBytes  = "bytes"
+)
+
+// typeFamilies 数据库类型所属的类型族，布尔类型在 MySQL 中为 tinyint，归入整数
+var typeFamilies = map[string]string{
+	"tinyint": familyInt, "smallint": familyInt, "mediumint": familyInt, "int": familyInt, "integer": familyInt, "bigint": familyInt,
+	"int2": familyInt, "int4": familyInt, "int8": familyInt, "serial": familyInt, "smallserial": familyInt, "bigserial": familyInt,
+	"bool": familyInt, "boolean": familyInt, "bit": familyInt,
+	"float": familyFloat, "double": familyFloat, "real": familyFloat, "numeric": familyFloat, "decimal": familyFloat,
+	"float4": familyFloat, "float8": familyFloat,
+	"char": familyString, "varchar": familyString, "character": familyString, "nchar": familyString, "nvarchar": familyString,
+	"text": familyString, "tinytext": familyString, "mediumtext": familyString, "longtext": familyString, "citext": familyString,
+	"enum": familyString, "set": familyString, "uuid": familyString, "json": familyString, "jsonb": familyString,
+	"date": familyTime, "datetime": familyTime, "time": familyTime, "timetz": familyTime, "timestamp": familyTime, "timestamptz": familyTime,
+	"blob": familyBytes, "tinyblob": familyBytes, "mediumblob": familyBytes, "longblob": familyBytes,
+	"binary": familyBytes, "varbinary": familyBytes, "bytea": familyBytes,
+}
+
+var intBits = map[string]int{
+	"bool": 1, "boolean": 1, "bit": 1,
+	"tinyint": 8, "smallint": 16, "int2": 16, "smallserial": 16, "mediumint": 24,
+	"int": 32, "integer": 32, "int4": 32, "serial": 32,
+	"bigint": 64, "int8": 64, "bigserial": 64,
+}
+
+var unbounded = map[string]bool{
+	"text": true, "mediumtext": true, "longtext": true, "citext": true, "json": true, "jsonb": true,
+}
+
+// baseType 返回类型名的第一个单词，如 varchar(255) -> varchar，bigint unsigned -> bigint
+func baseType(typ string) string {
+	end := strings.IndexAny(typ, "( ")
+	if end < 0 {
+		return typ
+	}
+	return typ[:end]
+}
+
+func typeFamily(typ string) string {
+	return typeFamilies[baseType(typ)]
+}
+
+// recorder 记录写语句而不执行，查询照常执行以读取表结构；事务为空操作
+type recorder struct {
+	gorm.ConnPool
+	dialector  gorm.Dialector
+	statements []string
+}
+
+func (r *recorder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	// 嵌套事务生成的保存点不属于 DDL
+	upper := strings.ToUpper(strings.TrimSpace(query))
+	if strings.HasPrefix(upper, "SAVEPOINT") || strings.HasPrefix(upper, "RELEASE SAVEPOINT") || strings.HasPrefix(upper, "ROLLBACK TO SAVEPOINT") {
+		return driver.RowsAffected(0), nil
+	}
+	r.statements = append(r.statements, r.dialector.Explain(query, args...))
+	return driver.RowsAffected(0), nil
+}
+
+func (r *recorder) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return r, nil
+}
+
+func (r *recorder) Commit() error {
+	return nil
+}
+
+func (r *recorder) Rollback() error {
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2025 lixw
+*/
+package migrate_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/migrate"
+)
+
+type account struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"size:32"`
+	Bio  string `gorm:"size:255"`
+}
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	if err := db.Exec("CREATE TABLE `accounts` (`id` integer PRIMARY KEY, `name` varchar(64), `bio` text, `legacy` text)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := migrate.Diff(ctx, db, &account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Drift() || !plan.Destructive() {
+		t.Fatalf("drift = %v, destructive = %v, want both", plan.Drift(), plan.Destructive())
+	}
+	want := []string{
+		"accounts.name: length narrows from 64 to 32",
+		"accounts.legacy: column is not in the model and will be dropped",
+	}
+	if !slices.Equal(plan.Warnings, want) {
+		t.Errorf("warnings = %q, want %q", plan.Warnings, want)
+	}
+	// SQLite 通过重建表删除列
+	if !slices.ContainsFunc(plan.Statements, func(stmt string) bool {
+		return strings.HasPrefix(stmt, "CREATE TABLE `accounts__temp`") && !strings.Contains(stmt, "legacy")
+	}) {
+		t.Errorf("statements = %q, want accounts rebuilt without legacy", plan.Statements)
+	}
+	// Diff 只生成语句，不修改表结构
+	if !db.Migrator().HasColumn("accounts", "legacy") {
+		t.Error("column legacy is dropped by Diff")
+	}
+
+	if err := db.AutoMigrate(&account{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("ALTER TABLE accounts DROP COLUMN legacy").Error; err != nil {
+		t.Fatal(err)
+	}
+	plan, err = migrate.Diff(ctx, db, &account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Drift() {
+		t.Errorf("statements = %q, warnings = %q, want no drift", plan.Statements, plan.Warnings)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright © 2025 lixw
+*/
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// fileNameRegexp {version}_{name}.{up|down}[.{driver}].sql
+var fileNameRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)(?:\.(\w+))?\.sql$`)
+
+type sqlFile struct {
+	content string
+	// 带驱动后缀的文件优先
+	specific bool
+}
+
+type sqlMigration struct {
+	name     string
+	up, down *sqlFile
+}
+
+// load 读取目录下的 SQL 迁移，忽略不匹配命名规则的文件和其他驱动专用的文件
+func load(fsys fs.FS, dir, driver string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration dir: %w", err)
+	}
+	files := make(map[int64]*sqlMigration)
+	for _, entry := range entries {
+		match := fileNameRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		fileDriver := match[4]
+		if fileDriver != "" && fileDriver != driver {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+		buf, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sm, ok := files[version]
+		if !ok {
+			sm = &sqlMigration{name: match[2]}
+			files[version] = sm
+		} else if sm.name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, sm.name, match[2])
+		}
+		file := &sqlFile{content: string(buf), specific: fileDriver != ""}
+		target := &sm.up
+		if match[3] == "down" {
+			target = &sm.down
+		}
+		if *target == nil || file.specific {
+			*target = file
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(files))
+	for version, sm := range files {
+		if sm.up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up script", version, sm.name)
+		}
+		sum := sha256.Sum256([]byte(sm.up.content))
+		mg := &Migration{
+			Version:  version,
+			Name:     sm.name,
+			Up:       execSQL(sm.up.content),
+			Checksum: hex.EncodeToString(sum[:]),
+		}
+		if sm.down != nil {
+			mg.Down = execSQL(sm.down.content)
+		}
+		migrations = append(migrations, mg)
+	}
+	return migrations, nil
+}
+
+// execSQL 逐条执行脚本中的语句，不依赖驱动的多语句支持
+func execSQL(script string) Func {
+	return func(ctx context.Context, tx *gorm.DB) error {
+		for _, stmt := range splitStatements(script) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// splitStatements 按分号拆分语句，忽略字符串、引用标识符、注释和 PostgreSQL $tag$ 引用体中的分号
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(script) {
+				if script[end] == c {
+					// 连续两个引号为转义
+					if end+1 < len(script) && script[end+1] == c {
+						end += 2
+						continue
+					}
+					break
+				}
+				if script[end] == '\\' && c == '\'' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(script))
+			current.WriteString(script[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end
+				current.WriteByte('\n')
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+				current.WriteByte(' ')
+			}
+		case c == '$':
+			tag := dollarTag(script[i:])
+			if tag == "" {
+				current.WriteByte(c)
+				continue
+			}
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				current.WriteString(script[i:])
+				i = len(script)
+				continue
+			}
+			end = i + len(tag) + end + len(tag)
+			current.WriteString(script[i:end])
+			i = end - 1
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
+
+// dollarTag 返回 PostgreSQL 美元引用的起始标记，如 $$ 或 $body$
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2025 lixw
+*/
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/logging"
+	"gorm.io/gorm"
+)
+
+// Message 待投递的事件，与业务变更写在同一事务中，投递成功后删除
+type Message struct {
+	ID            uint      `json:"id" gorm:"primaryKey;autoIncrement;index:idx_outbox_aggregate,priority:3;comment:主键ID"`
+	Aggregate     string    `json:"aggregate" gorm:"column:aggregate;size:64;not null;index:idx_outbox_aggregate,priority:1;comment:聚合类型"`
+	AggregateID   string    `json:"aggregate_id" gorm:"column:aggregate_id;size:127;not null;index:idx_outbox_aggregate,priority:2;comment:聚合ID"`
+	Topic         string    `json:"topic" gorm:"column:topic;size:127;not null;comment:事件类型"`
+	Payload       string    `json:"payload" gorm:"column:payload;type:text;comment:事件内容(JSON)"`
+	TraceID       string    `json:"trace_id" gorm:"column:trace_id;size:64;comment:请求ID"`
+	TenantID      uint      `json:"tenant_id" gorm:"column:tenant_id;comment:租户ID"`
+	Attempts      int       `json:"attempts" gorm:"column:attempts;not null;default:0;comment:投递次数"`
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"column:next_attempt_at;not null;index;comment:下次投递时间"`
+	LastError     string    `json:"last_error" gorm:"column:last_error;size:1024;comment:最近一次投递错误"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime;comment:创建时间"`
+}
+
+func (*Message) TableName() string {
+	return "outbox"
+}
+
+// DeadLetter 超过最大投递次数的事件，可通过 Redrive 重新投递
+type DeadLetter struct {
+	ID          uint      `json:"id" gorm:"primaryKey;autoIncrement;comment:主键ID"`
+	MessageID   uint      `json:"message_id" gorm:"column:message_id;not null;comment:原事件ID"`
+	Aggregate   string    `json:"aggregate" gorm:"column:aggregate;size:64;not null;comment:聚合类型"`
+	AggregateID string    `json:"aggregate_id" gorm:"column:aggregate_id;size:127;not null;comment:聚合ID"`
+	Topic       string    `json:"topic" gorm:"column:topic;size:127;not null;index;comment:事件类型"`
+	Payload     string    `json:"payload" gorm:"column:payload;type:text;comment:事件内容(JSON)"`
+	TraceID     string    `json:"trace_id" gorm:"column:trace_id;size:64;comment:请求ID"`
+	TenantID    uint      `json:"tenant_id" gorm:"column:tenant_id;comment:租户ID"`
+	Attempts    int       `json:"attempts" gorm:"column:attempts;not null;comment:投递次数"`
+	LastError   string    `json:"last_error" gorm:"column:last_error;size:1024;comment:最后一次投递错误"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at;comment:事件创建时间"`
+	FailedAt    time.Time `json:"failed_at" gorm:"column:failed_at;not null;comment:转入死信时间"`
+}
+
+func (*DeadLetter) TableName() string {
+	return "outbox_dead_letter"
+}
+
+// Writer 写入待投递事件，ctx 中有事务（database.TxManager）时与业务变更一同提交或回滚
+type Writer struct {
+	db *gorm.DB
+}
+
+func NewWriter(db *gorm.DB) *Writer {
+	return &Writer{db: db}
+}
+
+// Add 写入一条事件，payload 序列化为 JSON；同一聚合（aggregate + aggregateID）的事件按写入顺序投递
+func (w *Writer) Add(ctx context.Context, aggregate, aggregateID, topic string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("outbox: failed to marshal %s payload: %w", topic, err)
+	}
+	msg := &Message{
+		Aggregate:     aggregate,
+		AggregateID:   aggregateID,
+		Topic:         topic,
+		Payload:       string(data),
+		NextAttemptAt: time.Now(),
+	}
+	msg.TraceID, _ = ctx.Value(logging.ContextKeyTraceID).(string)
+	msg.TenantID, _ = database.TenantFrom(ctx)
+	return database.Conn(ctx, w.db).Create(msg).Error
+}
+
+// Redrive 将死信重新放回待投递队列，ids 为空时重新投递全部死信，返回处理的数量
+func Redrive(ctx context.Context, db *gorm.DB, ids ...uint) (int, error) {
+	var count int
+	err := database.Conn(ctx, db).Transaction(func(tx *gorm.DB) error {
+		query := tx.Order("id")
+		if len(ids) > 0 {
+			query = query.Where("id IN ?", ids)
+		}
+		var letters []*DeadLetter
+		if err := query.Find(&letters).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, letter := range letters {
+			msg := &Message{
+				Aggregate:     letter.Aggregate,
+				AggregateID:   letter.AggregateID,
+				Topic:         letter.Topic,
+				Payload:       letter.Payload,
+				TraceID:       letter.TraceID,
+				TenantID:      letter.TenantID,
+				NextAttemptAt: now,
+			}
+			if err := tx.Create(msg).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(letter).Error; err != nil {
+				return err
+			}
+		}
+		count = len(letters)
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,360 @@
+/*
+Copyright © 2025 lixw
+*/
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/ethanli-dev/go-app-layout/pkg/logging"
+	"github.com/ethanli-dev/go-app-layout/pkg/safego"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	maxErrorLength = 1024
+	// tableRecheck 租户库缺少 outbox 表时重新检查的间隔
+	tableRecheck = time.Minute
+)
+
+// relayLease 投递租约，多实例部署时只有持有租约的实例投递，保证同一聚合的顺序
+type relayLease struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement:false"`
+	Owner     string    `gorm:"column:owner;size:255;not null"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+}
+
+func (*relayLease) TableName() string {
+	return "outbox_lease"
+}
+
+type Options struct {
+	sinks       []Sink
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	leaseTTL    time.Duration
+}
+
+type Option func(*Options)
+
+// WithSinks 投递目标，每条事件依次投递到全部目标，任一失败时整条事件重试
+func WithSinks(sinks ...Sink) Option {
+	return func(o *Options) {
+		o.sinks = append(o.sinks, sinks...)
+	}
+}
+
+// WithInterval 轮询间隔，默认 1 秒；写入事件后可调用 Relay.Notify 立即投递
+func WithInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		if interval > 0 {
+			o.interval = interval
+		}
+	}
+}
+
+// WithBatchSize 每次轮询读取的事件数，默认 100
+func WithBatchSize(batchSize int) Option {
+	return func(o *Options) {
+		if batchSize > 0 {
+			o.batchSize = batchSize
+		}
+	}
+}
+
+// WithMaxAttempts 最大投递次数，超过后转入死信表，默认 10
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *Options) {
+		if maxAttempts > 0 {
+			o.maxAttempts = maxAttempts
+		}
+	}
+}
+
+// WithBackoff 重试间隔从 backoff 开始按次数翻倍，不超过 maxBackoff，默认 1 秒到 10 分钟
+func WithBackoff(backoff, maxBackoff time.Duration) Option {
+	return func(o *Options) {
+		if backoff > 0 {
+			o.backoff = backoff
+		}
+		if maxBackoff > 0 {
+			o.maxBackoff = maxBackoff
+		}
+	}
+}
+
+// WithLeaseTTL 投递租约有效期，默认 30 秒，需大于轮询间隔和单轮投递耗时，否则其他实例可能接管并重复投递
+func WithLeaseTTL(leaseTTL time.Duration) Option {
+	return func(o *Options) {
+		if leaseTTL > 0 {
+			o.leaseTTL = leaseTTL
+		}
+	}
+}
+
+// Relay 事件投递服务（app.Service），轮询 outbox 表并投递到 Sink，至少投递一次：
+// 同一聚合的事件按写入顺序投递，前一条失败时后续事件等待其重试；成功后删除，超过最大次数转入死信表。
+// 启用租户路由时同时投递独立存储的租户库中的事件
+type Relay struct {
+	db     *gorm.DB
+	opts   *Options
+	owner  string
+	notify chan struct{}
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	// ready 已确认存在 outbox 表的租户库，missing 缺少表的租户库及下次检查时间
+	tablesMu sync.Mutex
+	ready    map[uint]bool
+	missing  map[uint]time.Time
+}
+
+func NewRelay(db *gorm.DB, options ...Option) *Relay {
+	opts := &Options{
+		interval:    time.Second,
+		batchSize:   100,
+		maxAttempts: 10,
+		backoff:     time.Second,
+		maxBackoff:  10 * time.Minute,
+		leaseTTL:    30 * time.Second,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	hostname, _ := os.Hostname()
+	return &Relay{
+		db:      db,
+		opts:    opts,
+		owner:   fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		notify:  make(chan struct{}, 1),
+		ready:   make(map[uint]bool),
+		missing: make(map[uint]time.Time),
+	}
+}
+
+// Notify 唤醒投递循环，不等待下一次轮询；应在事务提交后调用
+func (r *Relay) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return nil
+	}
+	loopCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	safego.Go(loopCtx, func(ctx context.Context) {
+		defer close(r.done)
+		ticker := time.NewTicker(r.opts.interval)
+		defer ticker.Stop()
+		for {
+			r.Run(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-r.notify:
+			}
+		}
+	})
+	slog.InfoContext(ctx, "outbox relay started", "owner", r.owner)
+	return nil
+}
+
+func (r *Relay) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	r.cancel = nil
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// 释放租约，其他实例无需等待过期即可接管
+	if err := r.db.WithContext(ctx).Where("id = ? AND owner = ?", 1, r.owner).Delete(&relayLease{}).Error; err != nil {
+		slog.WarnContext(ctx, "failed to release outbox lease", "err", err)
+	}
+	slog.InfoContext(ctx, "outbox relay stopped")
+	return nil
+}
+
+// Run 执行一轮投递，未持有租约时跳过；通常由 Start 启动的循环调用
+func (r *Relay) Run(ctx context.Context) {
+	ok, err := r.acquire(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to acquire outbox lease", "err", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	r.deliver(ctx)
+	tenants, err := database.RoutedTenants(ctx, r.db)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list tenant databases", "err", err)
+		return
+	}
+	for _, id := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		tenantCtx := database.WithTenant(ctx, id)
+		if r.hasTable(tenantCtx, id) {
+			r.deliver(tenantCtx)
+		}
+	}
+}
+
+// hasTable 检查租户库是否已创建 outbox 表，未执行 migrate up --all-tenants 的租户库跳过投递，
+// 记录一次警告后每隔 tableRecheck 重新检查
+func (r *Relay) hasTable(ctx context.Context, tenantID uint) bool {
+	r.tablesMu.Lock()
+	defer r.tablesMu.Unlock()
+	if r.ready[tenantID] {
+		return true
+	}
+	next, checked := r.missing[tenantID]
+	if checked && time.Now().Before(next) {
+		return false
+	}
+	if database.Conn(ctx, r.db).Migrator().HasTable(&Message{}) {
+		delete(r.missing, tenantID)
+		r.ready[tenantID] = true
+		return true
+	}
+	if !checked {
+		slog.WarnContext(ctx, "outbox table is missing in tenant database, run migrate up --all-tenants", "tenant", tenantID)
+	}
+	r.missing[tenantID] = time.Now().Add(tableRecheck)
+	return false
+}
+
+// acquire 获取或续期租约，租约记录在共享库
+func (r *Relay) acquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	db := r.db.WithContext(database.WithShared(ctx))
+	res := db.Model(&relayLease{}).Where("id = ? AND (owner = ? OR expires_at < ?)", 1, r.owner, now).
+		Updates(map[string]any{"owner": r.owner, "expires_at": now.Add(r.opts.leaseTTL)})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+	// 主键冲突说明租约已被其他实例持有，不记录冲突错误日志
+	quiet := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)})
+	return quiet.Create(&relayLease{ID: 1, Owner: r.owner, ExpiresAt: now.Add(r.opts.leaseTTL)}).Error == nil, nil
+}
+
+// deliver 投递 ctx 所在库中到期的事件；已有更早事件在等待重试的聚合不在本轮投递
+func (r *Relay) deliver(ctx context.Context) {
+	db := database.Conn(ctx, r.db)
+	now := time.Now()
+	var messages []*Message
+	err := db.Where("next_attempt_at <= ?", now).
+		Where("NOT EXISTS (SELECT 1 FROM outbox o WHERE o.aggregate = outbox.aggregate AND o.aggregate_id = outbox.aggregate_id AND o.id < outbox.id AND o.next_attempt_at > ?)", now).
+		Order("id").Limit(r.opts.batchSize).Find(&messages).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read outbox", "err", err)
+		return
+	}
+	blocked := make(map[string]bool)
+	for _, msg := range messages {
+		key := msg.Aggregate + ":" + msg.AggregateID
+		if blocked[key] || ctx.Err() != nil {
+			continue
+		}
+		if err := r.publish(ctx, msg); err != nil {
+			blocked[key] = true
+			r.fail(ctx, db, msg, err)
+			continue
+		}
+		if err := db.Delete(msg).Error; err != nil {
+			// 删除失败时事件会被再次投递
+			slog.ErrorContext(ctx, "failed to delete delivered outbox message", "id", msg.ID, "err", err)
+			blocked[key] = true
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, msg *Message) error {
+	if msg.TraceID != "" {
+		ctx = context.WithValue(ctx, logging.ContextKeyTraceID, msg.TraceID)
+	}
+	if msg.TenantID > 0 {
+		ctx = database.WithTenant(ctx, msg.TenantID)
+	}
+	for _, sink := range r.opts.sinks {
+		if err := sink.Publish(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail 记录失败并按退避时间安排重试，超过最大次数时转入死信表
+func (r *Relay) fail(ctx context.Context, db *gorm.DB, msg *Message, cause error) {
+	msg.Attempts++
+	msg.LastError = cause.Error()
+	if len(msg.LastError) > maxErrorLength {
+		msg.LastError = msg.LastError[:maxErrorLength]
+	}
+	if msg.Attempts >= r.opts.maxAttempts {
+		slog.ErrorContext(ctx, "outbox message moved to dead letter", "id", msg.ID, "topic", msg.Topic, "attempts", msg.Attempts, "err", cause)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&DeadLetter{
+				MessageID:   msg.ID,
+				Aggregate:   msg.Aggregate,
+				AggregateID: msg.AggregateID,
+				Topic:       msg.Topic,
+				Payload:     msg.Payload,
+				TraceID:     msg.TraceID,
+				TenantID:    msg.TenantID,
+				Attempts:    msg.Attempts,
+				LastError:   msg.LastError,
+				CreatedAt:   msg.CreatedAt,
+				FailedAt:    time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(msg).Error
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to move outbox message to dead letter", "id", msg.ID, "err", err)
+		}
+		return
+	}
+	backoff := r.opts.backoff << min(msg.Attempts-1, 30)
+	if backoff <= 0 || backoff > r.opts.maxBackoff {
+		backoff = r.opts.maxBackoff
+	}
+	slog.WarnContext(ctx, "failed to publish outbox message", "id", msg.ID, "topic", msg.Topic, "attempts", msg.Attempts, "retryIn", backoff, "err", cause)
+	err := db.Model(msg).Updates(map[string]any{
+		"attempts":        msg.Attempts,
+		"last_error":      msg.LastError,
+		"next_attempt_at": time.Now().Add(backoff),
+	}).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update outbox message", "id", msg.ID, "err", err)
+	}
+}
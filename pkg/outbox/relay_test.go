@@ -0,0 +1,190 @@
+/*
+Copyright © 2025 lixw
+*/
+package outbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"gorm.io/gorm"
+)
+
+var errUnavailable = errors.New("sink unavailable")
+
+// recordSink 记录投递成功的事件（aggregateID/topic），failing 返回 true 的事件投递失败
+type recordSink struct {
+	mu        sync.Mutex
+	delivered []string
+	failing   func(msg *Message) bool
+}
+
+func (s *recordSink) Publish(ctx context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing != nil && s.failing(msg) {
+		return errUnavailable
+	}
+	s.delivered = append(s.delivered, msg.AggregateID+"/"+msg.Topic)
+	return nil
+}
+
+// take 返回并清空已投递的事件
+func (s *recordSink) take() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivered := s.delivered
+	s.delivered = nil
+	return delivered
+}
+
+func newDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.New(database.WithUrl("sqlite://" + filepath.Join(t.TempDir(), "app.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&Message{}, &DeadLetter{}, &relayLease{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func add(t *testing.T, db *gorm.DB, aggregateID, topic string) {
+	t.Helper()
+	if err := NewWriter(db).Add(context.Background(), "order", aggregateID, topic, map[string]string{"id": aggregateID}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pending(t *testing.T, db *gorm.DB) []*Message {
+	t.Helper()
+	var messages []*Message
+	if err := db.Order("id").Find(&messages).Error; err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestRelayOrder(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	add(t, db, "1", "created")
+	add(t, db, "1", "paid")
+	add(t, db, "2", "created")
+	sink := &recordSink{failing: func(msg *Message) bool { return msg.AggregateID == "1" && msg.Topic == "created" }}
+	relay := NewRelay(db, WithSinks(sink), WithBackoff(time.Hour, time.Hour))
+
+	// 订单 1 的第一条事件失败，后续事件等待其重试，不影响订单 2
+	relay.Run(ctx)
+	if got := sink.take(); !slices.Equal(got, []string{"2/created"}) {
+		t.Fatalf("delivered = %v, want [2/created]", got)
+	}
+	messages := pending(t, db)
+	if len(messages) != 2 || messages[0].Attempts != 1 || messages[0].LastError != errUnavailable.Error() || messages[1].Attempts != 0 {
+		t.Fatalf("pending = %+v, want 1/created failed once and 1/paid untouched", messages)
+	}
+	relay.Run(ctx)
+	if got := sink.take(); len(got) > 0 {
+		t.Fatalf("delivered = %v, want none before the retry is due", got)
+	}
+
+	// 重试到期且投递成功后按写入顺序投递
+	sink.failing = nil
+	if err := db.Model(messages[0]).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	relay.Run(ctx)
+	if got := sink.take(); !slices.Equal(got, []string{"1/created", "1/paid"}) {
+		t.Fatalf("delivered = %v, want [1/created 1/paid]", got)
+	}
+	if messages := pending(t, db); len(messages) > 0 {
+		t.Errorf("pending = %d, want 0", len(messages))
+	}
+}
+
+func TestRelayDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	add(t, db, "1", "created")
+	sink := &recordSink{failing: func(msg *Message) bool { return true }}
+	relay := NewRelay(db, WithSinks(sink), WithMaxAttempts(3), WithBackoff(time.Nanosecond, time.Nanosecond))
+
+	for i := 1; i <= 3; i++ {
+		relay.Run(ctx)
+		messages := pending(t, db)
+		if i < 3 && (len(messages) != 1 || messages[0].Attempts != i) {
+			t.Fatalf("run %d: pending = %+v, want 1 message with %d attempts", i, messages, i)
+		}
+		if i == 3 && len(messages) > 0 {
+			t.Fatalf("run %d: pending = %+v, want none", i, messages)
+		}
+	}
+	var letters []*DeadLetter
+	if err := db.Find(&letters).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].Attempts != 3 || letters[0].Topic != "created" || letters[0].LastError != errUnavailable.Error() {
+		t.Fatalf("dead letters = %+v, want 1/created after 3 attempts", letters)
+	}
+
+	// 重新投递后恢复为待投递事件
+	count, err := Redrive(ctx, db)
+	if err != nil || count != 1 {
+		t.Fatalf("redrive = %d, %v, want 1", count, err)
+	}
+	sink.failing = nil
+	relay.Run(ctx)
+	if got := sink.take(); !slices.Equal(got, []string{"1/created"}) {
+		t.Errorf("delivered = %v, want [1/created]", got)
+	}
+}
+
+func TestRelayLease(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	holder, other := &recordSink{}, &recordSink{}
+	relay := NewRelay(db, WithSinks(holder))
+	standby := NewRelay(db, WithSinks(other))
+	standby.owner = "standby"
+
+	relay.Run(ctx)
+	add(t, db, "1", "created")
+	// 租约由 relay 持有，standby 不投递
+	standby.Run(ctx)
+	if got := other.take(); len(got) > 0 {
+		t.Fatalf("standby delivered %v, want none", got)
+	}
+	if messages := pending(t, db); len(messages) != 1 || messages[0].Attempts != 0 {
+		t.Fatalf("pending = %+v, want 1 untouched message", messages)
+	}
+	relay.Run(ctx)
+	if got := holder.take(); !slices.Equal(got, []string{"1/created"}) {
+		t.Errorf("delivered = %v, want [1/created]", got)
+	}
+
+	// 租约过期后 standby 接管
+	if err := db.Model(&relayLease{}).Where("id = ?", 1).Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	add(t, db, "2", "created")
+	standby.Run(ctx)
+	if got := other.take(); !slices.Equal(got, []string{"2/created"}) {
+		t.Errorf("standby delivered %v, want [2/created]", got)
+	}
+	relay.Run(ctx)
+	if got := holder.take(); len(got) > 0 {
+		t.Errorf("relay delivered %v after losing the lease, want none", got)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright © 2025 lixw
+*/
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/signature"
+)
+
+// HeaderKeyMessageID 事件ID，投递至少一次，消费方可据此去重；独立存储的租户库各自编号，需结合 tenant_id
+const HeaderKeyMessageID = "X-Outbox-Id"
+
+// Sink 事件的投递目标，返回错误时事件稍后重试
+type Sink interface {
+	Publish(ctx context.Context, msg *Message) error
+}
+
+type SinkFunc func(ctx context.Context, msg *Message) error
+
+func (f SinkFunc) Publish(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// Envelope 投递到进程外的事件格式
+type Envelope struct {
+	ID          uint            `json:"id"`
+	Topic       string          `json:"topic"`
+	Aggregate   string          `json:"aggregate"`
+	AggregateID string          `json:"aggregate_id"`
+	TenantID    uint            `json:"tenant_id,omitempty"`
+	TraceID     string          `json:"trace_id,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func envelope(msg *Message) ([]byte, error) {
+	payload := json.RawMessage(msg.Payload)
+	if len(payload) == 0 {
+		payload = json.RawMessage("null")
+	}
+	return json.Marshal(&Envelope{
+		ID:          msg.ID,
+		Topic:       msg.Topic,
+		Aggregate:   msg.Aggregate,
+		AggregateID: msg.AggregateID,
+		TenantID:    msg.TenantID,
+		TraceID:     msg.TraceID,
+		Payload:     payload,
+		CreatedAt:   msg.CreatedAt,
+	})
+}
+
+// Bus 进程内事件总线，处理器同步执行；任一处理器失败时整条事件重试，处理器需要幂等
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]SinkFunc
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]SinkFunc)}
+}
+
+// Subscribe 订阅事件类型，topic 为 * 时订阅全部事件
+func (b *Bus) Subscribe(topic string, handler SinkFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[topic] = append(b.handlers[topic], handler)
+}
+
+func (b *Bus) Publish(ctx context.Context, msg *Message) error {
+	b.mu.RLock()
+	handlers := append(slices.Clone(b.handlers[msg.Topic]), b.handlers["*"]...)
+	b.mu.RUnlock()
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Webhook 以 POST JSON（Envelope）投递事件，非 2xx 响应视为失败
+type Webhook struct {
+	url    string
+	topics []string
+	client *http.Client
+}
+
+type WebhookOption func(*Webhook)
+
+// WithWebhookTopics 只投递指定类型的事件，默认投递全部
+func WithWebhookTopics(topics ...string) WebhookOption {
+	return func(w *Webhook) {
+		w.topics = topics
+	}
+}
+
+// WithWebhookSigner 使用 HMAC-SHA256 为请求签名，接收方可使用 signature.Verifier 校验
+func WithWebhookSigner(keyID string, secret []byte) WebhookOption {
+	return func(w *Webhook) {
+		w.client.Transport = &signature.Transport{Base: w.client.Transport, Signer: signature.NewSigner(keyID, secret)}
+	}
+}
+
+func WithWebhookTimeout(timeout time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		if timeout > 0 {
+			w.client.Timeout = timeout
+		}
+	}
+}
+
+func NewWebhook(url string, options ...WebhookOption) *Webhook {
+	w := &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, option := range options {
+		option(w)
+	}
+	return w
+}
+
+func (w *Webhook) Publish(ctx context.Context, msg *Message) error {
+	if len(w.topics) > 0 && !slices.Contains(w.topics, msg.Topic) {
+		return nil
+	}
+	body, err := envelope(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderKeyMessageID, strconv.FormatUint(uint64(msg.ID), 10))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded %d", w.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// Producer 消息中间件（Kafka、RabbitMQ、NATS 等）客户端的适配接口，
+// key 为聚合标识，按 key 分区可保持同一聚合的顺序
+type Producer interface {
+	Produce(ctx context.Context, topic, key string, value []byte, headers map[string]string) error
+}
+
+// Broker 将事件以 Envelope 格式发送到消息中间件，主题与事件类型相同
+type Broker struct {
+	producer Producer
+}
+
+func NewBroker(producer Producer) *Broker {
+	return &Broker{producer: producer}
+}
+
+func (b *Broker) Publish(ctx context.Context, msg *Message) error {
+	value, err := envelope(msg)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{HeaderKeyMessageID: strconv.FormatUint(uint64(msg.ID), 10)}
+	return b.producer.Produce(ctx, msg.Topic, msg.Aggregate+":"+msg.AggregateID, value, headers)
+}
@@ -0,0 +1,319 @@
+/*
+Copyright © 2025 lixw
+*/
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/database"
+	"github.com/goccy/go-yaml"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// refKey 记录中声明引用名的字段，其他记录通过 $name 引用该记录的主键，$name.column 引用指定列
+const refKey = "$ref"
+
+// Fixture 单个表的夹具，文件内容为 Fixture 列表（YAML 或 JSON）：
+//
+//   - table: tenant
+//     key: [name]
+//     records:
+//   - $ref: acme
+//     name: Acme
+//   - table: file
+//     key: [storage_key]
+//     records:
+//   - tenant_id: $acme
+//     storage_key: demo/acme/readme.txt
+type Fixture struct {
+	Table string `json:"table" yaml:"table"`
+	// Key 用于判断记录是否已存在的自然键列，为空时使用主键
+	Key     []string         `json:"key" yaml:"key"`
+	Records []map[string]any `json:"records" yaml:"records"`
+}
+
+// Result 单个表的加载结果
+type Result struct {
+	Table   string
+	Created int
+	Updated int
+}
+
+type Options struct {
+	models   []any
+	truncate bool
+}
+
+type Option func(*Options)
+
+// WithModels 注册夹具可写入的模型，按表名匹配夹具
+func WithModels(models ...any) Option {
+	return func(o *Options) {
+		o.models = append(o.models, models...)
+	}
+}
+
+// WithTruncate 加载前按夹具中表出现顺序的倒序清空表（含软删除的记录），用于测试
+func WithTruncate() Option {
+	return func(o *Options) {
+		o.truncate = true
+	}
+}
+
+type Seeder struct {
+	db       *gorm.DB
+	models   map[string]any
+	truncate bool
+	refs     map[string]*record
+}
+
+type record struct {
+	schema *schema.Schema
+	value  reflect.Value
+}
+
+func New(db *gorm.DB, options ...Option) (*Seeder, error) {
+	opts := &Options{}
+	for _, option := range options {
+		option(opts)
+	}
+	models := make(map[string]any, len(opts.models))
+	for _, model := range opts.models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		models[stmt.Schema.Table] = model
+	}
+	return &Seeder{
+		db:       db,
+		models:   models,
+		truncate: opts.truncate,
+		refs:     make(map[string]*record),
+	}, nil
+}
+
+// Load 在一个事务中按文件名顺序加载目录下的 .yml、.yaml、.json 夹具，引用可跨文件；
+// 记录按自然键存在时更新夹具中给出的列（恢复软删除），否则创建，重复执行结果一致
+func (s *Seeder) Load(ctx context.Context, fsys fs.FS, dir string) ([]Result, error) {
+	fixtures, err := read(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	refs := make(map[string]*record)
+	// 夹具显式给出租户ID，跨租户写入
+	err = s.db.WithContext(database.SkipTenant(database.WithPrimary(ctx))).Transaction(func(tx *gorm.DB) error {
+		if s.truncate {
+			if err := s.truncateTables(tx, fixtures); err != nil {
+				return err
+			}
+		}
+		for _, fixture := range fixtures {
+			result, err := s.load(ctx, tx, fixture, refs)
+			if err != nil {
+				return fmt.Errorf("failed to load fixture %s: %w", fixture.Table, err)
+			}
+			i := slices.IndexFunc(results, func(r Result) bool { return r.Table == result.Table })
+			if i < 0 {
+				results = append(results, result)
+				continue
+			}
+			results[i].Created += result.Created
+			results[i].Updated += result.Updated
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name, ref := range refs {
+		s.refs[name] = ref
+	}
+	return results, nil
+}
+
+// Ref 返回已加载的引用记录（模型指针），不存在时返回 nil
+func (s *Seeder) Ref(name string) any {
+	ref, ok := s.refs[name]
+	if !ok {
+		return nil
+	}
+	return ref.value.Interface()
+}
+
+func (s *Seeder) truncateTables(tx *gorm.DB, fixtures []Fixture) error {
+	var tables []string
+	for _, fixture := range fixtures {
+		if !slices.Contains(tables, fixture.Table) {
+			tables = append(tables, fixture.Table)
+		}
+	}
+	for _, table := range slices.Backward(tables) {
+		model, ok := s.models[table]
+		if !ok {
+			return fmt.Errorf("unknown fixture table: %s", table)
+		}
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(model).Error; err != nil {
+			return fmt.Errorf("failed to truncate %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func (s *Seeder) load(ctx context.Context, tx *gorm.DB, fixture Fixture, refs map[string]*record) (Result, error) {
+	result := Result{Table: fixture.Table}
+	model, ok := s.models[fixture.Table]
+	if !ok {
+		return result, fmt.Errorf("unknown fixture table: %s", fixture.Table)
+	}
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return result, err
+	}
+	sch := stmt.Schema
+	keys := fixture.Key
+	if len(keys) == 0 && sch.PrioritizedPrimaryField != nil {
+		keys = []string{sch.PrioritizedPrimaryField.DBName}
+	}
+	if len(keys) == 0 {
+		return result, errors.New("fixture has no key")
+	}
+
+	for i, values := range fixture.Records {
+		value := reflect.New(sch.ModelType)
+		var columns []string
+		for column, raw := range values {
+			if column == refKey {
+				continue
+			}
+			field := sch.LookUpField(column)
+			if field == nil {
+				return result, fmt.Errorf("record %d: unknown column %s", i, column)
+			}
+			resolved, err := resolve(ctx, raw, refs)
+			if err != nil {
+				return result, fmt.Errorf("record %d: %w", i, err)
+			}
+			if err := field.Set(ctx, value.Elem(), resolved); err != nil {
+				return result, fmt.Errorf("record %d: invalid value for %s: %w", i, column, err)
+			}
+			columns = append(columns, field.DBName)
+		}
+
+		where := make(map[string]any, len(keys))
+		for _, key := range keys {
+			field := sch.LookUpField(key)
+			if field == nil || !slices.Contains(columns, field.DBName) {
+				return result, fmt.Errorf("record %d: missing key column %s", i, key)
+			}
+			where[field.DBName], _ = field.ValueOf(ctx, value.Elem())
+		}
+
+		existing := reflect.New(sch.ModelType)
+		found := tx.Unscoped().Where(where).Limit(1).Find(existing.Interface())
+		switch err := found.Error; {
+		case err == nil && found.RowsAffected == 0:
+			// 只写入给出的列，零值（如 status: 0）不被列默认值覆盖
+			if err := tx.Select(columns).Create(value.Interface()).Error; err != nil {
+				return result, fmt.Errorf("record %d: %w", i, err)
+			}
+			result.Created++
+		case err != nil:
+			return result, fmt.Errorf("record %d: %w", i, err)
+		default:
+			for _, field := range sch.PrimaryFields {
+				pk, _ := field.ValueOf(ctx, existing.Elem())
+				if err := field.Set(ctx, value.Elem(), pk); err != nil {
+					return result, err
+				}
+			}
+			// 恢复软删除的记录
+			if field := sch.LookUpField("DeletedAt"); field != nil && field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+				columns = append(columns, field.DBName)
+			}
+			if err := tx.Unscoped().Model(value.Interface()).Select(columns).Updates(value.Interface()).Error; err != nil {
+				return result, fmt.Errorf("record %d: %w", i, err)
+			}
+			result.Updated++
+		}
+
+		name, _ := values[refKey].(string)
+		if name == "" {
+			continue
+		}
+		if _, ok := refs[name]; ok {
+			return result, fmt.Errorf("record %d: duplicate reference %s", i, name)
+		}
+		// 重新读取以获得数据库生成的主键和默认值
+		fresh := reflect.New(sch.ModelType)
+		if err := tx.Unscoped().Where(where).Take(fresh.Interface()).Error; err != nil {
+			return result, fmt.Errorf("record %d: %w", i, err)
+		}
+		refs[name] = &record{schema: sch, value: fresh}
+	}
+	return result, nil
+}
+
+// resolve 将 $name、$name.column 替换为引用记录的主键或列值，$$ 开头的字符串转义为 $
+func resolve(ctx context.Context, raw any, refs map[string]*record) (any, error) {
+	str, ok := raw.(string)
+	if !ok || !strings.HasPrefix(str, "$") {
+		return raw, nil
+	}
+	if strings.HasPrefix(str, "$$") {
+		return str[1:], nil
+	}
+	name, column, _ := strings.Cut(str[1:], ".")
+	ref, ok := refs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown reference %s", str)
+	}
+	field := ref.schema.PrioritizedPrimaryField
+	if column != "" {
+		field = ref.schema.LookUpField(column)
+	}
+	if field == nil {
+		return nil, fmt.Errorf("invalid reference %s", str)
+	}
+	value, _ := field.ValueOf(ctx, ref.value.Elem())
+	return value, nil
+}
+
+// read 按文件名顺序读取目录下的夹具文件，JSON 作为 YAML 的子集解析
+func read(fsys fs.FS, dir string) ([]Fixture, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture dir: %w", err)
+	}
+	var fixtures []Fixture
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch path.Ext(entry.Name()) {
+		case ".yml", ".yaml", ".json":
+		default:
+			continue
+		}
+		buf, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var items []Fixture
+		if err := yaml.Unmarshal(buf, &items); err != nil {
+			return nil, fmt.Errorf("invalid fixture file %s: %w", entry.Name(), err)
+		}
+		fixtures = append(fixtures, items...)
+	}
+	return fixtures, nil
+}
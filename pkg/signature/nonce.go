@@ -0,0 +1,49 @@
+/*
+Copyright © 2025 lixw
+*/
+package signature
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NonceStore 记录已使用的 nonce，用于防重放；多实例部署时应使用共享存储实现
+type NonceStore interface {
+	// Seen 记录 nonce 并返回其在 ttl 内是否已被使用
+	Seen(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces:    make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryNonceStore) Seen(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	// 每个 ttl 周期清理一次过期 nonce
+	if now.Sub(s.lastSweep) > ttl {
+		for k, expireAt := range s.nonces {
+			if now.After(expireAt) {
+				delete(s.nonces, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	if expireAt, ok := s.nonces[nonce]; ok && now.Before(expireAt) {
+		return true, nil
+	}
+	s.nonces[nonce] = now.Add(ttl)
+	return false, nil
+}
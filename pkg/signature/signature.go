@@ -0,0 +1,272 @@
+/*
+Copyright © 2025 lixw
+*/
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderKeyKeyID     = "X-Signature-Key"
+	HeaderKeyTimestamp = "X-Signature-Timestamp"
+	HeaderKeyNonce     = "X-Signature-Nonce"
+	HeaderKeySignature = "X-Signature"
+	// HeaderKeyContentSHA256 客户端预先计算的请求体哈希，设置后服务端流式校验请求体，适用于大文件上传
+	HeaderKeyContentSHA256 = "X-Content-Sha256"
+)
+
+var (
+	ErrMissingHeaders   = errors.New("missing signature headers")
+	ErrInvalidTimestamp = errors.New("invalid signature timestamp")
+	ErrExpired          = errors.New("signature timestamp outside allowed clock skew")
+	ErrReplayed         = errors.New("signature nonce already used")
+	ErrUnknownKey       = errors.New("unknown signature key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrBodyHashMismatch = errors.New("request body does not match signed hash")
+)
+
+// SecretProvider 根据 keyID（如租户ID）返回签名密钥
+type SecretProvider func(ctx context.Context, keyID string) ([]byte, error)
+
+// CanonicalRequest 构造规范请求串：
+//
+//	METHOD\nPATH\nSORTED_QUERY\nTIMESTAMP\nNONCE\nHEX(SHA256(BODY))
+func CanonicalRequest(method, path, rawQuery, timestamp, nonce, bodyHash string) string {
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		canonicalQuery(rawQuery),
+		timestamp,
+		nonce,
+		bodyHash,
+	}, "\n")
+}
+
+// canonicalQuery 按参数名、参数值排序并重新编码查询参数
+func canonicalQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func HashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign 使用 HMAC-SHA256 对规范请求串签名，返回十六进制编码的签名
+func Sign(secret []byte, canonical string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Signer 客户端签名器，为请求添加签名相关请求头
+type Signer struct {
+	keyID  string
+	secret []byte
+	now    func() time.Time
+}
+
+func NewSigner(keyID string, secret []byte) *Signer {
+	return &Signer{keyID: keyID, secret: secret, now: time.Now}
+}
+
+// Sign 为请求签名，读取请求体后会重置，不影响后续发送；
+// 请求已设置 X-Content-Sha256 时直接使用该哈希，不读取请求体
+func (s *Signer) Sign(req *http.Request) error {
+	bodyHash := req.Header.Get(HeaderKeyContentSHA256)
+	if bodyHash == "" {
+		var body []byte
+		if req.Body != nil && req.Body != http.NoBody {
+			var err error
+			body, err = io.ReadAll(req.Body)
+			if err != nil {
+				return fmt.Errorf("read request body: %w", err)
+			}
+			_ = req.Body.Close()
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body)), nil
+			}
+		}
+		bodyHash = HashBody(body)
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	nonceStr := hex.EncodeToString(nonce)
+	canonical := CanonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, timestamp, nonceStr, bodyHash)
+	req.Header.Set(HeaderKeyKeyID, s.keyID)
+	req.Header.Set(HeaderKeyTimestamp, timestamp)
+	req.Header.Set(HeaderKeyNonce, nonceStr)
+	req.Header.Set(HeaderKeySignature, Sign(s.secret, canonical))
+	return nil
+}
+
+// Transport 自动为请求签名的 http.RoundTripper
+type Transport struct {
+	Base   http.RoundTripper
+	Signer *Signer
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper 不应修改原请求
+	clone := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody && req.Header.Get(HeaderKeyContentSHA256) == "" {
+		if req.GetBody == nil {
+			return nil, errors.New("signature: request body is not rewindable, set GetBody")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	if err := t.Signer.Sign(clone); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(clone)
+}
+
+type Options struct {
+	clockSkew  time.Duration
+	nonceStore NonceStore
+}
+
+type Option func(*Options)
+
+// WithClockSkew 设置允许的客户端时钟偏差，同时决定 nonce 的保留时长
+func WithClockSkew(clockSkew time.Duration) Option {
+	return func(o *Options) {
+		o.clockSkew = clockSkew
+	}
+}
+
+func WithNonceStore(nonceStore NonceStore) Option {
+	return func(o *Options) {
+		o.nonceStore = nonceStore
+	}
+}
+
+// Verifier 服务端签名校验器
+type Verifier struct {
+	provider   SecretProvider
+	clockSkew  time.Duration
+	nonceStore NonceStore
+	now        func() time.Time
+}
+
+func NewVerifier(provider SecretProvider, options ...Option) *Verifier {
+	opts := &Options{
+		clockSkew: 5 * time.Minute,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	if opts.nonceStore == nil {
+		opts.nonceStore = NewMemoryNonceStore()
+	}
+	return &Verifier{
+		provider:   provider,
+		clockSkew:  opts.clockSkew,
+		nonceStore: opts.nonceStore,
+		now:        time.Now,
+	}
+}
+
+// Verify 校验请求签名，bodyHash 为请求体的十六进制 SHA256，成功时返回签名的 keyID
+func (v *Verifier) Verify(ctx context.Context, req *http.Request, bodyHash string) (string, error) {
+	keyID := req.Header.Get(HeaderKeyKeyID)
+	timestamp := req.Header.Get(HeaderKeyTimestamp)
+	nonce := req.Header.Get(HeaderKeyNonce)
+	sig := req.Header.Get(HeaderKeySignature)
+	if keyID == "" || timestamp == "" || nonce == "" || sig == "" {
+		return "", ErrMissingHeaders
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidTimestamp
+	}
+	if diff := v.now().Sub(time.Unix(ts, 0)); diff > v.clockSkew || diff < -v.clockSkew {
+		return "", ErrExpired
+	}
+	secret, err := v.provider(ctx, keyID)
+	if err != nil {
+		return "", errors.Join(ErrUnknownKey, err)
+	}
+	canonical := CanonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, timestamp, nonce, bodyHash)
+	if !hmac.Equal([]byte(Sign(secret, canonical)), []byte(strings.ToLower(sig))) {
+		return "", ErrInvalidSignature
+	}
+	// 签名通过后再记录 nonce，避免伪造请求占用 nonce
+	seen, err := v.nonceStore.Seen(ctx, keyID+":"+nonce, 2*v.clockSkew)
+	if err != nil {
+		return "", err
+	}
+	if seen {
+		return "", ErrReplayed
+	}
+	return keyID, nil
+}
+
+// hashVerifyingReader 读取时计算请求体哈希，读到末尾时与签名中的哈希比对
+type hashVerifyingReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+// VerifyBody 包装请求体，读完后哈希与 expected 不一致时返回 ErrBodyHashMismatch
+func VerifyBody(body io.ReadCloser, expected string) io.ReadCloser {
+	return &hashVerifyingReader{ReadCloser: body, hash: sha256.New(), expected: strings.ToLower(expected)}
+}
+
+func (r *hashVerifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(r.hash.Sum(nil)) != r.expected {
+		return n, ErrBodyHashMismatch
+	}
+	return n, err
+}
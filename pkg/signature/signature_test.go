@@ -0,0 +1,135 @@
+/*
+Copyright © 2025 lixw
+*/
+package signature
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+func provider(_ context.Context, keyID string) ([]byte, error) {
+	if keyID != "1" {
+		return nil, errors.New("not found")
+	}
+	return secret, nil
+}
+
+// signed 返回签名后的请求及其请求体哈希
+func signed(t *testing.T, method, target, body string) (*http.Request, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if err := NewSigner("1", secret).Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	read, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(read) != body {
+		t.Fatalf("body after signing = %q, want %q", read, body)
+	}
+	return req, HashBody(read)
+}
+
+func TestVerify(t *testing.T) {
+	req, bodyHash := signed(t, http.MethodPost, "/v1/files?b=2&a=1&a=0", `{"name":"a.txt"}`)
+	keyID, err := NewVerifier(provider).Verify(context.Background(), req, bodyHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "1" {
+		t.Fatalf("keyID = %q, want 1", keyID)
+	}
+}
+
+func TestVerifyQueryOrder(t *testing.T) {
+	req, bodyHash := signed(t, http.MethodGet, "/v1/files?b=2&a=1", "")
+	req.URL.RawQuery = "a=1&b=2"
+	if _, err := NewVerifier(provider).Verify(context.Background(), req, bodyHash); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(req *http.Request, bodyHash *string)
+		want   error
+	}{
+		{"missing headers", func(req *http.Request, _ *string) { req.Header.Del(HeaderKeySignature) }, ErrMissingHeaders},
+		{"invalid timestamp", func(req *http.Request, _ *string) { req.Header.Set(HeaderKeyTimestamp, "now") }, ErrInvalidTimestamp},
+		{"unknown key", func(req *http.Request, _ *string) { req.Header.Set(HeaderKeyKeyID, "2") }, ErrUnknownKey},
+		{"tampered path", func(req *http.Request, _ *string) { req.URL.Path = "/v1/files/2" }, ErrInvalidSignature},
+		{"tampered query", func(req *http.Request, _ *string) { req.URL.RawQuery = "id=2" }, ErrInvalidSignature},
+		{"tampered body", func(_ *http.Request, bodyHash *string) { *bodyHash = HashBody([]byte("{}")) }, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, bodyHash := signed(t, http.MethodPost, "/v1/files/1?id=1", `{"name":"a.txt"}`)
+			tt.modify(req, &bodyHash)
+			if _, err := NewVerifier(provider).Verify(context.Background(), req, bodyHash); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	req, bodyHash := signed(t, http.MethodGet, "/v1/tenant/info", "")
+	v := NewVerifier(provider, WithClockSkew(time.Minute))
+	v.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := v.Verify(context.Background(), req, bodyHash); !errors.Is(err, ErrExpired) {
+		t.Fatalf("err = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestVerifyReplayed(t *testing.T) {
+	req, bodyHash := signed(t, http.MethodGet, "/v1/tenant/info", "")
+	v := NewVerifier(provider)
+	if _, err := v.Verify(context.Background(), req, bodyHash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(context.Background(), req, bodyHash); !errors.Is(err, ErrReplayed) {
+		t.Fatalf("err = %v, want %v", err, ErrReplayed)
+	}
+}
+
+func TestVerifyBody(t *testing.T) {
+	body := `{"name":"a.txt"}`
+	if _, err := io.ReadAll(VerifyBody(io.NopCloser(strings.NewReader(body)), HashBody([]byte(body)))); err != nil {
+		t.Fatal(err)
+	}
+	_, err := io.ReadAll(VerifyBody(io.NopCloser(strings.NewReader(body)), HashBody([]byte("{}"))))
+	if !errors.Is(err, ErrBodyHashMismatch) {
+		t.Fatalf("err = %v, want %v", err, ErrBodyHashMismatch)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	v := NewVerifier(provider)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if _, err := v.Verify(r.Context(), r, HashBody(body)); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: &Transport{Signer: NewSigner("1", secret)}}
+	resp, err := client.Post(srv.URL+"/v1/files?a=1", "application/json", strings.NewReader(`{"name":"a.txt"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+}
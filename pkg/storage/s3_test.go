@@ -0,0 +1,124 @@
+/*
+Copyright © 2025 lixw
+*/
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/storage"
+	"github.com/ethanli-dev/go-app-layout/pkg/storage/s3test"
+)
+
+func newS3(t *testing.T, srv *s3test.Server, pathStyle bool, secretKey string) storage.Storage {
+	t.Helper()
+	store, err := storage.New(
+		storage.WithDriver(storage.DriverS3),
+		storage.WithS3(srv.URL(), "us-east-1", "files", "access", secretKey),
+		storage.WithS3PathStyle(pathStyle),
+		storage.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestS3(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		pathStyle bool
+	}{
+		{"path style", true},
+		{"virtual hosted", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			srv := s3test.New(t, "files", "access", "secret")
+			store := newS3(t, srv, tt.pathStyle, "secret")
+			if err := store.Ping(ctx); err != nil {
+				t.Fatal(err)
+			}
+			// 键中包含需要编码的字符，签名须使用编码后的路径
+			key := "1/2025/a b+c=ü.txt"
+			content := "hello s3"
+			if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+				t.Fatal(err)
+			}
+			if obj := srv.Object(key); obj == nil || string(obj.Data) != content || obj.ContentType != "text/plain" {
+				t.Fatalf("stored object = %+v", obj)
+			}
+			info, err := store.Stat(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size != int64(len(content)) || info.ContentType != "text/plain" || info.ModTime.IsZero() {
+				t.Fatalf("stat = %+v", info)
+			}
+			rc, _, err := store.Get(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(rc)
+			_ = rc.Close()
+			if string(data) != content {
+				t.Fatalf("content = %q, want %q", data, content)
+			}
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := store.Get(ctx, key); !errors.Is(err, storage.ErrObjectNotFound) {
+				t.Fatalf("get deleted object err = %v, want %v", err, storage.ErrObjectNotFound)
+			}
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatalf("delete missing object: %v", err)
+			}
+		})
+	}
+}
+
+func TestS3EmptyObject(t *testing.T) {
+	srv := s3test.New(t, "files", "access", "secret")
+	store := newS3(t, srv, true, "secret")
+	if err := store.Put(context.Background(), "empty", strings.NewReader(""), 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if obj := srv.Object("empty"); obj == nil || len(obj.Data) != 0 {
+		t.Fatalf("stored object = %+v", obj)
+	}
+	if err := store.Put(context.Background(), "unknown", strings.NewReader("x"), -1, ""); err == nil {
+		t.Fatal("put with unknown size should fail")
+	}
+}
+
+func TestS3InvalidSignature(t *testing.T) {
+	srv := s3test.New(t, "files", "access", "secret")
+	store := newS3(t, srv, true, "wrong")
+	err := store.Put(context.Background(), "a.txt", strings.NewReader("a"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("err = %v, want SignatureDoesNotMatch", err)
+	}
+	if keys := srv.Keys(); len(keys) != 0 {
+		t.Fatalf("objects = %v, want none", keys)
+	}
+}
+
+func TestS3QueryAndBucket(t *testing.T) {
+	srv := s3test.New(t, "files", "access", "secret")
+	store := newS3(t, srv, true, "secret")
+	if err := store.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	other := s3test.New(t, "other", "access", "secret")
+	if err := newS3(t, other, true, "secret").Ping(context.Background()); !errors.Is(err, storage.ErrObjectNotFound) {
+		t.Fatalf("ping missing bucket err = %v, want %v", err, storage.ErrObjectNotFound)
+	}
+	if !slices.Equal(srv.Requests(), []string{"HEAD "}) {
+		t.Fatalf("requests = %v", srv.Requests())
+	}
+}
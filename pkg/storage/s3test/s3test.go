@@ -0,0 +1,281 @@
+/*
+Copyright © 2025 lixw
+*/
+
+// Package s3test S3兼容存储的内存替身，校验 Signature V4 签名，用于测试 S3 存储后端
+//
+//	srv := s3test.New(t, "bucket", "access", "secret")
+//	store, _ := storage.New(storage.WithDriver(storage.DriverS3), storage.WithS3(srv.URL(), "us-east-1", "bucket", "access", "secret"),
+//		storage.WithS3PathStyle(true), storage.WithHTTPClient(srv.Client()))
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	algorithm = "AWS4-HMAC-SHA256"
+	// maxSkew S3 允许的请求时间偏差
+	maxSkew = 15 * time.Minute
+)
+
+type Object struct {
+	Data        []byte
+	ContentType string
+	ModTime     time.Time
+}
+
+// Server 单个存储桶的 S3 替身，支持路径和虚拟主机两种访问方式，
+// 支持 HEAD bucket 以及对象的 PUT、GET、HEAD、DELETE
+type Server struct {
+	server    *httptest.Server
+	bucket    string
+	accessKey string
+	secretKey string
+
+	mu       sync.Mutex
+	objects  map[string]*Object
+	requests []string
+}
+
+// New 启动 S3 替身，测试结束后关闭
+func New(t testing.TB, bucket, accessKey, secretKey string) *Server {
+	t.Helper()
+	s := &Server{
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		objects:   make(map[string]*Object),
+	}
+	s.server = httptest.NewServer(s)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// URL 返回 endpoint，如 http://127.0.0.1:port
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Client 返回将所有主机名（包括虚拟主机方式的 bucket.127.0.0.1）连接到替身的客户端
+func (s *Server) Client() *http.Client {
+	addr := s.server.Listener.Addr().String()
+	transport := s.server.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	return &http.Client{Transport: transport}
+}
+
+// Object 返回存储的对象，不存在时返回 nil
+func (s *Server) Object(key string) *Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[key]
+}
+
+// Keys 返回全部对象键，按字典序排列
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Requests 返回已处理的请求，格式为 METHOD key，HEAD bucket 的 key 为空
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.objectKey(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if err := s.verify(r, body); err != nil {
+		writeError(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+key)
+	if key == "" {
+		if r.Method != http.MethodHead {
+			writeError(w, http.StatusNotImplemented, "NotImplemented", "only HEAD bucket is supported")
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = &Object{Data: body, ContentType: r.Header.Get("Content-Type"), ModTime: time.Now().UTC()}
+	case http.MethodGet, http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "key does not exist")
+			return
+		}
+		if obj.ContentType != "" {
+			w.Header().Set("Content-Type", obj.ContentType)
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(obj.Data)))
+		w.Header().Set("Last-Modified", obj.ModTime.Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.Data)
+		}
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// objectKey 按访问方式解析对象键，存储桶不匹配时返回 false
+func (s *Server) objectKey(r *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if bucket, ok := strings.CutSuffix(host, ".127.0.0.1"); ok {
+		return strings.TrimPrefix(r.URL.Path, "/"), bucket == s.bucket
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	return key, bucket == s.bucket
+}
+
+// verify 按 AWS Signature Version 4 规范重新计算签名并比较
+func (s *Server) verify(r *http.Request, body []byte) error {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), algorithm+" ")
+	if !ok {
+		return fmt.Errorf("unsupported authorization: %q", r.Header.Get("Authorization"))
+	}
+	params := make(map[string]string)
+	for _, part := range strings.Split(auth, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[k] = v
+	}
+	credential := strings.Split(params["Credential"], "/")
+	if len(credential) != 5 || credential[3] != "s3" || credential[4] != "aws4_request" {
+		return fmt.Errorf("invalid credential: %q", params["Credential"])
+	}
+	if credential[0] != s.accessKey {
+		return fmt.Errorf("unknown access key: %s", credential[0])
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	ts, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return fmt.Errorf("invalid x-amz-date: %q", amzDate)
+	}
+	if d := time.Since(ts); d > maxSkew || d < -maxSkew {
+		return fmt.Errorf("request time too skewed: %s", amzDate)
+	}
+	if credential[1] != ts.Format("20060102") {
+		return fmt.Errorf("credential date %s does not match x-amz-date %s", credential[1], amzDate)
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != "UNSIGNED-PAYLOAD" {
+		sum := sha256.Sum256(body)
+		if payloadHash != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("payload hash mismatch")
+		}
+	}
+	signedHeaders := strings.Split(params["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signedHeaders) || !slices.Contains(signedHeaders, "host") || !slices.Contains(signedHeaders, "x-amz-date") {
+		return fmt.Errorf("invalid signed headers: %q", params["SignedHeaders"])
+	}
+	var headers strings.Builder
+	for _, h := range signedHeaders {
+		value := r.Header.Get(h)
+		if h == "host" {
+			value = r.Host
+		}
+		headers.WriteString(h + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	canonical := strings.Join([]string{
+		r.Method,
+		escape(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		headers.String(),
+		params["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonical))
+	scope := strings.Join(credential[1:], "/")
+	stringToSign := algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range credential[1:] {
+		key = hmacSHA256(key, part)
+	}
+	want := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(want), []byte(params["Signature"])) {
+		return fmt.Errorf("signature mismatch, canonical request:\n%s", canonical)
+	}
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalQuery(values url.Values) string {
+	var pairs []string
+	for k, vs := range values {
+		for _, v := range vs {
+			pairs = append(pairs, escape(k, true)+"="+escape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// escape RFC 3986 非保留字符之外全部编码，encodeSlash 为 false 时保留 /
+func escape(s string, encodeSlash bool) string {
+	var b bytes.Buffer
+	for _, c := range []byte(s) {
+		unreserved := c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~", c) >= 0
+		if unreserved || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, message)
+}
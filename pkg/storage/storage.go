@@ -0,0 +1,130 @@
+/*
+Copyright © 2025 lixw
+*/
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage 对象存储抽象，key 使用 / 分隔的相对路径
+type Storage interface {
+	// Put 写入对象，size 为 -1 时表示未知大小（部分后端不支持）
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// Ping 检查存储后端是否可用
+	Ping(ctx context.Context) error
+}
+
+type Options struct {
+	driver      string
+	localRoot   string
+	s3Endpoint  string
+	s3Region    string
+	s3Bucket    string
+	s3AccessKey string
+	s3SecretKey string
+	s3PathStyle bool
+	httpClient  *http.Client
+}
+
+type Option func(*Options)
+
+// WithDriver 设置存储后端：local 或 s3
+func WithDriver(driver string) Option {
+	return func(o *Options) {
+		o.driver = driver
+	}
+}
+
+func WithLocalRoot(root string) Option {
+	return func(o *Options) {
+		o.localRoot = root
+	}
+}
+
+// WithS3 设置S3兼容存储的访问参数，endpoint 如 https://s3.amazonaws.com 或 http://127.0.0.1:9000
+func WithS3(endpoint, region, bucket, accessKey, secretKey string) Option {
+	return func(o *Options) {
+		o.s3Endpoint = endpoint
+		o.s3Region = region
+		o.s3Bucket = bucket
+		o.s3AccessKey = accessKey
+		o.s3SecretKey = secretKey
+	}
+}
+
+// WithS3PathStyle 使用 endpoint/bucket/key 形式的路径访问，MinIO 等本地替代服务通常需要开启
+func WithS3PathStyle(pathStyle bool) Option {
+	return func(o *Options) {
+		o.s3PathStyle = pathStyle
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) {
+		o.httpClient = client
+	}
+}
+
+func New(options ...Option) (Storage, error) {
+	opts := &Options{
+		driver:     DriverLocal,
+		localRoot:  "data/storage",
+		s3Region:   "us-east-1",
+		httpClient: http.DefaultClient,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	switch opts.driver {
+	case DriverLocal:
+		return NewLocal(opts.localRoot)
+	case DriverS3:
+		return NewS3(opts)
+	default:
+		return nil, fmt.Errorf("unsupported storage driver: %s", opts.driver)
+	}
+}
+
+type StorageService struct {
+	storage Storage
+}
+
+func NewService(storage Storage) *StorageService {
+	return &StorageService{storage: storage}
+}
+
+func (s *StorageService) Start(ctx context.Context) error {
+	if err := s.storage.Ping(ctx); err != nil {
+		return fmt.Errorf("storage is not available: %w", err)
+	}
+	slog.InfoContext(ctx, "storage connect successfully")
+	return nil
+}
+
+func (s *StorageService) Stop(ctx context.Context) error {
+	return nil
+}
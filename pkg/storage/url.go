@@ -0,0 +1,54 @@
+/*
+Copyright © 2025 lixw
+*/
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/signature"
+)
+
+var (
+	ErrURLExpired          = errors.New("download url expired")
+	ErrInvalidURLSignature = errors.New("invalid download url signature")
+)
+
+// URLSigner 为下载链接生成和校验带过期时间的签名
+type URLSigner struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewURLSigner 创建下载链接签名器，secret 为空时生成随机密钥（重启或多实例部署时已签发的链接失效）
+func NewURLSigner(secret []byte) *URLSigner {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	return &URLSigner{secret: secret, now: time.Now}
+}
+
+// Sign 对资源标识签名，返回过期时间戳和签名
+func (s *URLSigner) Sign(resource string, ttl time.Duration) (expires string, sig string) {
+	expires = strconv.FormatInt(s.now().Add(ttl).Unix(), 10)
+	return expires, signature.Sign(s.secret, resource+"\n"+expires)
+}
+
+func (s *URLSigner) Verify(resource, expires, sig string) error {
+	ts, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidURLSignature
+	}
+	if !hmac.Equal([]byte(signature.Sign(s.secret, resource+"\n"+expires)), []byte(sig)) {
+		return ErrInvalidURLSignature
+	}
+	if s.now().Unix() > ts {
+		return ErrURLExpired
+	}
+	return nil
+}
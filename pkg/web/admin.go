@@ -0,0 +1,63 @@
+/*
+Copyright © 2025 lixw
+*/
+package web
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/api"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/web/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// UseAdmin 在 /admin 下注册管理路由，需携带 Authorization: Bearer {adminToken} 访问
+func (s *Server) UseAdmin(routeFuncs ...func(*gin.RouterGroup)) *Server {
+	for _, fn := range routeFuncs {
+		fn(s.adminRoute)
+	}
+	return s
+}
+
+type maintenanceRequest struct {
+	// off、readonly 或 full，必填
+	Mode string `json:"mode"`
+	// 建议客户端重试间隔（秒）
+	RetryAfter int `json:"retry_after"`
+}
+
+func (s *Server) maintenanceRoutes(group *gin.RouterGroup) {
+	group.GET("/maintenance", func(ctx *gin.Context) {
+		api.SuccessWithData(ctx, s.maintenance.Status())
+	})
+	group.PUT("/maintenance", func(ctx *gin.Context) {
+		var req maintenanceRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "failed to parse request parameters"))
+			return
+		}
+		// 配置项未设置时视为 off，接口则要求显式指定，避免空请求体误关闭维护模式
+		if strings.TrimSpace(req.Mode) == "" {
+			api.Failure(ctx, errorx.New(errorx.ErrCodeValidation, "mode is required"))
+			return
+		}
+		mode, err := middleware.ParseMaintenanceMode(req.Mode)
+		if err != nil {
+			api.Failure(ctx, errorx.Wrap(err, errorx.ErrCodeValidation, "invalid maintenance mode"))
+			return
+		}
+		s.maintenance.SetMode(mode, time.Duration(req.RetryAfter)*time.Second)
+		slog.WarnContext(ctx, "maintenance mode changed", "mode", mode, "client_ip", ctx.ClientIP())
+		api.SuccessWithData(ctx, s.maintenance.Status())
+	})
+}
+
+// deprecationRoutes 废弃路由的调用统计，用于确认下线前是否仍有调用方
+func (s *Server) deprecationRoutes(group *gin.RouterGroup) {
+	group.GET("/deprecations", func(ctx *gin.Context) {
+		api.SuccessWithData(ctx, middleware.DeprecationStats())
+	})
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2025 lixw
+*/
+package web_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/internal/webtest"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/web"
+	"github.com/ethanli-dev/go-app-layout/pkg/web/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+const adminToken = "test-admin-token"
+
+func TestAdminDeprecations(t *testing.T) {
+	c := webtest.New(t, webtest.WithWebOptions(web.WithAdminToken(adminToken)))
+	c.Server().UseVersion(web.NewVersion("v0", web.Deprecated()), func(group *gin.RouterGroup) {
+		group.GET("/ping", func(ctx *gin.Context) { ctx.String(http.StatusOK, "pong") })
+	})
+	c.GET("/v0/ping").Header("X-Forwarded-For", "10.0.0.1").Send().Status(http.StatusOK)
+	c.GET("/v0/ping").Header("X-Forwarded-For", "10.0.0.1").Send().Status(http.StatusOK)
+
+	c.GET("/admin/deprecations").Send().Code(errorx.ErrCodeUnauthorized)
+	stats := webtest.Data[[]middleware.DeprecationStat](c.GET("/admin/deprecations").Bearer(adminToken).Send().OK())
+	for _, stat := range stats {
+		if stat.Route == "GET /v0/ping" && stat.Count == 2 {
+			return
+		}
+	}
+	t.Fatalf("stats = %+v, want GET /v0/ping called twice", stats)
+}
+
+func TestAdminMaintenance(t *testing.T) {
+	maintenance := middleware.NewMaintenanceSwitch()
+	c := webtest.New(t, webtest.WithWebOptions(web.WithAdminToken(adminToken), web.WithMaintenance(maintenance)))
+
+	c.PUT("/admin/maintenance").Bearer(adminToken).JSON(map[string]any{"mode": "readonly", "retry_after": 60}).Send().OK()
+	if status := maintenance.Status(); status.Mode != middleware.MaintenanceReadOnly || status.RetryAfter != 60 {
+		t.Fatalf("status = %+v, want readonly", status)
+	}
+	// 缺少或为空的 mode 不能关闭维护模式
+	for _, body := range []map[string]any{{}, {"mode": ""}, {"retry_after": 10}} {
+		c.PUT("/admin/maintenance").Bearer(adminToken).JSON(body).Send().Code(errorx.ErrCodeValidation).Message("mode is required")
+	}
+	c.PUT("/admin/maintenance").Bearer(adminToken).JSON(map[string]any{"mode": "unknown"}).Send().Code(errorx.ErrCodeValidation)
+	if mode := maintenance.Status().Mode; mode != middleware.MaintenanceReadOnly {
+		t.Fatalf("mode = %s, want readonly", mode)
+	}
+	c.PUT("/admin/maintenance").Bearer(adminToken).JSON(map[string]any{"mode": "off"}).Send().OK()
+	if mode := maintenance.Status().Mode; mode != middleware.MaintenanceOff {
+		t.Fatalf("mode = %s, want off", mode)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/logging"
+	"github.com/ethanli-dev/go-app-layout/pkg/ternary"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AccessLogFormatJSON     = "json"
+	AccessLogFormatCombined = "combined"
+)
+
+type AccessLogOptions struct {
+	writer   io.Writer
+	format   string
+	redactor *Redactor
+}
+
+type AccessLogOption func(*AccessLogOptions)
+
+func WithAccessLogWriter(writer io.Writer) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.writer = writer
+	}
+}
+
+// WithAccessLogFormat 设置访问日志格式：json 或 combined（Apache Combined Log Format）
+func WithAccessLogFormat(format string) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.format = format
+	}
+}
+
+func WithAccessLogRedactor(redactor *Redactor) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.redactor = redactor
+	}
+}
+
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RequestID  string  `json:"request_id"`
+	ClientIP   string  `json:"client_ip"`
+	Method     string  `json:"method"`
+	URL        string  `json:"url"`
+	Route      string  `json:"route"`
+	Proto      string  `json:"proto"`
+	Status     int     `json:"status"`
+	BytesIn    int64   `json:"bytes_in"`
+	BytesOut   int     `json:"bytes_out"`
+	DurationMs float64 `json:"duration_ms"`
+	Referer    string  `json:"referer"`
+	UserAgent  string  `json:"user_agent"`
+}
+
+// AccessLog 将每个请求以 json 或 combined 格式写入独立的访问日志
+func AccessLog(options ...AccessLogOption) gin.HandlerFunc {
+	opts := &AccessLogOptions{
+		format: AccessLogFormatJSON,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	if opts.writer == nil {
+		opts.writer = logging.NewWriter()
+	}
+	if opts.redactor == nil {
+		opts.redactor = NewRedactor()
+	}
+	var mu sync.Mutex
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		counter := countBody(ctx.Request)
+		ctx.Next()
+
+		entry := accessLogEntry{
+			Time:       start.Format(time.RFC3339Nano),
+			RequestID:  ctx.GetString(logging.ContextKeyTraceID),
+			ClientIP:   ctx.ClientIP(),
+			Method:     ctx.Request.Method,
+			URL:        opts.redactor.RedactURL(ctx.Request.URL),
+			Route:      ctx.FullPath(),
+			Proto:      ctx.Request.Proto,
+			Status:     ctx.Writer.Status(),
+			BytesIn:    requestSize(ctx.Request, counter),
+			BytesOut:   max(ctx.Writer.Size(), 0),
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			Referer:    ctx.Request.Referer(),
+			UserAgent:  ctx.Request.UserAgent(),
+		}
+		var line []byte
+		switch strings.ToLower(opts.format) {
+		case AccessLogFormatCombined:
+			line = formatCombined(&entry, start)
+		default:
+			buf, err := json.Marshal(&entry)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to encode access log", "err", err)
+				return
+			}
+			line = append(buf, '\n')
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := opts.writer.Write(line); err != nil {
+			slog.ErrorContext(ctx, "failed to write access log", "err", err)
+		}
+	}
+}
+
+// formatCombined 按 Apache Combined Log Format 输出：%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+func formatCombined(entry *accessLogEntry, start time.Time) []byte {
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, "%s - - [%s] \"%s %s %s\" %d %s %q %q\n",
+		entry.ClientIP, start.Format("02/Jan/2006:15:04:05 -0700"),
+		entry.Method, entry.URL, entry.Proto, entry.Status,
+		ternary.IFElse(entry.BytesOut > 0, strconv.Itoa(entry.BytesOut), "-"),
+		ternary.IFElse(entry.Referer == "", "-", entry.Referer),
+		ternary.IFElse(entry.UserAgent == "", "-", entry.UserAgent))
+	return buf.Bytes()
+}
+
+// bodyCounter 统计请求体实际读取的字节数
+type bodyCounter struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *bodyCounter) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func countBody(r *http.Request) *bodyCounter {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	counter := &bodyCounter{ReadCloser: r.Body}
+	r.Body = counter
+	return counter
+}
+
+// requestSize 优先使用 Content-Length，分块传输时使用实际读取的字节数
+func requestSize(r *http.Request, counter *bodyCounter) int64 {
+	if r.ContentLength >= 0 {
+		return r.ContentLength
+	}
+	if counter != nil {
+		return counter.n
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/ethanli-dev/go-app-layout/api"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 校验管理接口的 Bearer Token，未配置 token 时拒绝所有请求
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token == "" {
+			api.Failure(ctx, errorx.New(errorx.ErrCodeForbidden, "admin api is disabled"))
+			ctx.Abort()
+			return
+		}
+		bearer, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			api.Failure(ctx, errorx.New(errorx.ErrCodeUnauthorized, "invalid admin token"))
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
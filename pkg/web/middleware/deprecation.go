@@ -0,0 +1,230 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"container/list"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderKeyDeprecation = "Deprecation"
+	HeaderKeySunset      = "Sunset"
+	HeaderKeyLink        = "Link"
+)
+
+type DeprecationOptions struct {
+	deprecatedAt time.Time
+	sunset       time.Time
+	link         string
+	counter      *DeprecationCounter
+	callerFunc   func(*gin.Context) string
+}
+
+type DeprecationOption func(*DeprecationOptions)
+
+// WithDeprecatedAt 设置废弃时间，未设置时使用当前时间
+func WithDeprecatedAt(deprecatedAt time.Time) DeprecationOption {
+	return func(o *DeprecationOptions) {
+		o.deprecatedAt = deprecatedAt
+	}
+}
+
+// WithSunset 设置下线时间（RFC 8594）
+func WithSunset(sunset time.Time) DeprecationOption {
+	return func(o *DeprecationOptions) {
+		o.sunset = sunset
+	}
+}
+
+// WithDeprecationLink 设置迁移说明文档地址
+func WithDeprecationLink(link string) DeprecationOption {
+	return func(o *DeprecationOptions) {
+		o.link = link
+	}
+}
+
+func WithDeprecationCounter(counter *DeprecationCounter) DeprecationOption {
+	return func(o *DeprecationOptions) {
+		o.counter = counter
+	}
+}
+
+// WithCallerFunc 设置调用方识别函数，默认使用客户端IP
+func WithCallerFunc(callerFunc func(*gin.Context) string) DeprecationOption {
+	return func(o *DeprecationOptions) {
+		o.callerFunc = callerFunc
+	}
+}
+
+// Deprecation 为废弃的路由输出 Deprecation/Sunset 响应头（RFC 9745/RFC 8594），并按调用方统计调用次数
+func Deprecation(options ...DeprecationOption) gin.HandlerFunc {
+	opts := &DeprecationOptions{
+		deprecatedAt: time.Now(),
+		counter:      defaultDeprecationCounter,
+		callerFunc: func(ctx *gin.Context) string {
+			return ctx.ClientIP()
+		},
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	deprecation := fmt.Sprintf("@%d", opts.deprecatedAt.Unix())
+	var sunset string
+	if !opts.sunset.IsZero() {
+		sunset = opts.sunset.UTC().Format(http.TimeFormat)
+	}
+	var link string
+	if opts.link != "" {
+		link = fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, opts.link)
+	}
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+		header.Set(HeaderKeyDeprecation, deprecation)
+		if sunset != "" {
+			header.Set(HeaderKeySunset, sunset)
+		}
+		if link != "" {
+			header.Add(HeaderKeyLink, link)
+		}
+		route := ctx.Request.Method + " " + ctx.FullPath()
+		caller := opts.callerFunc(ctx)
+		// 每个调用方首次调用时记录日志，避免日志刷屏
+		if opts.counter.Inc(route, caller) == 1 {
+			slog.WarnContext(ctx, "deprecated route called", "route", route, "caller", caller)
+		}
+		ctx.Next()
+	}
+}
+
+type DeprecationStat struct {
+	Route    string    `json:"route"`
+	Caller   string    `json:"caller"`
+	Count    int64     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type deprecationKey struct {
+	route  string
+	caller string
+}
+
+type DeprecationCounterOptions struct {
+	maxEntries int
+	ttl        time.Duration
+}
+
+type DeprecationCounterOption func(*DeprecationCounterOptions)
+
+// WithMaxCallers 设置最多统计的路由和调用方组合数，默认 10000
+func WithMaxCallers(maxEntries int) DeprecationCounterOption {
+	return func(o *DeprecationCounterOptions) {
+		if maxEntries > 0 {
+			o.maxEntries = maxEntries
+		}
+	}
+}
+
+// WithCallerTTL 设置调用方的统计保留时长，超过该时长未再调用的记录可被清理，默认 24 小时
+func WithCallerTTL(ttl time.Duration) DeprecationCounterOption {
+	return func(o *DeprecationCounterOptions) {
+		if ttl > 0 {
+			o.ttl = ttl
+		}
+	}
+}
+
+// DeprecationCounter 按路由和调用方统计废弃接口的调用次数；记录数达到上限时先清理过期记录，
+// 仍然已满时淘汰最久未调用的记录（LRU），被清理的调用方再次调用时重新计数
+type DeprecationCounter struct {
+	mu    sync.Mutex
+	opts  *DeprecationCounterOptions
+	ll    *list.List
+	stats map[deprecationKey]*list.Element
+	now   func() time.Time
+}
+
+var defaultDeprecationCounter = NewDeprecationCounter()
+
+func NewDeprecationCounter(options ...DeprecationCounterOption) *DeprecationCounter {
+	opts := &DeprecationCounterOptions{
+		maxEntries: 10000,
+		ttl:        24 * time.Hour,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	return &DeprecationCounter{
+		opts:  opts,
+		ll:    list.New(),
+		stats: make(map[deprecationKey]*list.Element),
+		now:   time.Now,
+	}
+}
+
+// Inc 增加调用计数并返回当前计数
+func (c *DeprecationCounter) Inc(route, caller string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	key := deprecationKey{route: route, caller: caller}
+	el, ok := c.stats[key]
+	if ok {
+		c.ll.MoveToFront(el)
+	} else {
+		if c.ll.Len() >= c.opts.maxEntries {
+			c.evict(now)
+		}
+		el = c.ll.PushFront(&DeprecationStat{Route: route, Caller: caller})
+		c.stats[key] = el
+	}
+	stat := el.Value.(*DeprecationStat)
+	stat.Count++
+	stat.LastSeen = now
+	return stat.Count
+}
+
+// evict 从最久未调用的一端清理过期记录，没有过期记录时淘汰最久未调用的一条
+func (c *DeprecationCounter) evict(now time.Time) {
+	for el := c.ll.Back(); el != nil; el = c.ll.Back() {
+		if now.Sub(el.Value.(*DeprecationStat).LastSeen) <= c.opts.ttl && c.ll.Len() < c.opts.maxEntries {
+			return
+		}
+		c.remove(el)
+	}
+}
+
+func (c *DeprecationCounter) remove(el *list.Element) {
+	stat := c.ll.Remove(el).(*DeprecationStat)
+	delete(c.stats, deprecationKey{route: stat.Route, caller: stat.Caller})
+}
+
+// Snapshot 返回按路由、调用方排序的统计快照
+func (c *DeprecationCounter) Snapshot() []DeprecationStat {
+	c.mu.Lock()
+	stats := make([]DeprecationStat, 0, c.ll.Len())
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		stats = append(stats, *el.Value.(*DeprecationStat))
+	}
+	c.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Route != stats[j].Route {
+			return stats[i].Route < stats[j].Route
+		}
+		return stats[i].Caller < stats[j].Caller
+	})
+	return stats
+}
+
+// DeprecationStats 返回默认计数器的统计快照
+func DeprecationStats() []DeprecationStat {
+	return defaultDeprecationCounter.Snapshot()
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func callers(stats []DeprecationStat) []string {
+	var names []string
+	for _, stat := range stats {
+		names = append(names, stat.Caller)
+	}
+	return names
+}
+
+// fakeClock 测试时钟，advance 推进当前时间
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestCounter(options ...DeprecationCounterOption) (*DeprecationCounter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewDeprecationCounter(options...)
+	c.now = clock.Now
+	return c, clock
+}
+
+func TestDeprecationCounterMaxCallers(t *testing.T) {
+	c, clock := newTestCounter(WithMaxCallers(2))
+	c.Inc("GET /v1/a", "1.1.1.1")
+	clock.advance(time.Second)
+	c.Inc("GET /v1/a", "2.2.2.2")
+	clock.advance(time.Second)
+	// 1.1.1.1 最久未调用，被淘汰
+	c.Inc("GET /v1/a", "2.2.2.2")
+	c.Inc("GET /v1/a", "3.3.3.3")
+	stats := c.Snapshot()
+	if got := callers(stats); len(got) != 2 || got[0] != "2.2.2.2" || got[1] != "3.3.3.3" {
+		t.Fatalf("callers = %v, want [2.2.2.2 3.3.3.3]", got)
+	}
+	if stats[0].Count != 2 {
+		t.Fatalf("count = %d, want 2", stats[0].Count)
+	}
+	if n := c.Inc("GET /v1/a", "1.1.1.1"); n != 1 {
+		t.Fatalf("evicted caller count = %d, want 1", n)
+	}
+}
+
+func TestDeprecationCounterTTL(t *testing.T) {
+	c, clock := newTestCounter(WithMaxCallers(3), WithCallerTTL(time.Minute))
+	c.Inc("GET /v1/a", "1.1.1.1")
+	c.Inc("GET /v1/a", "2.2.2.2")
+	c.Inc("GET /v1/a", "3.3.3.3")
+	clock.advance(2 * time.Minute)
+	c.Inc("GET /v1/a", "3.3.3.3")
+	// 已满时一次清理全部过期记录
+	c.Inc("GET /v1/a", "4.4.4.4")
+	if got := callers(c.Snapshot()); len(got) != 2 || got[0] != "3.3.3.3" || got[1] != "4.4.4.4" {
+		t.Fatalf("callers = %v, want [3.3.3.3 4.4.4.4]", got)
+	}
+}
+
+// TestDeprecationCounterLRU 调用时间相同时按最近调用顺序淘汰
+func TestDeprecationCounterLRU(t *testing.T) {
+	c, clock := newTestCounter(WithMaxCallers(3))
+	for _, caller := range []string{"a", "b", "c", "a", "d", "e"} {
+		c.Inc("GET /v1/a", caller)
+	}
+	stats := c.Snapshot()
+	if got := callers(stats); len(got) != 3 || got[0] != "a" || got[1] != "d" || got[2] != "e" {
+		t.Fatalf("callers = %v, want [a d e]", got)
+	}
+	if stats[0].Count != 2 || !stats[0].LastSeen.Equal(clock.Now()) {
+		t.Fatalf("stat = %+v, want count 2 at %s", stats[0], clock.Now())
+	}
+}
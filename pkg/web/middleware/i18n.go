@@ -24,7 +24,7 @@ func I18n() gin.HandlerFunc {
 				locale = languages[0]
 			}
 		}
-		ctx.Request = ctx.Request.WithContext(i18n.SetLocale(ctx, locale))
+		ctx.Request = ctx.Request.WithContext(i18n.SetLocale(ctx.Request.Context(), locale))
 		ctx.Next()
 	}
 }
@@ -0,0 +1,221 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ethanli-dev/go-app-layout/api"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientIPResolver 根据可信代理列表从 Forwarded/X-Forwarded-For/X-Real-Ip 中解析真实客户端IP
+type ClientIPResolver struct {
+	mu      sync.RWMutex
+	trusted []*net.IPNet
+}
+
+func NewClientIPResolver() *ClientIPResolver {
+	return &ClientIPResolver{}
+}
+
+// SetTrustedProxies 设置可信代理（支持CIDR），为空时不信任任何代理转发的地址
+func (r *ClientIPResolver) SetTrustedProxies(proxies []string) error {
+	nets, err := ParseCIDRs(proxies)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trusted = nets
+	return nil
+}
+
+func (r *ClientIPResolver) isTrusted(ip net.IP) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return containsIP(r.trusted, ip)
+}
+
+// Resolve 从右向左遍历转发链，跳过可信代理，返回第一个不可信的地址；直连地址不可信时直接返回直连地址
+func (r *ClientIPResolver) Resolve(req *http.Request) string {
+	peer := remoteHost(req.RemoteAddr)
+	peerIP := net.ParseIP(peer)
+	if peerIP == nil || !r.isTrusted(peerIP) {
+		return peer
+	}
+	chain := forwardedFor(req.Header)
+	if len(chain) == 0 {
+		return peer
+	}
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(chain[i])
+		if ip == nil {
+			// 无法识别的地址（如 unknown 或混淆标识）之后的内容不可信
+			break
+		}
+		client = ip.String()
+		if !r.isTrusted(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFor 依次读取 Forwarded(RFC 7239)、X-Forwarded-For、X-Real-Ip 请求头中的转发链
+func forwardedFor(header http.Header) []string {
+	var chain []string
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(key, "for") {
+						chain = append(chain, stripPort(strings.Trim(val, `"`)))
+					}
+				}
+			}
+		}
+		return chain
+	}
+	if values := header.Values("X-Forwarded-For"); len(values) > 0 {
+		for _, value := range values {
+			for _, ip := range strings.Split(value, ",") {
+				chain = append(chain, stripPort(strings.TrimSpace(ip)))
+			}
+		}
+		return chain
+	}
+	if ip := strings.TrimSpace(header.Get("X-Real-Ip")); ip != "" {
+		chain = append(chain, stripPort(ip))
+	}
+	return chain
+}
+
+// stripPort 去除地址中的端口和IPv6方括号，如 [2001:db8::1]:4711 或 192.0.2.1:80
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
+
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(remoteAddr))
+	if err != nil {
+		return strings.TrimSpace(remoteAddr)
+	}
+	return host
+}
+
+// RealIP 将请求的 RemoteAddr 替换为解析后的客户端IP，之后 ctx.ClientIP() 即返回真实客户端IP
+func RealIP(resolver *ClientIPResolver) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ip := resolver.Resolve(ctx.Request)
+		_, port, err := net.SplitHostPort(ctx.Request.RemoteAddr)
+		if err != nil {
+			port = "0"
+		}
+		ctx.Request.RemoteAddr = net.JoinHostPort(ip, port)
+		ctx.Next()
+	}
+}
+
+// IPFilter IP黑白名单，命中黑名单或白名单非空且未命中时拒绝访问，支持运行时更新
+type IPFilter struct {
+	mu    sync.RWMutex
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func NewIPFilter() *IPFilter {
+	return &IPFilter{}
+}
+
+func (f *IPFilter) Set(allow, deny []string) error {
+	allowNets, err := ParseCIDRs(allow)
+	if err != nil {
+		return err
+	}
+	denyNets, err := ParseCIDRs(deny)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allow = allowNets
+	f.deny = denyNets
+	return nil
+}
+
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if ip == nil {
+		return len(f.allow) == 0 && len(f.deny) == 0
+	}
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || containsIP(f.allow, ip)
+}
+
+// IPAccess IP访问控制中间件，拒绝的请求返回 ErrCodeForbidden
+func IPAccess(filter *IPFilter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ip := ctx.ClientIP()
+		if !filter.Allowed(net.ParseIP(ip)) {
+			slog.WarnContext(ctx, "request blocked by ip filter", "client_ip", ip, "path", ctx.Request.URL.Path)
+			api.Failure(ctx, errorx.New(errorx.ErrCodeForbidden, "access denied"))
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// ParseCIDRs 解析IP或CIDR列表，单个IP视为 /32 或 /128
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address: %s", value)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr: %s", value)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,12 +5,16 @@ package middleware
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/ethanli-dev/go-app-layout/api"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
 	"github.com/ethanli-dev/go-app-layout/pkg/logging"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,6 +22,7 @@ import (
 
 const (
 	requestBodyLimit   = 1 << 20
+	responseBodyLimit  = 64 << 10
 	HeaderKeyRequestID = "X-Request-Id"
 )
 
@@ -45,7 +50,100 @@ func RequestId() gin.HandlerFunc {
 	}
 }
 
-func Logger() gin.HandlerFunc {
+type LoggerOptions struct {
+	redactor        *Redactor
+	logHeaders      bool
+	skipBodyRoutes  map[string]struct{}
+	captureResponse bool
+	sampleRate      float64
+	routeSampleRate map[string]float64
+}
+
+type LoggerOption func(*LoggerOptions)
+
+func WithRedactor(redactor *Redactor) LoggerOption {
+	return func(o *LoggerOptions) {
+		o.redactor = redactor
+	}
+}
+
+// WithLogHeaders 记录（脱敏后的）请求头
+func WithLogHeaders(logHeaders bool) LoggerOption {
+	return func(o *LoggerOptions) {
+		o.logHeaders = logHeaders
+	}
+}
+
+// WithSkipBodyRoutes 指定不记录请求体的路由，使用路由模板，如 /v1/tenant/:id
+func WithSkipBodyRoutes(routes ...string) LoggerOption {
+	return func(o *LoggerOptions) {
+		for _, route := range routes {
+			o.skipBodyRoutes[route] = struct{}{}
+		}
+	}
+}
+
+// WithResponseCapture 记录响应体，错误响应（HTTP 状态码>=400或业务错误码）始终记录，成功响应按采样率记录
+func WithResponseCapture(captureResponse bool) LoggerOption {
+	return func(o *LoggerOptions) {
+		o.captureResponse = captureResponse
+	}
+}
+
+// WithResponseSampleRate 设置成功响应体的默认采样率（0-1）
+func WithResponseSampleRate(rate float64) LoggerOption {
+	return func(o *LoggerOptions) {
+		o.sampleRate = rate
+	}
+}
+
+// WithRouteSampleRate 按路由模板设置成功响应体的采样率（0-1），如 /v1/tenant/:id
+func WithRouteSampleRate(route string, rate float64) LoggerOption {
+	return func(o *LoggerOptions) {
+		o.routeSampleRate[route] = rate
+	}
+}
+
+// responseCaptureWriter 在写出响应的同时保留不超过 responseBodyLimit 的响应体副本
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	buf       *bytes.Buffer
+	truncated bool
+}
+
+func (w *responseCaptureWriter) capture(n int, write func(remaining int)) {
+	remaining := responseBodyLimit - w.buf.Len()
+	if remaining <= 0 {
+		w.truncated = w.truncated || n > 0
+		return
+	}
+	if n > remaining {
+		w.truncated = true
+	}
+	write(min(n, remaining))
+}
+
+func (w *responseCaptureWriter) Write(b []byte) (int, error) {
+	w.capture(len(b), func(n int) { w.buf.Write(b[:n]) })
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCaptureWriter) WriteString(s string) (int, error) {
+	w.capture(len(s), func(n int) { w.buf.WriteString(s[:n]) })
+	return w.ResponseWriter.WriteString(s)
+}
+
+func Logger(options ...LoggerOption) gin.HandlerFunc {
+	opts := &LoggerOptions{
+		skipBodyRoutes:  make(map[string]struct{}),
+		routeSampleRate: make(map[string]float64),
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	if opts.redactor == nil {
+		opts.redactor = NewRedactor()
+	}
 	return func(ctx *gin.Context) {
 		if ctx.Request.Method == http.MethodOptions {
 			ctx.Next()
@@ -55,42 +153,89 @@ func Logger() gin.HandlerFunc {
 		var (
 			reqBody         []byte
 			isBodyTruncated bool
+			bodyOmitted     string
 		)
-		if ctx.Request.Body != http.NoBody {
+		contentType := ctx.ContentType()
+		counter := countBody(ctx.Request)
+		var capture *responseCaptureWriter
+		if opts.captureResponse {
 			buf := bufPool.Get().(*bytes.Buffer)
 			defer func() {
 				buf.Reset()
 				bufPool.Put(buf)
 			}()
-			// 限制读取的最大字节数1MB，避免内存溢出
-			limitedReader := &io.LimitedReader{
-				R: ctx.Request.Body,
-				N: requestBodyLimit,
-			}
-			if n, err := io.Copy(buf, limitedReader); err == nil {
-				// 检查是否被截断
-				isBodyTruncated = limitedReader.N <= 0 && n >= requestBodyLimit
-				reqBody = buf.Bytes()
-				// 重置请求体供后续处理使用
-				ctx.Request.Body = io.NopCloser(bytes.NewReader(reqBody))
+			capture = &responseCaptureWriter{ResponseWriter: ctx.Writer, buf: buf}
+			ctx.Writer = capture
+		}
+		if ctx.Request.Body != http.NoBody && ctx.Request.Body != nil {
+			_, skipRoute := opts.skipBodyRoutes[ctx.FullPath()]
+			switch {
+			case skipRoute:
+				bodyOmitted = "[body logging disabled for route]"
+			case !IsLoggableContentType(ctx.GetHeader("Content-Type")):
+				// 二进制内容和文件上传不读取，避免占用内存和记录敏感文件
+				bodyOmitted = fmt.Sprintf("[body omitted, content-type=%q, content-length=%d]", contentType, ctx.Request.ContentLength)
+			default:
+				buf := bufPool.Get().(*bytes.Buffer)
+				defer func() {
+					buf.Reset()
+					bufPool.Put(buf)
+				}()
+				// 限制读取的最大字节数1MB，避免内存溢出
+				limitedReader := &io.LimitedReader{
+					R: ctx.Request.Body,
+					N: requestBodyLimit,
+				}
+				if n, err := io.Copy(buf, limitedReader); err == nil {
+					// 检查是否被截断
+					isBodyTruncated = limitedReader.N <= 0 && n >= requestBodyLimit
+					reqBody = buf.Bytes()
+					// 重置请求体供后续处理使用，被截断时拼接剩余未读取的内容
+					if isBodyTruncated {
+						ctx.Request.Body = struct {
+							io.Reader
+							io.Closer
+						}{io.MultiReader(bytes.NewReader(reqBody), ctx.Request.Body), ctx.Request.Body}
+					} else {
+						ctx.Request.Body = io.NopCloser(bytes.NewReader(reqBody))
+					}
+				}
 			}
 		}
 
 		defer func() {
 			cost := time.Since(start)
 			status := ctx.Writer.Status()
-			bodyStr := string(reqBody)
-			if len(reqBody) > 0 && isBodyTruncated {
-				bodyStr += " [truncated]"
+			bodyStr := bodyOmitted
+			if len(reqBody) > 0 {
+				bodyStr = opts.redactor.RedactBody(contentType, reqBody)
+				if isBodyTruncated {
+					bodyStr += " [truncated]"
+				}
 			}
 
 			attrs := []any{
 				slog.String("method", ctx.Request.Method),
-				slog.String("url", ctx.Request.URL.String()),
+				slog.String("url", opts.redactor.RedactURL(ctx.Request.URL)),
+				slog.String("route", ctx.FullPath()),
+				slog.String("client_ip", ctx.ClientIP()),
+				slog.String("user_agent", ctx.Request.UserAgent()),
 				slog.Int("status", status),
 				slog.Duration("cost", cost),
+				slog.Int64("bytes_in", requestSize(ctx.Request, counter)),
+				slog.Int("bytes_out", max(ctx.Writer.Size(), 0)),
 				slog.String("request", bodyStr),
 			}
+			if capture != nil && opts.shouldLogResponse(ctx, status) {
+				respStr := opts.redactor.RedactBody(capture.Header().Get("Content-Type"), capture.buf.Bytes())
+				if capture.truncated {
+					respStr += " [truncated]"
+				}
+				attrs = append(attrs, slog.String("response", respStr))
+			}
+			if opts.logHeaders {
+				attrs = append(attrs, slog.Any("headers", opts.redactor.RedactHeaders(ctx.Request.Header)))
+			}
 			switch {
 			case status >= http.StatusInternalServerError:
 				slog.ErrorContext(ctx, "request completed with error", attrs...)
@@ -103,3 +248,18 @@ func Logger() gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// shouldLogResponse 错误响应始终记录响应体，成功响应按路由采样率记录
+func (o *LoggerOptions) shouldLogResponse(ctx *gin.Context, status int) bool {
+	if status >= http.StatusBadRequest {
+		return true
+	}
+	if code, ok := ctx.Get(api.ContextKeyErrorCode); ok && code != errorx.ErrCodeSuccess {
+		return true
+	}
+	rate, ok := o.routeSampleRate[ctx.FullPath()]
+	if !ok {
+		rate = o.sampleRate
+	}
+	return rate > 0 && (rate >= 1 || rand.Float64() < rate)
+}
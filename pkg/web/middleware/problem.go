@@ -0,0 +1,18 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"github.com/ethanli-dev/go-app-layout/api"
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemDetails 路由组的错误响应使用错误码对应的HTTP状态码和 RFC 9457 application/problem+json，
+// typeBaseURI 为问题类型 URI 前缀，为空时使用 about:blank
+func ProblemDetails(typeBaseURI string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		api.UseProblemDetails(ctx, typeBaseURI)
+		ctx.Next()
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/ethanli-dev/go-app-layout/api"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
 	"github.com/gin-gonic/gin"
 )
 
@@ -61,7 +63,12 @@ func Recovery() gin.HandlerFunc {
 					return
 				}
 
-				// 其他错误返回500
+				// 其他错误返回500，启用问题详情的路由组同时输出 problem+json
+				if api.ProblemDetailsEnabled(ctx) && !ctx.Writer.Written() {
+					api.Failure(ctx, errorx.New(errorx.ErrCodeInternalServer, "internal server error"))
+					ctx.Abort()
+					return
+				}
 				ctx.AbortWithStatus(http.StatusInternalServerError)
 			}
 		}()
@@ -0,0 +1,304 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	redactedValue = "******"
+	// arrayIndex JSON 路径中数组下标的路径段
+	arrayIndex = "[]"
+)
+
+var (
+	defaultRedactJSONPaths   = []string{"password", "passwd", "secret", "token", "api_key", "access_token", "refresh_token", "authorization", "id_card", "phone"}
+	defaultRedactHeaders     = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", HeaderKeyAPIKey}
+	defaultRedactQueryParams = []string{"token", "access_token", "api_key", "password", "signature", "sig"}
+	// cardNumberRegexp 匹配13-19位银行卡号（允许空格或短横线分隔），命中后再经 Luhn 校验，避免误伤时间戳等数字
+	cardNumberRegexp = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	// keyValueRegexp 匹配纯文本中的 key=value、key: value，值可带双引号
+	keyValueRegexp = regexp.MustCompile(`([\w-]+)(\s*[=:]\s*)("[^"]*"|[^\s&,;]+)`)
+)
+
+type RedactOptions struct {
+	jsonPaths   []string
+	headers     []string
+	queryParams []string
+	patterns    []*regexp.Regexp
+}
+
+type RedactOption func(*RedactOptions)
+
+// WithRedactJSONPaths 追加需要脱敏的JSON路径，如 password、user.card.number、items.*.secret；单级路径匹配任意深度的同名字段
+func WithRedactJSONPaths(paths ...string) RedactOption {
+	return func(o *RedactOptions) {
+		o.jsonPaths = append(o.jsonPaths, paths...)
+	}
+}
+
+func WithRedactHeaders(headers ...string) RedactOption {
+	return func(o *RedactOptions) {
+		o.headers = append(o.headers, headers...)
+	}
+}
+
+func WithRedactQueryParams(params ...string) RedactOption {
+	return func(o *RedactOptions) {
+		o.queryParams = append(o.queryParams, params...)
+	}
+}
+
+// WithRedactPatterns 追加正则脱敏规则，匹配的内容替换为 ******
+func WithRedactPatterns(patterns ...*regexp.Regexp) RedactOption {
+	return func(o *RedactOptions) {
+		o.patterns = append(o.patterns, patterns...)
+	}
+}
+
+// Redactor 对请求日志中的请求体、请求头和查询参数进行脱敏
+type Redactor struct {
+	jsonPaths   [][]string
+	headers     map[string]struct{}
+	queryParams map[string]struct{}
+	// keys 纯文本中需要脱敏的键，包括查询参数和单级 JSON 路径
+	keys     map[string]struct{}
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor 创建脱敏器，默认规则覆盖常见的密码、令牌、密钥字段及银行卡号
+func NewRedactor(options ...RedactOption) *Redactor {
+	opts := &RedactOptions{
+		jsonPaths:   defaultRedactJSONPaths,
+		headers:     defaultRedactHeaders,
+		queryParams: defaultRedactQueryParams,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	r := &Redactor{
+		headers:     make(map[string]struct{}, len(opts.headers)),
+		queryParams: make(map[string]struct{}, len(opts.queryParams)),
+		keys:        make(map[string]struct{}),
+		patterns:    opts.patterns,
+	}
+	for _, p := range opts.jsonPaths {
+		segments := strings.Split(p, ".")
+		for i := range segments {
+			segments[i] = normalizeKey(segments[i])
+		}
+		r.jsonPaths = append(r.jsonPaths, segments)
+		if len(segments) == 1 {
+			r.keys[segments[0]] = struct{}{}
+		}
+	}
+	for _, h := range opts.headers {
+		r.headers[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	for _, q := range opts.queryParams {
+		r.queryParams[normalizeKey(q)] = struct{}{}
+		r.keys[normalizeKey(q)] = struct{}{}
+	}
+	return r
+}
+
+// normalizeKey 统一字段名格式，apiKey、api_key、api-key 视为同一字段
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// IsLoggableContentType 判断请求体是否为可记录的文本类型，二进制和文件上传内容不记录
+func IsLoggableContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/x-www-form-urlencoded",
+		strings.HasPrefix(mediaType, "text/"):
+		return true
+	default:
+		return false
+	}
+}
+
+// RedactBody 按内容类型脱敏请求体，JSON 无法解析时（如被截断）不记录原文；
+// 其他文本类型按 key=value、key: value 脱敏敏感键的值
+func (r *Redactor) RedactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var v any
+		if err := decoder.Decode(&v); err != nil {
+			return fmt.Sprintf("[unparseable json body omitted, %d bytes]", len(body))
+		}
+		buf, err := json.Marshal(r.redactJSON(v, nil))
+		if err != nil {
+			return fmt.Sprintf("[unparseable json body omitted, %d bytes]", len(body))
+		}
+		return string(buf)
+	case mediaType == "application/x-www-form-urlencoded":
+		return r.redactPatterns(r.redactQuery(string(body)))
+	default:
+		return r.redactPatterns(r.redactPairs(string(body)))
+	}
+}
+
+func (r *Redactor) redactJSON(v any, path []string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			childPath := append(path[:len(path):len(path)], normalizeKey(k))
+			if r.matchJSONPath(childPath) {
+				val[k] = redactedValue
+				continue
+			}
+			val[k] = r.redactJSON(child, childPath)
+		}
+		return val
+	case []any:
+		// 数组元素的路径段可省略，items.secret 和 items.*.secret 均可匹配 items[*].secret
+		childPath := append(path[:len(path):len(path)], arrayIndex)
+		for i, child := range val {
+			val[i] = r.redactJSON(child, childPath)
+		}
+		return val
+	case string:
+		return r.redactPatterns(val)
+	case json.Number:
+		// 数字命中规则时替换为字符串，保证输出仍是合法的 JSON
+		if r.redactPatterns(val.String()) != val.String() {
+			return redactedValue
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) matchJSONPath(path []string) bool {
+	for _, rule := range r.jsonPaths {
+		if len(rule) == 1 {
+			if rule[0] == path[len(path)-1] {
+				return true
+			}
+			continue
+		}
+		if matchSegments(rule, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(rule, path []string) bool {
+	if len(rule) == 0 || len(path) == 0 {
+		return len(rule) == 0 && len(path) == 0
+	}
+	if path[0] == arrayIndex && matchSegments(rule, path[1:]) {
+		return true
+	}
+	if rule[0] != "*" && rule[0] != path[0] {
+		return false
+	}
+	return matchSegments(rule[1:], path[1:])
+}
+
+// redactQuery 脱敏 a=1&b=2 格式的参数，保留原始编码和顺序
+func (r *Redactor) redactQuery(query string) string {
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		rawKey, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if _, ok := r.queryParams[normalizeKey(key)]; ok {
+			pairs[i] = rawKey + "=" + redactedValue
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactPairs 脱敏纯文本中敏感键的值，如 password=abc、token: xyz
+func (r *Redactor) redactPairs(s string) string {
+	return keyValueRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		groups := keyValueRegexp.FindStringSubmatch(match)
+		if _, ok := r.keys[normalizeKey(groups[1])]; !ok {
+			return match
+		}
+		return groups[1] + groups[2] + redactedValue
+	})
+}
+
+func (r *Redactor) redactPatterns(s string) string {
+	s = cardNumberRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		if luhnValid(match) {
+			return redactedValue
+		}
+		return match
+	})
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, redactedValue)
+	}
+	return s
+}
+
+// RedactURL 脱敏URL中的查询参数
+func (r *Redactor) RedactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	redacted := *u
+	redacted.RawQuery = ""
+	return r.redactPatterns(redacted.String() + "?" + r.redactQuery(u.RawQuery))
+}
+
+// RedactHeaders 返回脱敏后的请求头，多值请求头以逗号拼接
+func (r *Redactor) RedactHeaders(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+	for key, values := range header {
+		if _, ok := r.headers[http.CanonicalHeaderKey(key)]; ok {
+			result[key] = redactedValue
+			continue
+		}
+		result[key] = r.redactPatterns(strings.Join(values, ", "))
+	}
+	return result
+}
+
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
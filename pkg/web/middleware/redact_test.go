@@ -0,0 +1,116 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	r := NewRedactor(
+		WithRedactJSONPaths("user.card.number", "items.*.code", "orders.note"),
+		WithRedactPatterns(regexp.MustCompile(`\d{6}(19|20)\d{6}\d{3}[\dXx]`)),
+	)
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json field at any depth", "application/json", `{"password":"abc","user":{"Password":"abc","name":"bob"}}`, `{"password":"******","user":{"Password":"******","name":"bob"}}`},
+		{"json normalized key", "application/json", `{"apiKey":"k","API-KEY":"k","api_key":"k"}`, `{"API-KEY":"******","apiKey":"******","api_key":"******"}`},
+		{"json nested path", "application/json", `{"user":{"card":{"number":"x","cvv":"1"}},"card":{"number":"y"}}`, `{"card":{"number":"y"},"user":{"card":{"cvv":"1","number":"******"}}}`},
+		{"json wildcard path", "application/json", `{"items":[{"code":"a","n":1},{"code":"b"}]}`, `{"items":[{"code":"******","n":1},{"code":"******"}]}`},
+		{"json array path", "application/json", `{"orders":[{"note":"a"},[{"note":"b"}]],"note":"c"}`, `{"note":"c","orders":[{"note":"******"},[{"note":"******"}]]}`},
+		{"json object value", "application/json", `{"token":{"value":"t"}}`, `{"token":"******"}`},
+		{"json card number", "application/json", `{"n":4111111111111111,"s":"card 4111 1111 1111 1111","m":1700000000000}`, `{"m":1700000000000,"n":"******","s":"card ******"}`},
+		{"json custom pattern", "application/problem+json", `{"note":"id 11010519491231002X"}`, `{"note":"id ******"}`},
+		{"json unparseable", "application/json", `{"password":"ab`, "[unparseable json body omitted, 15 bytes]"},
+		{"form", "application/x-www-form-urlencoded", "user=bob&password=abc&api%5Fkey=k&card=4111111111111111", "user=bob&password=******&api%5Fkey=******&card=******"},
+		{"text pairs", "text/plain", "user=bob password=abc token: xyz phone=\"123\"", "user=bob password=****** token: ****** phone=******"},
+		{"text card", "text/plain; charset=utf-8", "paid with 4111-1111-1111-1111 at 1700000000000", "paid with ****** at 1700000000000"},
+		{"empty", "application/json", "", ""},
+	}
+	for _, c := range cases {
+		got := r.RedactBody(c.contentType, []byte(c.body))
+		if got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+		if c.contentType == "application/json" && c.body != "" && c.name != "json unparseable" && !json.Valid([]byte(got)) {
+			t.Errorf("%s: %s is not valid json", c.name, got)
+		}
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	r := NewRedactor(WithRedactQueryParams("session"))
+	cases := []struct {
+		url  string
+		want string
+	}{
+		{"/v1/files", "/v1/files"},
+		{"/v1/files?page=1&size=10", "/v1/files?page=1&size=10"},
+		{"/v1/files?access_token=t&page=1&Session=s", "/v1/files?access_token=******&page=1&Session=******"},
+		{"/v1/files?sig=a%2Fb&api-key=k", "/v1/files?sig=******&api-key=******"},
+		{"/v1/pay?card=4111111111111111", "/v1/pay?card=******"},
+	}
+	for _, c := range cases {
+		u, err := url.Parse(c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.RedactURL(u); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.url, got, c.want)
+		}
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	r := NewRedactor(WithRedactHeaders("x-session"))
+	header := http.Header{
+		"Authorization": {"Bearer t"},
+		"Cookie":        {"a=1", "b=2"},
+		"X-Api-Key":     {"k"},
+		"X-Session":     {"s"},
+		"X-Card":        {"4111111111111111"},
+		"Accept":        {"application/json", "text/plain"},
+	}
+	want := map[string]string{
+		"Authorization": "******",
+		"Cookie":        "******",
+		"X-Api-Key":     "******",
+		"X-Session":     "******",
+		"X-Card":        "******",
+		"Accept":        "application/json, text/plain",
+	}
+	got := r.RedactHeaders(header)
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s: got %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+func TestLuhnValid(t *testing.T) {
+	cases := []struct {
+		number string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"5500-0000-0000-0004", true},
+		{"378282246310005", true},
+		{"4111111111111112", false},
+		{"1700000000000", false},
+	}
+	for _, c := range cases {
+		if got := luhnValid(c.number); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.number, got, c.want)
+		}
+	}
+}
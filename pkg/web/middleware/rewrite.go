@@ -0,0 +1,30 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type originalURLKey struct{}
+
+// WithOriginalURL 在服务端重写请求路径（如API版本协商）前调用，记录客户端请求的原始URL，
+// 签名校验等依赖原始路径的中间件通过 OriginalURL 读取；返回的请求持有 URL 的副本，可直接修改
+func WithOriginalURL(r *http.Request) *http.Request {
+	u := *r.URL
+	r = r.WithContext(context.WithValue(r.Context(), originalURLKey{}, &u))
+	r.URL = new(url.URL)
+	*r.URL = u
+	return r
+}
+
+// OriginalURL 返回重写前的请求URL，未被重写时返回 r.URL
+func OriginalURL(r *http.Request) *url.URL {
+	if u, ok := r.Context().Value(originalURLKey{}).(*url.URL); ok {
+		return u
+	}
+	return r.URL
+}
@@ -0,0 +1,142 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ContextKeyCSPNonce = "cspNonce"
+	// CSPNoncePlaceholder CSP策略中的占位符，每个请求替换为新生成的 nonce
+	CSPNoncePlaceholder = "{nonce}"
+)
+
+type SecurityOptions struct {
+	hstsMaxAge            time.Duration
+	hstsIncludeSubdomains bool
+	hstsPreload           bool
+	noSniff               bool
+	frameOptions          string
+	referrerPolicy        string
+	permissionsPolicy     string
+	contentSecurityPolicy string
+}
+
+type SecurityOption func(*SecurityOptions)
+
+// DefaultHSTSMaxAge 未设置 WithHSTS 时 Strict-Transport-Security 的 max-age，同时包含 includeSubDomains
+const DefaultHSTSMaxAge = 365 * 24 * time.Hour
+
+// WithHSTS 设置 Strict-Transport-Security，maxAge 为0时不输出该响应头
+func WithHSTS(maxAge time.Duration, includeSubdomains, preload bool) SecurityOption {
+	return func(o *SecurityOptions) {
+		o.hstsMaxAge = maxAge
+		o.hstsIncludeSubdomains = includeSubdomains
+		o.hstsPreload = preload
+	}
+}
+
+func WithNoSniff(noSniff bool) SecurityOption {
+	return func(o *SecurityOptions) {
+		o.noSniff = noSniff
+	}
+}
+
+// WithFrameOptions 设置 X-Frame-Options（DENY/SAMEORIGIN），为空时不输出
+func WithFrameOptions(frameOptions string) SecurityOption {
+	return func(o *SecurityOptions) {
+		o.frameOptions = frameOptions
+	}
+}
+
+func WithReferrerPolicy(referrerPolicy string) SecurityOption {
+	return func(o *SecurityOptions) {
+		o.referrerPolicy = referrerPolicy
+	}
+}
+
+func WithPermissionsPolicy(permissionsPolicy string) SecurityOption {
+	return func(o *SecurityOptions) {
+		o.permissionsPolicy = permissionsPolicy
+	}
+}
+
+// WithContentSecurityPolicy 设置CSP策略，策略中的 {nonce} 会替换为每个请求生成的随机值
+func WithContentSecurityPolicy(policy string) SecurityOption {
+	return func(o *SecurityOptions) {
+		o.contentSecurityPolicy = policy
+	}
+}
+
+// SecurityHeaders 输出安全相关响应头，CSP 包含 {nonce} 时为每个请求生成 nonce，可通过 CSPNonce 获取
+func SecurityHeaders(options ...SecurityOption) gin.HandlerFunc {
+	opts := &SecurityOptions{
+		hstsMaxAge:            DefaultHSTSMaxAge,
+		hstsIncludeSubdomains: true,
+		noSniff:               true,
+		frameOptions:          "DENY",
+		referrerPolicy:        "strict-origin-when-cross-origin",
+		permissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+		contentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	var hsts string
+	if opts.hstsMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(opts.hstsMaxAge.Seconds()))
+		if opts.hstsIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if opts.hstsPreload {
+			hsts += "; preload"
+		}
+	}
+	useNonce := strings.Contains(opts.contentSecurityPolicy, CSPNoncePlaceholder)
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+		if hsts != "" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		if opts.noSniff {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+		if opts.frameOptions != "" {
+			header.Set("X-Frame-Options", opts.frameOptions)
+		}
+		if opts.referrerPolicy != "" {
+			header.Set("Referrer-Policy", opts.referrerPolicy)
+		}
+		if opts.permissionsPolicy != "" {
+			header.Set("Permissions-Policy", opts.permissionsPolicy)
+		}
+		if policy := opts.contentSecurityPolicy; policy != "" {
+			if useNonce {
+				nonce := newNonce()
+				ctx.Set(ContextKeyCSPNonce, nonce)
+				policy = strings.ReplaceAll(policy, CSPNoncePlaceholder, nonce)
+			}
+			header.Set("Content-Security-Policy", policy)
+		}
+		ctx.Next()
+	}
+}
+
+// CSPNonce 返回当前请求的 CSP nonce，供处理器和模板在内联脚本/样式中使用
+func CSPNonce(ctx *gin.Context) string {
+	return ctx.GetString(ContextKeyCSPNonce)
+}
+
+func newNonce() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return base64.StdEncoding.EncodeToString(buf)
+}
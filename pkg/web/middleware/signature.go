@@ -0,0 +1,74 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/ethanli-dev/go-app-layout/api"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/signature"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ContextKeySignatureKeyID = "signatureKeyId"
+	// signatureBodyLimit 未携带 X-Content-Sha256 时读入内存计算哈希的请求体上限
+	signatureBodyLimit = 10 << 20 // 10MB
+)
+
+// Signature 校验 HMAC-SHA256 请求签名，校验通过后可通过 SignatureKeyID 获取调用方 keyID；
+// 请求携带 X-Content-Sha256 时不读取请求体，由处理器读取时流式校验
+func Signature(verifier *signature.Verifier) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bodyHash := ctx.GetHeader(signature.HeaderKeyContentSHA256)
+		streaming := bodyHash != ""
+		if !streaming {
+			var body []byte
+			if ctx.Request.Body != nil && ctx.Request.Body != http.NoBody {
+				var err error
+				body, err = io.ReadAll(io.LimitReader(ctx.Request.Body, signatureBodyLimit+1))
+				if err != nil {
+					api.Failure(ctx, errorx.Wrap(err, errorx.ErrCodeBadRequest, "failed to read request body"))
+					ctx.Abort()
+					return
+				}
+				if len(body) > signatureBodyLimit {
+					api.Failure(ctx, errorx.New(errorx.ErrCodeBadRequest, "request body too large, sign with %s", signature.HeaderKeyContentSHA256))
+					ctx.Abort()
+					return
+				}
+				ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			bodyHash = signature.HashBody(body)
+		}
+		// 路径被重写时按客户端签名的原始路径校验
+		req := ctx.Request
+		if u := OriginalURL(req); u != req.URL {
+			req = req.WithContext(req.Context())
+			req.URL = u
+		}
+		keyID, err := verifier.Verify(ctx, req, bodyHash)
+		if err != nil {
+			slog.WarnContext(ctx, "request signature verification failed",
+				"key_id", ctx.GetHeader(signature.HeaderKeyKeyID), "err", err)
+			api.Failure(ctx, errorx.Wrap(err, errorx.ErrCodeUnauthorized, "invalid request signature"))
+			ctx.Abort()
+			return
+		}
+		if streaming && ctx.Request.Body != nil && ctx.Request.Body != http.NoBody {
+			ctx.Request.Body = signature.VerifyBody(ctx.Request.Body, bodyHash)
+		}
+		ctx.Set(ContextKeySignatureKeyID, keyID)
+		ctx.Next()
+	}
+}
+
+// SignatureKeyID 返回通过签名校验的调用方 keyID
+func SignatureKeyID(ctx *gin.Context) string {
+	return ctx.GetString(ContextKeySignatureKeyID)
+}
@@ -0,0 +1,41 @@
+/*
+Copyright © 2025 lixw
+*/
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/pkg/signature"
+	"github.com/gin-gonic/gin"
+)
+
+// TestSignatureOriginalURL 客户端签名未带版本前缀的路径，服务端协商版本后重写了路径
+func TestSignatureOriginalURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := []byte("secret")
+	verifier := signature.NewVerifier(func(context.Context, string) ([]byte, error) { return secret, nil })
+	engine := gin.New()
+	engine.GET("/v1/tenant/info", Signature(verifier), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, SignatureKeyID(ctx))
+	})
+
+	for _, rewrite := range []bool{false, true} {
+		req := httptest.NewRequest(http.MethodGet, "/tenant/info", nil)
+		if err := signature.NewSigner("1", secret).Sign(req); err != nil {
+			t.Fatal(err)
+		}
+		if rewrite {
+			req = WithOriginalURL(req)
+		}
+		req.URL.Path = "/v1/tenant/info"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if got := w.Body.String() == "1"; got != rewrite {
+			t.Errorf("rewrite recorded = %v: body = %s", rewrite, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2025 lixw
+*/
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ethanli-dev/go-app-layout/api"
+	"github.com/ethanli-dev/go-app-layout/internal/webtest"
+	"github.com/ethanli-dev/go-app-layout/pkg/errorx"
+	"github.com/ethanli-dev/go-app-layout/pkg/web"
+	"github.com/ethanli-dev/go-app-layout/pkg/web/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// failingRoutes 注册返回业务错误和 panic 的路由
+func failingRoutes(group *gin.RouterGroup) {
+	group.GET("/missing", func(ctx *gin.Context) {
+		api.Failure(ctx, errorx.New(errorx.ErrCodeNotFound, "file not found"))
+	})
+	group.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+}
+
+func problemOf(t *testing.T, resp *webtest.Response) *api.Problem {
+	t.Helper()
+	var problem api.Problem
+	if err := json.Unmarshal(resp.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("body = %s: %v", resp.Body.String(), err)
+	}
+	return &problem
+}
+
+func TestProblemDetails(t *testing.T) {
+	c := webtest.New(t,
+		webtest.WithWebOptions(web.WithAdminToken(adminToken), web.WithProblemDetails("https://errors.example.com/", web.GroupAPI)),
+		webtest.WithRoutes(failingRoutes),
+		webtest.WithAdminRoutes(failingRoutes),
+	)
+
+	resp := c.GET("/v1/missing").Header(middleware.HeaderKeyRequestID, "trace-1").Send().
+		Status(http.StatusNotFound).
+		HasHeader("Content-Type", api.MediaTypeProblemJSON)
+	want := api.Problem{
+		Type:     "https://errors.example.com/10003",
+		Title:    "Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "file not found",
+		Instance: "/v1/missing",
+		Code:     errorx.ErrCodeNotFound,
+		TraceID:  "trace-1",
+	}
+	if got := problemOf(t, resp); *got != want {
+		t.Errorf("problem = %+v, want %+v", *got, want)
+	}
+
+	// 未启用的路由组保持 HTTP 200 加错误码响应体
+	c.GET("/admin/missing").Bearer(adminToken).Send().
+		Status(http.StatusOK).
+		HasHeader("Content-Type", "application/json; charset=utf-8").
+		Code(errorx.ErrCodeNotFound).
+		Message("file not found")
+}
+
+func TestProblemDetailsAboutBlank(t *testing.T) {
+	c := webtest.New(t, webtest.WithWebOptions(web.WithProblemDetails("", web.GroupAPI)), webtest.WithRoutes(failingRoutes))
+	resp := c.GET("/v1/missing").Send().Status(http.StatusNotFound)
+	if got := problemOf(t, resp); got.Type != "about:blank" || got.TraceID == "" {
+		t.Errorf("problem = %+v, want about:blank with a generated trace id", *got)
+	}
+}
+
+func TestProblemDetailsRecovery(t *testing.T) {
+	c := webtest.New(t,
+		webtest.WithWebOptions(web.WithAdminToken(adminToken), web.WithProblemDetails("https://errors.example.com", web.GroupAPI)),
+		webtest.WithRoutes(failingRoutes),
+		webtest.WithAdminRoutes(failingRoutes),
+	)
+
+	resp := c.GET("/v1/panic").Header(middleware.HeaderKeyRequestID, "trace-2").Send().
+		Status(http.StatusInternalServerError).
+		HasHeader("Content-Type", api.MediaTypeProblemJSON)
+	problem := problemOf(t, resp)
+	if problem.Status != http.StatusInternalServerError || problem.Code != errorx.ErrCodeInternalServer ||
+		problem.Type != "https://errors.example.com/10007" || problem.Instance != "/v1/panic" || problem.TraceID != "trace-2" {
+		t.Errorf("problem = %+v, want an internal server error problem", *problem)
+	}
+
+	// 未启用的路由组只返回 500 状态码
+	resp = c.GET("/admin/panic").Bearer(adminToken).Send().Status(http.StatusInternalServerError)
+	if resp.Body.Len() > 0 {
+		t.Errorf("body = %s, want empty", resp.Body.String())
+	}
+}
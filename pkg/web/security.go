@@ -0,0 +1,29 @@
+/*
+Copyright © 2025 lixw
+*/
+package web
+
+import "github.com/ethanli-dev/go-app-layout/pkg/web/middleware"
+
+// defaultSecurityOptions 返回路由组默认的安全响应头选项
+// JSON 接口禁止加载任何资源；Swagger UI 依赖内联脚本和样式；静态文件原样输出无法注入 nonce，仅允许同源资源
+func defaultSecurityOptions(group string) []middleware.SecurityOption {
+	switch group {
+	case GroupSwagger:
+		return []middleware.SecurityOption{
+			middleware.WithReferrerPolicy("no-referrer"),
+			middleware.WithContentSecurityPolicy("default-src 'self'; script-src 'self' 'unsafe-inline'; " +
+				"style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'"),
+		}
+	case GroupStatic:
+		return []middleware.SecurityOption{
+			middleware.WithFrameOptions("SAMEORIGIN"),
+			middleware.WithContentSecurityPolicy("default-src 'self'; img-src 'self' data:; frame-ancestors 'self'"),
+		}
+	default:
+		return []middleware.SecurityOption{
+			middleware.WithReferrerPolicy("no-referrer"),
+			middleware.WithContentSecurityPolicy("default-src 'none'; frame-ancestors 'none'"),
+		}
+	}
+}
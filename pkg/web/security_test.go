@@ -0,0 +1,27 @@
+/*
+Copyright © 2025 lixw
+*/
+package web_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ethanli-dev/go-app-layout/internal/webtest"
+	"github.com/ethanli-dev/go-app-layout/pkg/web"
+)
+
+// TestStaticSecurityHeaders 静态文件不会注入 nonce，默认策略不能依赖 nonce
+func TestStaticSecurityHeaders(t *testing.T) {
+	fs := fstest.MapFS{"index.html": {Data: []byte("<script src=\"app.js\"></script>")}}
+	c := webtest.New(t, webtest.WithWebOptions(web.WithEmbedFS("/static", fs)))
+
+	resp := c.GET("/static/").Send().Status(http.StatusOK).
+		HasHeader("Content-Security-Policy", "default-src 'self'; img-src 'self' data:; frame-ancestors 'self'").
+		HasHeader("X-Frame-Options", "SAMEORIGIN")
+	if !strings.Contains(resp.Body.String(), "app.js") {
+		t.Fatalf("body = %q", resp.Body.String())
+	}
+}
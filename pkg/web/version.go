@@ -0,0 +1,194 @@
+/*
+Copyright © 2025 lixw
+*/
+package web
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ethanli-dev/go-app-layout/docs"
+	"github.com/ethanli-dev/go-app-layout/pkg/web/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+const HeaderKeyAPIVersion = "X-Api-Version"
+
+// mediaTypeVersionRegexp 匹配 application/vnd.{vendor}.{version}+json 形式的媒体类型
+var mediaTypeVersionRegexp = regexp.MustCompile(`application/vnd\.[\w.-]+?\.(v\d+)(?:\+[\w-]+)?`)
+
+type Version struct {
+	name        string
+	deprecation []middleware.DeprecationOption
+	deprecated  bool
+}
+
+type VersionOption func(*Version)
+
+// Deprecated 将整个版本标记为废弃，该版本下所有路由均输出 Deprecation/Sunset 响应头
+func Deprecated(options ...middleware.DeprecationOption) VersionOption {
+	return func(v *Version) {
+		v.deprecated = true
+		v.deprecation = append(v.deprecation, options...)
+	}
+}
+
+func NewVersion(name string, options ...VersionOption) *Version {
+	v := &Version{name: name}
+	for _, option := range options {
+		option(v)
+	}
+	return v
+}
+
+func (v *Version) Name() string {
+	return v.name
+}
+
+// versionRoutes 记录版本下注册的路由，用于协商时判断重写后的路径是否存在
+type versionRoutes struct {
+	prefix string
+	routes []routePattern
+}
+
+type routePattern struct {
+	method   string
+	segments []string
+}
+
+func newRoutePattern(method, fullPath string) routePattern {
+	return routePattern{method: method, segments: splitPath(fullPath)}
+}
+
+func (p routePattern) match(method string, segments []string) bool {
+	if p.method != method {
+		return false
+	}
+	for i, seg := range p.segments {
+		if strings.HasPrefix(seg, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(seg, ":") && seg != segments[i] {
+			return false
+		}
+	}
+	return len(p.segments) == len(segments)
+}
+
+func (vr *versionRoutes) match(method, urlPath string) bool {
+	segments := splitPath(urlPath)
+	for _, route := range vr.routes {
+		if route.match(method, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// UseVersion 在 basePath/{version} 下注册路由，多个版本可以同时提供服务
+func (s *Server) UseVersion(v *Version, routeFuncs ...func(*gin.RouterGroup)) *Server {
+	prefix := path.Join(s.basePath, v.name)
+	handlers := append(slices.Clone(s.apiMiddleware), apiVersion(v.name))
+	if v.deprecated {
+		handlers = append(handlers, middleware.Deprecation(v.deprecation...))
+	}
+	group := s.engine.Group(prefix, handlers...)
+
+	existing := make(map[string]struct{})
+	for _, route := range s.engine.Routes() {
+		existing[route.Method+" "+route.Path] = struct{}{}
+	}
+	for _, fn := range routeFuncs {
+		fn(group)
+	}
+
+	vr, ok := s.versions[v.name]
+	if !ok {
+		vr = &versionRoutes{prefix: prefix}
+		s.versions[v.name] = vr
+	}
+	for _, route := range s.engine.Routes() {
+		if _, ok := existing[route.Method+" "+route.Path]; ok {
+			continue
+		}
+		vr.routes = append(vr.routes, newRoutePattern(route.Method, route.Path))
+	}
+	if v.name == s.defaultVersion {
+		docs.SwaggerInfo.BasePath = prefix
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(s.versions) > 0 {
+		r = s.negotiateVersion(r)
+	}
+	s.engine.ServeHTTP(w, r)
+}
+
+// negotiateVersion 对未携带版本前缀的请求，根据请求头或媒体类型协商版本并重写路径，
+// 原始路径记录在请求上下文中，供签名校验使用
+func (s *Server) negotiateVersion(r *http.Request) *http.Request {
+	urlPath := r.URL.Path
+	for _, vr := range s.versions {
+		if urlPath == vr.prefix || strings.HasPrefix(urlPath, vr.prefix+"/") {
+			return r
+		}
+	}
+	rel, ok := strings.CutPrefix(urlPath, strings.TrimSuffix(s.basePath, "/"))
+	if !ok {
+		return r
+	}
+	name := requestedVersion(r)
+	if name == "" {
+		name = s.defaultVersion
+	}
+	vr, ok := s.versions[name]
+	if !ok {
+		return r
+	}
+	target := path.Join(vr.prefix, rel)
+	if !vr.match(r.Method, target) {
+		return r
+	}
+	r = middleware.WithOriginalURL(r)
+	r.URL.Path = target
+	r.URL.RawPath = ""
+	return r
+}
+
+// requestedVersion 优先读取 X-Api-Version 请求头，其次解析 Accept 中的厂商媒体类型
+func requestedVersion(r *http.Request) string {
+	if v := strings.TrimSpace(r.Header.Get(HeaderKeyAPIVersion)); v != "" {
+		if !strings.HasPrefix(v, "v") {
+			v = "v" + v
+		}
+		return v
+	}
+	if m := mediaTypeVersionRegexp.FindStringSubmatch(r.Header.Get("Accept")); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// apiVersion 在响应头中返回实际处理请求的版本
+func apiVersion(name string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Writer.Header().Set(HeaderKeyAPIVersion, name)
+		ctx.Next()
+	}
+}
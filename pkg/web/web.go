@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/ethanli-dev/go-app-layout/docs"
+	"github.com/ethanli-dev/go-app-layout/pkg/ternary"
 	"github.com/ethanli-dev/go-app-layout/pkg/web/middleware"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -22,19 +23,44 @@ import (
 )
 
 type Options struct {
-	address        string
-	readTimeout    time.Duration
-	writeTimeout   time.Duration
-	idleTimeout    time.Duration
-	maxHeaderBytes int
-	basePath       string
-	staticPath     string
-	fs             fs.FS
-	middleware     []gin.HandlerFunc
+	address          string
+	readTimeout      time.Duration
+	writeTimeout     time.Duration
+	idleTimeout      time.Duration
+	maxHeaderBytes   int
+	basePath         string
+	staticPath       string
+	fs               fs.FS
+	middleware       []gin.HandlerFunc
+	defaultVersion   string
+	maintenance      *middleware.MaintenanceSwitch
+	adminToken       string
+	ipResolver       *middleware.ClientIPResolver
+	ipFilters        map[string]*middleware.IPFilter
+	security         map[string][]middleware.SecurityOption
+	loggerOptions    []middleware.LoggerOption
+	accessLog        []middleware.AccessLogOption
+	accessLogEnabled bool
+	problemGroups    map[string]bool
+	problemTypeBase  string
 }
 
 type Option func(*Options)
 
+// 路由组，IP黑白名单、安全响应头和错误响应格式按路由组配置
+const (
+	GroupAPI     = "api"
+	GroupAdmin   = "admin"
+	GroupHealth  = "health"
+	GroupSwagger = "swagger"
+	GroupStatic  = "static"
+)
+
+// Groups 返回全部路由组
+func Groups() []string {
+	return []string{GroupAPI, GroupAdmin, GroupHealth, GroupSwagger, GroupStatic}
+}
+
 func WithAddress(address string) Option {
 	return func(o *Options) {
 		o.address = address
@@ -93,10 +119,93 @@ func WithMiddleware(middleware ...gin.HandlerFunc) Option {
 	}
 }
 
+// WithDefaultVersion 设置默认API版本，未携带版本信息的请求将路由到该版本
+func WithDefaultVersion(version string) Option {
+	return func(o *Options) {
+		o.defaultVersion = version
+	}
+}
+
+// WithMaintenance 启用维护模式开关，仅作用于业务路由，健康检查和管理接口不受影响
+func WithMaintenance(maintenance *middleware.MaintenanceSwitch) Option {
+	return func(o *Options) {
+		o.maintenance = maintenance
+	}
+}
+
+// WithAdminToken 设置管理接口（/admin）的访问令牌，未设置时管理接口不可用
+func WithAdminToken(token string) Option {
+	return func(o *Options) {
+		o.adminToken = token
+	}
+}
+
+// WithClientIPResolver 设置客户端IP解析器（可信代理），未设置时不信任任何代理转发的地址
+func WithClientIPResolver(resolver *middleware.ClientIPResolver) Option {
+	return func(o *Options) {
+		o.ipResolver = resolver
+	}
+}
+
+// WithIPFilter 为路由组设置IP黑白名单，group 为 Groups 之一：api（业务路由）、admin（管理接口）、health、swagger、static
+func WithIPFilter(group string, filter *middleware.IPFilter) Option {
+	return func(o *Options) {
+		if o.ipFilters == nil {
+			o.ipFilters = make(map[string]*middleware.IPFilter)
+		}
+		o.ipFilters[group] = filter
+	}
+}
+
+// WithSecurity 为路由组设置安全响应头选项，group 为空时作用于所有路由组
+func WithSecurity(group string, options ...middleware.SecurityOption) Option {
+	return func(o *Options) {
+		if o.security == nil {
+			o.security = make(map[string][]middleware.SecurityOption)
+		}
+		o.security[group] = append(o.security[group], options...)
+	}
+}
+
+// WithLoggerOptions 设置请求日志中间件选项（脱敏规则、请求体记录等）
+func WithLoggerOptions(options ...middleware.LoggerOption) Option {
+	return func(o *Options) {
+		o.loggerOptions = append(o.loggerOptions, options...)
+	}
+}
+
+// WithAccessLog 启用独立的访问日志
+func WithAccessLog(options ...middleware.AccessLogOption) Option {
+	return func(o *Options) {
+		o.accessLogEnabled = true
+		o.accessLog = append(o.accessLog, options...)
+	}
+}
+
+// WithProblemDetails 为路由组启用真实HTTP状态码和 RFC 9457 错误响应，未启用的路由组保持HTTP 200加错误码响应体，
+// typeBaseURI 为问题类型 URI 前缀，为空时使用 about:blank
+func WithProblemDetails(typeBaseURI string, groups ...string) Option {
+	return func(o *Options) {
+		if o.problemGroups == nil {
+			o.problemGroups = make(map[string]bool)
+		}
+		for _, group := range groups {
+			o.problemGroups[group] = true
+		}
+		o.problemTypeBase = typeBaseURI
+	}
+}
+
 type Server struct {
-	httpSrv   *http.Server
-	baseRoute *gin.RouterGroup
-	engine    *gin.Engine
+	httpSrv        *http.Server
+	baseRoute      *gin.RouterGroup
+	adminRoute     *gin.RouterGroup
+	engine         *gin.Engine
+	basePath       string
+	defaultVersion string
+	versions       map[string]*versionRoutes
+	apiMiddleware  []gin.HandlerFunc
+	maintenance    *middleware.MaintenanceSwitch
 }
 
 func New(options ...Option) *Server {
@@ -119,9 +228,16 @@ func New(options ...Option) *Server {
 	engine := gin.New()
 	engine.RedirectTrailingSlash = true
 	engine.RedirectFixedPath = true
+	// 客户端IP由 RealIP 中间件根据可信代理解析，禁用 gin 自带的转发头解析
+	engine.ForwardedByClientIP = false
+	ipResolver := opts.ipResolver
+	if ipResolver == nil {
+		ipResolver = middleware.NewClientIPResolver()
+	}
 	// 中间件注册顺序（关键！）
-	// 1. 跨域处理 → 2. 追踪ID → 3. 日志 → 4. 异常恢复
+	// 1. 真实IP → 2. 跨域处理 → 3. 追踪ID → 4. 访问日志 → 5. 日志 → 6. 异常恢复
 	engine.Use(
+		middleware.RealIP(ipResolver),
 		cors.New(cors.Config{
 			AllowOrigins:     []string{"*"},
 			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
@@ -131,35 +247,69 @@ func New(options ...Option) *Server {
 			MaxAge:           12 * time.Hour,
 		}),
 		middleware.RequestId(),
-		middleware.Logger(),
+	)
+	if opts.accessLogEnabled {
+		engine.Use(middleware.AccessLog(opts.accessLog...))
+	}
+	engine.Use(
+		middleware.Logger(opts.loggerOptions...),
 		middleware.Recovery(),
 		middleware.I18n(),
 	)
 	engine.Use(opts.middleware...)
 
-	engine.GET("/health", func(ctx *gin.Context) {
-		ctx.JSON(http.StatusOK, gin.H{"status": "ok", "time": time.Now().UnixMilli()})
-	})
+	maintenance := opts.maintenance
+	if maintenance == nil {
+		maintenance = middleware.NewMaintenanceSwitch()
+	}
+	// 路由组中间件：问题详情 → 安全响应头 → IP黑白名单 → 组内中间件
+	groupMiddleware := func(group string, handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+		var chain []gin.HandlerFunc
+		if opts.problemGroups[group] {
+			chain = append(chain, middleware.ProblemDetails(opts.problemTypeBase))
+		}
+		securityOpts := append(defaultSecurityOptions(group), opts.security[""]...)
+		chain = append(chain, middleware.SecurityHeaders(append(securityOpts, opts.security[group]...)...))
+		if filter, ok := opts.ipFilters[group]; ok {
+			chain = append(chain, middleware.IPAccess(filter))
+		}
+		return append(chain, handlers...)
+	}
+	// 维护模式仅作用于业务路由，不作用于健康检查、文档和管理接口
+	apiMiddleware := groupMiddleware(GroupAPI, middleware.Maintenance(maintenance))
+	adminMiddleware := groupMiddleware(GroupAdmin, middleware.AdminAuth(opts.adminToken))
+
+	engine.GET("/health", append(groupMiddleware(GroupHealth), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok", "maintenance": maintenance.Mode(), "time": time.Now().UnixMilli()})
+	})...)
 
-	engine.GET("/swagger/*any", ginswag.WrapHandler(swagfiles.Handler))
+	engine.GET("/swagger/*any", append(groupMiddleware(GroupSwagger), ginswag.WrapHandler(swagfiles.Handler))...)
 
 	if opts.fs != nil {
-		engine.StaticFS(opts.staticPath, http.FS(opts.fs))
+		engine.Group(opts.staticPath, groupMiddleware(GroupStatic)...).StaticFS("/", http.FS(opts.fs))
 		slog.Info("serving static files", "path", opts.staticPath)
 	}
-	
-	return &Server{
-		baseRoute: engine.Group(opts.basePath),
-		engine:    engine,
-		httpSrv: &http.Server{
-			Addr:           opts.address,
-			Handler:        engine,
-			IdleTimeout:    opts.idleTimeout,
-			ReadTimeout:    opts.readTimeout,
-			WriteTimeout:   opts.writeTimeout,
-			MaxHeaderBytes: opts.maxHeaderBytes,
-		},
+
+	s := &Server{
+		baseRoute:      engine.Group(opts.basePath, apiMiddleware...),
+		adminRoute:     engine.Group("/admin", adminMiddleware...),
+		engine:         engine,
+		basePath:       ternary.IFElse(opts.basePath == "", "/", opts.basePath),
+		defaultVersion: opts.defaultVersion,
+		versions:       make(map[string]*versionRoutes),
+		apiMiddleware:  apiMiddleware,
+		maintenance:    maintenance,
+	}
+	s.UseAdmin(s.maintenanceRoutes, s.deprecationRoutes)
+	s.httpSrv = &http.Server{
+		Addr:           opts.address,
+		Handler:        s,
+		IdleTimeout:    opts.idleTimeout,
+		ReadTimeout:    opts.readTimeout,
+		WriteTimeout:   opts.writeTimeout,
+		MaxHeaderBytes: opts.maxHeaderBytes,
 	}
+	return s
 }
 
 func (s *Server) Start(ctx context.Context) error {